@@ -23,7 +23,7 @@ export default function LoginPage() {
             });
 
             const data = await res.json();
-            if (!res.ok) throw new Error(data.error || "Login failed");
+            if (!res.ok) throw new Error(data.message || "Login failed");
 
             // Handle successful login (e.g., store token and redirect)
             window.location.href = "/dashboard";
@@ -0,0 +1,10 @@
+// Package openapi embeds the OpenAPI document generated from proto/auth.proto.
+// Regenerate it with make proto after changing the proto.
+package openapi
+
+import _ "embed"
+
+// Spec is the OpenAPI 2.0 document of the REST API
+//
+//go:embed auth.swagger.json
+var Spec []byte
@@ -6,14 +6,14 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-auth-service/internal/events"
 	"github.com/vhvplatform/go-auth-service/internal/gateway"
+	"github.com/vhvplatform/go-auth-service/internal/keys"
 	"github.com/vhvplatform/go-shared/config"
-	"github.com/vhvplatform/go-shared/jwt"
 	"github.com/vhvplatform/go-shared/logger"
 	"go.uber.org/zap"
 )
@@ -34,63 +34,86 @@ func main() {
 
 	log.Info("Starting API Gateway", zap.String("environment", cfg.Environment))
 
-	// Initialize JWT manager for internal token generation
-	jwtManager := jwt.NewManager(cfg.JWT.Secret, cfg.JWT.Expiration, cfg.JWT.RefreshExpiration)
+	// Initialize signing keys for internal tokens and rotate them in the background
+	signingKeys, err := keys.LoadFromEnv(context.Background(), "GATEWAY_SIGNING")
+	if err != nil {
+		log.Fatal("Failed to load signing keys", zap.Error(err))
+	}
+	rotationCtx, stopRotation := context.WithCancel(context.Background())
+	defer stopRotation()
+	go signingKeys.Run(rotationCtx, keys.ReloadInterval, func(err error) {
+		log.Error("Signing key rotation failed", zap.Error(err))
+	})
 
-	// Initialize local cache
-	// In a real scenario, these values should come from config
-	localCache := gateway.NewCache(5*time.Minute, 10*time.Minute)
+	// Initialize local cache of token validations
+	localCache := gateway.NewCache(gateway.DefaultCacheConfig())
+
+	// Evict cached tokens as soon as the auth service revokes them or changes roles.
+	// Without Redis cached tokens stay valid until they expire.
+	if cfg.Redis.Enabled {
+		eventBus := events.DialRedis(cfg.Redis)
+		defer eventBus.Close()
+		go gateway.SubscribeInvalidations(rotationCtx, localCache, eventBus, 5*time.Second, log)
+	} else {
+		log.Warn("Redis is disabled, revoked tokens stay cached until they expire")
+	}
+
+	// Load the route table and reload it when the file changes
+	routesFile := os.Getenv("GATEWAY_ROUTES_FILE")
+	if routesFile == "" {
+		routesFile = "cmd/gateway/routes.yaml"
+	}
+	routes, err := gateway.LoadRouteTable(routesFile)
+	if err != nil {
+		log.Fatal("Failed to load routes", zap.Error(err))
+	}
+	reloadInterval := 5 * time.Second
+	if value := os.Getenv("GATEWAY_ROUTES_RELOAD"); value != "" {
+		if reloadInterval, err = time.ParseDuration(value); err != nil {
+			log.Fatal("Invalid GATEWAY_ROUTES_RELOAD", zap.Error(err))
+		}
+	}
+	go routes.Run(rotationCtx, reloadInterval, func(err error) {
+		log.Error("Route reload failed, keeping the current routes", zap.Error(err))
+	})
+	log.Info("Routes loaded", zap.String("file", routesFile), zap.Int("routes", len(routes.Routes())))
 
 	// Initialize Proxy
 	proxy := gateway.NewProxy()
-	// Add default services (these should eventually come from service discovery or config)
-	proxy.AddService("auth-service", "http://localhost:8081")
-	proxy.AddService("file-service", "http://localhost:8082")
 
 	// Initialize Gin router
 	router := gin.New()
 	router.Use(gin.Recovery(), gin.Logger())
 
-	// Initialize real AuthClient (gRPC) - placeholder for now
-	// authClient := &gateway.AuthRPCClient{ ... }
+	// Initialize AuthClient that validates tokens with the auth service over gRPC
+	authClient, err := gateway.LoadAuthClientFromEnv()
+	if err != nil {
+		log.Fatal("Failed to create auth client", zap.Error(err))
+	}
+	defer authClient.Close()
+	authMiddleware := gateway.AuthMiddleware(authClient, localCache, signingKeys, log)
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "gateway is healthy"})
 	})
 
-	// Add AuthMiddleware to router
-	// For public routes, we don't apply it.
-
-	apiGroup := router.Group("/api")
-	{
-		// Use manual check for login/register
-		apiGroup.Any("/*path", func(c *gin.Context) {
-			path := c.Param("path")
-			if strings.Contains(path, "/auth/login") || strings.Contains(path, "/auth/register") {
-				proxy.ServeHTTP(c.Writer, c.Request, "", "")
-				return
-			}
-
-			// Apply AuthMiddleware inline (simplified)
-			gateway.AuthMiddleware(nil, localCache, jwtManager, log)(c)
-			if c.IsAborted() {
-				return
-			}
-
-			tenantID, _ := c.Get("tenant_id")
-			internalToken, _ := c.Get("internal_token")
-
-			proxy.ServeHTTP(c.Writer, c.Request, tenantID.(string), internalToken.(string))
-		})
-	}
+	// Token cache hits, misses and evictions
+	router.GET("/metrics/cache", func(c *gin.Context) {
+		c.JSON(http.StatusOK, localCache.Stats())
+	})
 
-	// Other groups for /page and /upload
-	router.Any("/page/*path", func(c *gin.Context) {
-		proxy.ServeHTTP(c.Writer, c.Request, "", "")
+	// Public keys that verify internal tokens
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, signingKeys.JWKS())
 	})
-	router.Any("/upload/*path", func(c *gin.Context) {
-		proxy.ServeHTTP(c.Writer, c.Request, "", "")
+
+	// Every other request is proxied by the route table; AuthMiddleware skips public routes
+	router.NoRoute(routes.Middleware(), authMiddleware, func(c *gin.Context) {
+		route, _ := gateway.RouteFromContext(c)
+		tenantID := c.GetString("tenant_id")
+		internalToken := c.GetString("internal_token")
+		proxy.ServeHTTP(c.Writer, c.Request, route, tenantID, internalToken)
 	})
 
 	// Start server
@@ -122,5 +145,3 @@ func main() {
 		log.Fatal("Gateway forced to shutdown", zap.Error(err))
 	}
 }
-
-// Note: Added missing import for strings
@@ -5,24 +5,32 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-auth-service/api/openapi"
+	"github.com/vhvplatform/go-auth-service/internal/events"
 	"github.com/vhvplatform/go-auth-service/internal/grpc"
 	"github.com/vhvplatform/go-auth-service/internal/handler"
+	"github.com/vhvplatform/go-auth-service/internal/keys"
+	"github.com/vhvplatform/go-auth-service/internal/notification"
+	"github.com/vhvplatform/go-auth-service/internal/oauth"
+	"github.com/vhvplatform/go-auth-service/internal/oidc"
+	"github.com/vhvplatform/go-auth-service/internal/pb"
 	"github.com/vhvplatform/go-auth-service/internal/repository"
 	"github.com/vhvplatform/go-auth-service/internal/service"
+	"github.com/vhvplatform/go-auth-service/internal/store"
 	"github.com/vhvplatform/go-auth-service/internal/utils"
+	"github.com/vhvplatform/go-shared/cache"
 	"github.com/vhvplatform/go-shared/config"
 	"github.com/vhvplatform/go-shared/jwt"
 	"github.com/vhvplatform/go-shared/logger"
 	"github.com/vhvplatform/go-shared/mongodb"
 	"github.com/vhvplatform/go-shared/redis"
-
-	// pb "github.com/vhvplatform/go-auth-service/proto"
 	"go.uber.org/zap"
 	grpcServer "google.golang.org/grpc"
 	"google.golang.org/grpc/health"
@@ -76,31 +84,142 @@ func main() {
 	// Initialize JWT manager
 	jwtManager := jwt.NewManager(cfg.JWT.Secret, cfg.JWT.Expiration, cfg.JWT.RefreshExpiration)
 
+	// Initialize token hasher. Refresh tokens and session keys are stored as keyed hashes;
+	// changing the key invalidates all sessions and refresh tokens.
+	tokenHashKey := os.Getenv("TOKEN_HASH_KEY")
+	if tokenHashKey == "" {
+		log.Warn("TOKEN_HASH_KEY is not set, falling back to the JWT secret")
+		tokenHashKey = cfg.JWT.Secret
+	}
+	tokenHasher := utils.NewTokenHasher(tokenHashKey)
+
+	// Initialize signing keys for ID tokens and rotate them in the background
+	signingKeys, err := keys.LoadFromEnv(context.Background(), "SIGNING")
+	if err != nil {
+		log.Fatal("Failed to load signing keys", zap.Error(err))
+	}
+	rotationCtx, stopRotation := context.WithCancel(context.Background())
+	defer stopRotation()
+	go signingKeys.Run(rotationCtx, keys.ReloadInterval, func(err error) {
+		log.Error("Signing key rotation failed", zap.Error(err))
+	})
+	oidcProvider := oidc.LoadProviderFromEnv(signingKeys)
+
+	// Initialize notifier. Messages are only logged unless NOTIFICATION_FILE names a file to append them to.
+	var notifier notification.Notifier = notification.NewLogNotifier(log)
+	if path := os.Getenv("NOTIFICATION_FILE"); path != "" {
+		notifier = notification.NewFileNotifier(path)
+	}
+
+	// Publish session and role changes for the gateway's token cache over Redis when it is enabled
+	var publisher events.Publisher
+	if cfg.Redis.Enabled {
+		eventBus := events.DialRedis(cfg.Redis)
+		defer eventBus.Close()
+		publisher = eventBus
+	}
+
+	// Sessions, MFA challenges and attempt counters are kept in Redis when it is enabled
+	var sessionStore store.Store
+	if cfg.Redis.Enabled {
+		redisStore := store.DialRedis(cfg.Redis)
+		defer redisStore.Close()
+		sessionStore = redisStore
+	}
+
 	// Initialize repositories
-	userRepo := repository.NewUserRepository(mongoClient.Database())
-	tenantRepo := repository.NewTenantRepository(mongoClient.Database())
-	refreshTokenRepo := repository.NewRefreshTokenRepository(mongoClient.Database())
-	roleRepo := repository.NewRoleRepository(mongoClient.Database())
+	db := mongoClient.Database()
+	userRepo := repository.NewUserRepository(db)
+	userTenantRepo := repository.NewUserTenantRepository(db)
+	tenantLoginConfigRepo := repository.NewTenantLoginConfigRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	userLockoutRepo := repository.NewUserLockoutRepository(db)
+	userMFARepo := repository.NewUserMFARepository(db)
+	passwordResetRepo := repository.NewPasswordResetTokenRepository(db)
+	verificationRepo := repository.NewIdentifierVerificationRepository(db)
+	oauthAccountRepo := repository.NewOAuthAccountRepository(db)
+	oidcClientRepo := repository.NewOIDCClientRepository(db)
+	serviceAccountRepo := repository.NewServiceAccountRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	webauthnCredentialRepo := repository.NewWebAuthnCredentialRepository(db)
+	passwordlessLoginRepo := repository.NewPasswordlessLoginRepository(db)
+
+	// Roles and permissions are cached in Redis when it is enabled
+	var permissionCache cache.Cache
+	if redisClient != nil {
+		permissionCache = redis.NewCache(redisClient, redis.CacheConfig{
+			DefaultTTL: 5 * time.Minute,
+			KeyPrefix:  "auth",
+		})
+	}
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, tenantRepo, refreshTokenRepo, roleRepo, jwtManager, redisClient, log)
+	permissionService := service.NewPermissionService(userRepo, userTenantRepo, roleRepo, serviceAccountRepo, permissionCache, log)
+	authService := service.NewMultiTenantAuthService(
+		userRepo,
+		userTenantRepo,
+		tenantLoginConfigRepo,
+		refreshTokenRepo,
+		roleRepo,
+		loginAttemptRepo,
+		userLockoutRepo,
+		userMFARepo,
+		passwordResetRepo,
+		verificationRepo,
+		oauthAccountRepo,
+		oidcClientRepo,
+		serviceAccountRepo,
+		apiKeyRepo,
+		webauthnCredentialRepo,
+		passwordlessLoginRepo,
+		notifier,
+		publisher,
+		permissionService,
+		oauth.LoadProvidersFromEnv(),
+		oidcProvider,
+		jwtManager,
+		tokenHasher,
+		sessionStore,
+		log,
+	)
 
 	// Start gRPC server
 	grpcPort := os.Getenv("AUTH_SERVICE_PORT")
 	if grpcPort == "" {
 		grpcPort = "50051"
 	}
-	go startGRPCServer(authService, log, grpcPort, cfg) // Pass cfg for TLS paths
+	// Client addresses are read from X-Forwarded-For only when it was set by a trusted proxy,
+	// e.g. the API gateway
+	trustedProxies, err := grpc.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES", zap.Error(err))
+	}
+	authGrpcServer := grpc.NewMultiTenantAuthServer(authService, permissionService, trustedProxies, log)
+	go startGRPCServer(authGrpcServer, log, grpcPort, cfg) // Pass cfg for TLS paths
+
+	// The REST API is transcoded from the gRPC service
+	restHandler, err := grpc.NewRESTHandler(context.Background(), authGrpcServer)
+	if err != nil {
+		log.Fatal("Failed to initialize REST API", zap.Error(err))
+	}
 
 	// Start HTTP server
 	httpPort := os.Getenv("AUTH_SERVICE_HTTP_PORT")
 	if httpPort == "" {
 		httpPort = "8081"
 	}
-	startHTTPServer(authService, log, httpPort)
+	startHTTPServer(
+		restHandler,
+		handler.NewOIDCHandler(authService, oidcProvider, log),
+		oidcProvider.Issuer,
+		log,
+		httpPort,
+	)
 }
 
-func startGRPCServer(authService *service.AuthService, log *logger.Logger, port string, cfg *config.Config) {
+func startGRPCServer(authGrpcServer *grpc.MultiTenantAuthServer, log *logger.Logger, port string, cfg *config.Config) {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
 		log.Fatal("Failed to listen", zap.Error(err))
@@ -118,9 +237,7 @@ func startGRPCServer(authService *service.AuthService, log *logger.Logger, port
 	}
 
 	grpcSrv := grpcServer.NewServer(opts...)
-	authGrpcServer := grpc.NewAuthServiceServer(authService, log)
-	// pb.RegisterAuthServiceServer(grpcSrv, authGrpcServer)
-	_ = authGrpcServer // Use the variable to avoid unused error
+	pb.RegisterAuthServiceServer(grpcSrv, authGrpcServer)
 
 	// Register health check service
 	healthServer := health.NewServer()
@@ -133,14 +250,11 @@ func startGRPCServer(authService *service.AuthService, log *logger.Logger, port
 	}
 }
 
-func startHTTPServer(authService *service.AuthService, log *logger.Logger, port string) {
+func startHTTPServer(restHandler http.Handler, oidcHandler *handler.OIDCHandler, issuer string, log *logger.Logger, port string) {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 	router.Use(gin.Recovery())
 
-	// Initialize handlers
-	authHandler := handler.NewAuthHandler(authService, log)
-
 	// Health check endpoints
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
@@ -149,17 +263,18 @@ func startHTTPServer(authService *service.AuthService, log *logger.Logger, port
 		c.JSON(http.StatusOK, gin.H{"status": "ready"})
 	})
 
-	// API routes
-	v1 := router.Group("/api/v1")
-	{
-		auth := v1.Group("/auth")
-		{
-			auth.POST("/register", authHandler.Register)
-			auth.POST("/login", authHandler.Login)
-			auth.POST("/logout", authHandler.Logout)
-			auth.POST("/refresh", authHandler.RefreshToken)
-		}
+	// API routes, at the paths of the google.api.http options in auth.proto
+	router.Any("/api/v1/auth/*path", gin.WrapH(restHandler))
+	router.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", openapi.Spec)
+	})
+
+	// OpenID Connect endpoints live under the path of the issuer URL
+	issuerURL, err := url.Parse(issuer)
+	if err != nil {
+		log.Fatal("Invalid OIDC issuer", zap.String("issuer", issuer), zap.Error(err))
 	}
+	oidcHandler.RegisterRoutes(router.Group(issuerURL.Path))
 
 	// Configure HTTP server with timeouts for better performance and resource management
 	srv := &http.Server{
@@ -8,20 +8,23 @@ import (
 
 // User represents the authentication data for a user
 type User struct {
-	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
-	Email        string              `bson:"email,omitempty" json:"email,omitempty"`
-	Username     string              `bson:"username,omitempty" json:"username,omitempty"`
-	Phone        string              `bson:"phone,omitempty" json:"phone,omitempty"`
-	DocNumber    string              `bson:"docNumber,omitempty" json:"doc_number,omitempty"`
-	PasswordHash string              `bson:"passwordHash" json:"-"`
-	Tenants      []string            `bson:"tenants" json:"tenants"`
-	Roles        []string            `bson:"roles" json:"roles"`              // Global roles? Usually roles are per tenant.
-	TenantRoles  map[string][]string `bson:"tenantRoles" json:"tenant_roles"` // tenantId -> roles
-	IsActive     bool                `bson:"isActive" json:"is_active"`
-	IsVerified   bool                `bson:"isVerified" json:"is_verified"`
-	LastLoginAt  *time.Time          `bson:"lastLoginAt,omitempty" json:"last_login_at,omitempty"`
-	CreatedAt    time.Time           `bson:"createdAt" json:"created_at"`
-	UpdatedAt    time.Time           `bson:"updatedAt" json:"updated_at"`
+	ID                primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Email             string              `bson:"email,omitempty" json:"email,omitempty"`
+	Username          string              `bson:"username,omitempty" json:"username,omitempty"`
+	Phone             string              `bson:"phone,omitempty" json:"phone,omitempty"`
+	DocNumber         string              `bson:"docNumber,omitempty" json:"doc_number,omitempty"`
+	PasswordHash      string              `bson:"passwordHash" json:"-"`
+	Tenants           []string            `bson:"tenants" json:"tenants"`
+	Roles             []string            `bson:"roles" json:"roles"`              // Global roles? Usually roles are per tenant.
+	TenantRoles       map[string][]string `bson:"tenantRoles" json:"tenant_roles"` // tenantId -> roles
+	IsActive          bool                `bson:"isActive" json:"is_active"`
+	IsVerified        bool                `bson:"isVerified" json:"is_verified"` // At least one identifier is verified
+	EmailVerified     bool                `bson:"emailVerified" json:"email_verified"`
+	PhoneVerified     bool                `bson:"phoneVerified" json:"phone_verified"`
+	LastLoginAt       *time.Time          `bson:"lastLoginAt,omitempty" json:"last_login_at,omitempty"`
+	PasswordChangedAt *time.Time          `bson:"passwordChangedAt,omitempty" json:"password_changed_at,omitempty"`
+	CreatedAt         time.Time           `bson:"createdAt" json:"created_at"`
+	UpdatedAt         time.Time           `bson:"updatedAt" json:"updated_at"`
 }
 
 // Tenant represents a tenant's configuration
@@ -34,15 +37,67 @@ type Tenant struct {
 	UpdatedAt    time.Time `bson:"updatedAt" json:"updated_at"`
 }
 
-// RefreshToken represents a refresh token
+// RefreshToken represents a refresh token.
+// Each refresh replaces the token with a child in the same family; presenting a
+// replaced token again revokes the whole family.
 type RefreshToken struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	UserID    string             `bson:"userId" json:"user_id"`
-	Token     string             `bson:"token" json:"token"`
+	TokenHash string             `bson:"tokenHash" json:"-"` // HMAC-SHA256 of the token; the token itself is never stored
 	TenantID  string             `bson:"tenantId" json:"tenant_id"`
+	FamilyID  string             `bson:"familyId" json:"family_id"`                     // ID of the first token issued at login
+	ParentID  string             `bson:"parentId,omitempty" json:"parent_id,omitempty"` // Token this one replaced
 	ExpiresAt time.Time          `bson:"expiresAt" json:"expires_at"`
 	CreatedAt time.Time          `bson:"createdAt" json:"created_at"`
-	RevokedAt *time.Time         `bson:"revokedAt,omitempty" json:"revoked_at,omitempty"`
+	RotatedAt *time.Time         `bson:"rotatedAt,omitempty" json:"rotated_at,omitempty"` // Set when the token was exchanged
+	// End of the login; children never expire after it. Zero when the tenant has no maximum session age.
+	AbsoluteExpiresAt time.Time  `bson:"absoluteExpiresAt,omitempty" json:"absolute_expires_at,omitempty"`
+	RevokedAt         *time.Time `bson:"revokedAt,omitempty" json:"revoked_at,omitempty"`
+}
+
+// PasswordResetToken represents a single-use password reset token.
+// Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    string             `bson:"userId" json:"user_id"`
+	TenantID  string             `bson:"tenantId" json:"tenant_id"`
+	TokenHash string             `bson:"tokenHash" json:"-"`
+	IPAddress string             `bson:"ipAddress,omitempty" json:"ip_address,omitempty"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expires_at"`
+	CreatedAt time.Time          `bson:"createdAt" json:"created_at"`
+	UsedAt    *time.Time         `bson:"usedAt,omitempty" json:"used_at,omitempty"`
+}
+
+// IdentifierVerification is a pending verification of a user's email or phone.
+// The code is for manual entry, the link token for verification links; only their hashes are stored.
+type IdentifierVerification struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         string             `bson:"userId" json:"user_id"`
+	TenantID       string             `bson:"tenantId" json:"tenant_id"`
+	IdentifierType IdentifierType     `bson:"identifierType" json:"identifier_type"`
+	Identifier     string             `bson:"identifier" json:"identifier"`
+	CodeHash       string             `bson:"codeHash" json:"-"`
+	LinkTokenHash  string             `bson:"linkTokenHash" json:"-"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	ExpiresAt      time.Time          `bson:"expiresAt" json:"expires_at"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"created_at"`
+	UsedAt         *time.Time         `bson:"usedAt,omitempty" json:"used_at,omitempty"`
+}
+
+// PasswordlessLogin is a pending login with a one-time code or link sent to a user's email or phone.
+// Like verifications, only the hashes of the code and link token are stored.
+type PasswordlessLogin struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         string             `bson:"userId" json:"user_id"`
+	TenantID       string             `bson:"tenantId" json:"tenant_id"`
+	IdentifierType IdentifierType     `bson:"identifierType" json:"identifier_type"`
+	Identifier     string             `bson:"identifier" json:"identifier"`
+	CodeHash       string             `bson:"codeHash" json:"-"`
+	LinkTokenHash  string             `bson:"linkTokenHash" json:"-"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	ExpiresAt      time.Time          `bson:"expiresAt" json:"expires_at"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"created_at"`
+	UsedAt         *time.Time         `bson:"usedAt,omitempty" json:"used_at,omitempty"`
 }
 
 // Role represents a role in the system
@@ -69,12 +124,74 @@ type Permission struct {
 
 // Session represents a user session stored in Redis
 type Session struct {
-	UserID    string    `json:"user_id"`
-	TenantID  string    `json:"tenant_id"`
-	Email     string    `json:"email"`
-	Roles     []string  `json:"roles"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID                string        `json:"id"` // Shared by the access tokens of one login, including refreshed ones
+	UserID            string        `json:"user_id"`
+	TenantID          string        `json:"tenant_id"`
+	Email             string        `json:"email"`
+	Roles             []string      `json:"roles"`
+	RefreshTokenID    string        `json:"refresh_token_id,omitempty"` // Refresh token issued with this session
+	RefreshFamily     string        `json:"refresh_family,omitempty"`   // Family of that refresh token
+	ClientID          string        `json:"client_id,omitempty"`        // OIDC client or service account the token was issued to
+	ServiceAccount    bool          `json:"service_account,omitempty"`  // UserID is a service account
+	Scope             string        `json:"scope,omitempty"`            // Scopes granted to the OIDC client
+	CreatedAt         time.Time     `json:"created_at"`
+	LastSeenAt        time.Time     `json:"last_seen_at"`
+	ExpiresAt         time.Time     `json:"expires_at"`
+	IdleTimeout       time.Duration `json:"idle_timeout,omitempty"`        // The session ends when unused this long
+	AbsoluteExpiresAt time.Time     `json:"absolute_expires_at,omitempty"` // End of the login, whatever the activity
+}
+
+// SessionInfo describes an active login of a user, as listed by ListSessions
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	TenantID   string    `json:"tenant_id"`
+	ClientID   string    `json:"client_id,omitempty"`
+	Device     string    `json:"device,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // Session of the access token making the request
+}
+
+// ClientInfo describes the device a request comes from. It is recorded on the sessions the request creates.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+	Device    string // Name given by the client, or derived from the user agent
+}
+
+// UserMFA holds a user's TOTP second factor
+type UserMFA struct {
+	ID                 primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID             string             `bson:"userId" json:"user_id"`
+	TOTPSecret         string             `bson:"totpSecret" json:"-"`
+	Enabled            bool               `bson:"enabled" json:"enabled"`
+	RecoveryCodeHashes []string           `bson:"recoveryCodeHashes" json:"-"`
+	LastUsedStep       int64              `bson:"lastUsedStep" json:"-"` // Last accepted TOTP time step, rejects replays
+	EnabledAt          *time.Time         `bson:"enabledAt,omitempty" json:"enabled_at,omitempty"`
+	CreatedAt          time.Time          `bson:"createdAt" json:"created_at"`
+	UpdatedAt          time.Time          `bson:"updatedAt" json:"updated_at"`
+}
+
+// MFAChallenge represents a login waiting for its second factor, stored in Redis.
+// The identifier and IP address of the first factor count wrong codes toward their lockout.
+type MFAChallenge struct {
+	UserID     string    `json:"user_id"`
+	TenantID   string    `json:"tenant_id"`
+	Identifier string    `json:"identifier,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// TOTPEnrollment is returned when a user starts or confirms TOTP enrollment
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret,omitempty"`
+	OTPAuthURI    string   `json:"otpauth_uri,omitempty"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	Enabled       bool     `json:"enabled"`
 }
 
 // OAuthProvider represents OAuth provider types
@@ -96,13 +213,148 @@ type OAuthAccount struct {
 	UpdatedAt  time.Time          `bson:"updatedAt" json:"updated_at"`
 }
 
-// LoginResponse represents a successful login response
+// OAuthState is the pending authorization request of a social login or link, stored in Redis under its state value
+type OAuthState struct {
+	Provider     OAuthProvider `json:"provider"`
+	TenantID     string        `json:"tenant_id"`
+	Nonce        string        `json:"nonce"`
+	CodeVerifier string        `json:"code_verifier"`
+	LinkUserID   string        `json:"link_user_id,omitempty"` // Set when linking to a signed-in user instead of logging in
+	CreatedAt    time.Time     `json:"created_at"`
+	ExpiresAt    time.Time     `json:"expires_at"`
+}
+
+// OAuthAuthorization is returned when a social login or link starts
+type OAuthAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+// OIDCClient is an application registered by a tenant to sign its users in through the OpenID Connect provider
+type OIDCClient struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID         string             `bson:"tenantId" json:"tenant_id"`
+	ClientID         string             `bson:"clientId" json:"client_id"`
+	ClientSecretHash string             `bson:"clientSecretHash,omitempty" json:"-"`
+	Name             string             `bson:"name" json:"name"`
+	RedirectURIs     []string           `bson:"redirectUris" json:"redirect_uris"`
+	Scopes           []string           `bson:"scopes" json:"scopes"`
+	Public           bool               `bson:"public" json:"public"` // No secret, PKCE only (browser and mobile apps)
+	IsActive         bool               `bson:"isActive" json:"is_active"`
+	CreatedAt        time.Time          `bson:"createdAt" json:"created_at"`
+	UpdatedAt        time.Time          `bson:"updatedAt" json:"updated_at"`
+}
+
+// ServiceAccount is a non-human identity of a tenant for batch jobs and services. It signs in with
+// its client credentials (the client_credentials grant) and gets permissions through its roles like a user.
+type ServiceAccount struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID         string             `bson:"tenantId" json:"tenant_id"`
+	Name             string             `bson:"name" json:"name"`
+	Description      string             `bson:"description,omitempty" json:"description,omitempty"`
+	ClientID         string             `bson:"clientId" json:"client_id"`
+	ClientSecretHash string             `bson:"clientSecretHash" json:"-"`
+	Roles            []string           `bson:"roles" json:"roles"`
+	IsActive         bool               `bson:"isActive" json:"is_active"`
+	LastUsedAt       *time.Time         `bson:"lastUsedAt,omitempty" json:"last_used_at,omitempty"` // Last token issued
+	CreatedAt        time.Time          `bson:"createdAt" json:"created_at"`
+	UpdatedAt        time.Time          `bson:"updatedAt" json:"updated_at"`
+}
+
+// APIKey is a long-lived personal key of a user in a tenant, for scripts. It is limited to a subset
+// of the user's permissions; only its hash is stored.
+type APIKey struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      string             `bson:"userId" json:"user_id"`
+	TenantID    string             `bson:"tenantId" json:"tenant_id"`
+	Name        string             `bson:"name" json:"name"`
+	Prefix      string             `bson:"prefix" json:"prefix"` // Start of the key, to recognise it in listings
+	KeyHash     string             `bson:"keyHash" json:"-"`
+	Permissions []string           `bson:"permissions" json:"permissions"`
+	ExpiresAt   *time.Time         `bson:"expiresAt,omitempty" json:"expires_at,omitempty"` // Never expires when nil
+	LastUsedAt  *time.Time         `bson:"lastUsedAt,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time         `bson:"revokedAt,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"created_at"`
+}
+
+// WebAuthnCredential is a passkey registered by a user. It is bound to the relying party ID it was created for.
+type WebAuthnCredential struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       string             `bson:"userId" json:"user_id"`
+	RPID         string             `bson:"rpId" json:"rp_id"`
+	CredentialID string             `bson:"credentialId" json:"credential_id"` // base64url
+	PublicKey    []byte             `bson:"publicKey" json:"-"`                // COSE_Key
+	SignCount    uint32             `bson:"signCount" json:"-"`
+	Transports   []string           `bson:"transports,omitempty" json:"transports,omitempty"`
+	Name         string             `bson:"name" json:"name"`
+	LastUsedAt   *time.Time         `bson:"lastUsedAt,omitempty" json:"last_used_at,omitempty"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"created_at"`
+}
+
+// WebAuthnChallenge is a pending passkey registration or login, stored in Redis under its ceremony token
+type WebAuthnChallenge struct {
+	Challenge []byte    `json:"challenge"`
+	UserID    string    `json:"user_id,omitempty"` // Set for registrations
+	TenantID  string    `json:"tenant_id"`
+	RPID      string    `json:"rp_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PasskeyCeremony is returned when a passkey registration or login starts. Options are passed to
+// navigator.credentials.create() or get(), and the token identifies the ceremony when it finishes.
+type PasskeyCeremony struct {
+	Token   string      `json:"token"`
+	Options interface{} `json:"options"`
+}
+
+// OIDCAuthorizationCode is an issued authorization code, stored in Redis until it is redeemed
+type OIDCAuthorizationCode struct {
+	ClientID      string    `json:"client_id"`
+	TenantID      string    `json:"tenant_id"`
+	UserID        string    `json:"user_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	Scope         string    `json:"scope"`
+	Nonce         string    `json:"nonce,omitempty"`
+	CodeChallenge string    `json:"code_challenge"`
+	AuthTime      time.Time `json:"auth_time"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// OIDCTokenResponse is the token endpoint response
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token,omitempty"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// TokenIntrospectionResponse describes a token to a resource server (RFC 7662).
+// An inactive token only has Active set.
+type TokenIntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Tenant    string `json:"tenant,omitempty"`
+}
+
+// LoginResponse represents a successful login response.
+// When a second factor is required, only MFAToken is set and the session is issued by VerifyMFA.
 type LoginResponse struct {
-	AccessToken  string   `json:"access_token"`
-	RefreshToken string   `json:"refresh_token"`
-	TokenType    string   `json:"token_type"`
-	ExpiresIn    int64    `json:"expires_in"`
-	User         UserInfo `json:"user"`
+	AccessToken           string   `json:"access_token"`
+	RefreshToken          string   `json:"refresh_token"`
+	TokenType             string   `json:"token_type"`
+	ExpiresIn             int64    `json:"expires_in"`
+	User                  UserInfo `json:"user"`
+	MFARequired           bool     `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool     `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string   `json:"mfa_token,omitempty"`
+	RecoveryCodes         []string `json:"recovery_codes,omitempty"`
 }
 
 // UserInfo represents brief user information in login response
@@ -35,14 +35,14 @@ func TestRefreshToken_Structure(t *testing.T) {
 	token := &RefreshToken{
 		ID:        primitive.NewObjectID(),
 		UserID:    "507f1f77bcf86cd799439011",
-		Token:     "refresh-token-string",
+		TokenHash: "refresh-token-hash",
 		ExpiresAt: now.Add(7 * 24 * time.Hour),
 		CreatedAt: now,
 	}
 
 	assert.NotEmpty(t, token.ID)
 	assert.Equal(t, "507f1f77bcf86cd799439011", token.UserID)
-	assert.Equal(t, "refresh-token-string", token.Token)
+	assert.Equal(t, "refresh-token-hash", token.TokenHash)
 	assert.True(t, token.ExpiresAt.After(now))
 }
 
@@ -6,6 +6,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// SystemTenantID is the tenant whose administrators may manage every tenant
+const SystemTenantID = "system"
+
 // UserTenant represents the relationship between a user and a tenant
 type UserTenant struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
@@ -20,26 +23,44 @@ type UserTenant struct {
 
 // TenantLoginConfig represents login configuration for a tenant
 type TenantLoginConfig struct {
-	ID                   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	TenantID             string             `bson:"tenantId" json:"tenant_id"`
-	AllowedIdentifiers   []string           `bson:"allowedIdentifiers" json:"allowed_identifiers"` // ["email", "phone", "username", "document_number"]
-	Require2FA           bool               `bson:"require2FA" json:"require_2fa"`
-	AllowRegistration    bool               `bson:"allowRegistration" json:"allow_registration"`
-	CustomLogoURL        string             `bson:"customLogoUrl,omitempty" json:"custom_logo_url,omitempty"`
-	CustomBackgroundURL  string             `bson:"customBackgroundUrl,omitempty" json:"custom_background_url,omitempty"`
-	CustomFields         map[string]string  `bson:"customFields,omitempty" json:"custom_fields,omitempty"`
-	PasswordMinLength    int                `bson:"passwordMinLength" json:"password_min_length"`
-	PasswordRequireUpper bool               `bson:"passwordRequireUpper" json:"password_require_upper"`
-	PasswordRequireLower bool               `bson:"passwordRequireLower" json:"password_require_lower"`
-	PasswordRequireDigit bool               `bson:"passwordRequireDigit" json:"password_require_digit"`
-	PasswordRequireSpec  bool               `bson:"passwordRequireSpec" json:"password_require_spec"`
-	SessionTimeout       int                `bson:"sessionTimeout" json:"session_timeout"` // in minutes
-	MaxLoginAttempts     int                `bson:"maxLoginAttempts" json:"max_login_attempts"`
-	LockoutDuration      int                `bson:"lockoutDuration" json:"lockout_duration"` // in minutes
-	CreatedAt            time.Time          `bson:"createdAt" json:"created_at"`
-	UpdatedAt            time.Time          `bson:"updatedAt" json:"updated_at"`
+	ID                        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID                  string             `bson:"tenantId" json:"tenant_id"`
+	AllowedIdentifiers        []string           `bson:"allowedIdentifiers" json:"allowed_identifiers"` // ["email", "phone", "username", "document_number", "passkey"]
+	Require2FA                bool               `bson:"require2FA" json:"require_2fa"`
+	AllowRegistration         bool               `bson:"allowRegistration" json:"allow_registration"`
+	CustomLogoURL             string             `bson:"customLogoUrl,omitempty" json:"custom_logo_url,omitempty"`
+	CustomBackgroundURL       string             `bson:"customBackgroundUrl,omitempty" json:"custom_background_url,omitempty"`
+	CustomFields              map[string]string  `bson:"customFields,omitempty" json:"custom_fields,omitempty"`
+	PasswordMinLength         int                `bson:"passwordMinLength" json:"password_min_length"`
+	PasswordRequireUpper      bool               `bson:"passwordRequireUpper" json:"password_require_upper"`
+	PasswordRequireLower      bool               `bson:"passwordRequireLower" json:"password_require_lower"`
+	PasswordRequireDigit      bool               `bson:"passwordRequireDigit" json:"password_require_digit"`
+	PasswordRequireSpec       bool               `bson:"passwordRequireSpec" json:"password_require_spec"`
+	SessionTimeout            int                `bson:"sessionTimeout" json:"session_timeout"`                // Access token lifetime, in minutes
+	RefreshTokenLifetime      int                `bson:"refreshTokenLifetime" json:"refresh_token_lifetime"`   // in minutes
+	IdleTimeout               int                `bson:"idleTimeout" json:"idle_timeout"`                      // Sessions unused this long end, in minutes; 0 disables
+	MaxSessionAge             int                `bson:"maxSessionAge" json:"max_session_age"`                 // Refreshing cannot extend a login past this age, in minutes; 0 disables
+	MaxConcurrentSessions     int                `bson:"maxConcurrentSessions" json:"max_concurrent_sessions"` // Per user; 0 is unlimited
+	SessionLimitPolicy        SessionLimitPolicy `bson:"sessionLimitPolicy,omitempty" json:"session_limit_policy,omitempty"`
+	MaxLoginAttempts          int                `bson:"maxLoginAttempts" json:"max_login_attempts"`
+	LockoutDuration           int                `bson:"lockoutDuration" json:"lockout_duration"`                      // in minutes
+	RequireVerifiedIdentifier bool               `bson:"requireVerifiedIdentifier" json:"require_verified_identifier"` // Block login until the email or phone used is verified
+	PasswordlessLogin         bool               `bson:"passwordlessLogin" json:"passwordless_login"`                  // Allow login with a one-time code or link sent to the email or phone
+	WebAuthnRPID              string             `bson:"webauthnRpId,omitempty" json:"webauthn_rp_id,omitempty"`       // Domain passkeys are bound to; required for passkey login
+	WebAuthnRPName            string             `bson:"webauthnRpName,omitempty" json:"webauthn_rp_name,omitempty"`   // Shown by the browser when a passkey is created
+	WebAuthnOrigins           []string           `bson:"webauthnOrigins,omitempty" json:"webauthn_origins,omitempty"`  // Origins passkey ceremonies may run on
+	CreatedAt                 time.Time          `bson:"createdAt" json:"created_at"`
+	UpdatedAt                 time.Time          `bson:"updatedAt" json:"updated_at"`
 }
 
+// SessionLimitPolicy decides what happens to a login beyond a tenant's MaxConcurrentSessions
+type SessionLimitPolicy string
+
+const (
+	SessionLimitReject      SessionLimitPolicy = "reject"       // The new login fails
+	SessionLimitEvictOldest SessionLimitPolicy = "evict_oldest" // The oldest session ends, including its refresh token
+)
+
 // IdentifierType represents the type of identifier used for login
 type IdentifierType string
 
@@ -48,6 +69,7 @@ const (
 	IdentifierTypeUsername       IdentifierType = "username"
 	IdentifierTypePhone          IdentifierType = "phone"
 	IdentifierTypeDocumentNumber IdentifierType = "document_number"
+	IdentifierTypePasskey        IdentifierType = "passkey" // Passwordless login with a WebAuthn credential
 )
 
 // ValidIdentifierTypes returns all valid identifier types
@@ -57,6 +79,7 @@ func ValidIdentifierTypes() []IdentifierType {
 		IdentifierTypeUsername,
 		IdentifierTypePhone,
 		IdentifierTypeDocumentNumber,
+		IdentifierTypePasskey,
 	}
 }
 
@@ -97,10 +120,13 @@ type LoginAttempt struct {
 	AttemptAt  time.Time          `bson:"attemptAt" json:"attempt_at"`
 }
 
-// UserLockout tracks user lockouts due to failed login attempts
+// UserLockout tracks user lockouts due to failed login attempts.
+// A lockout applies either to an identifier or to an IP address within a tenant.
 type UserLockout struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID     string             `bson:"userId" json:"user_id"`
+	UserID     string             `bson:"userId,omitempty" json:"user_id,omitempty"`
+	Identifier string             `bson:"identifier,omitempty" json:"identifier,omitempty"`
+	IPAddress  string             `bson:"ipAddress,omitempty" json:"ip_address,omitempty"`
 	TenantID   string             `bson:"tenantId" json:"tenant_id"`
 	LockedAt   time.Time          `bson:"lockedAt" json:"locked_at"`
 	UnlockAt   time.Time          `bson:"unlockAt" json:"unlock_at"`
@@ -1,5 +1,7 @@
 package domain
 
+import "encoding/json"
+
 // RegisterRequest represents a user registration request
 type RegisterRequest struct {
 	Email     string `json:"email" binding:"required,email"`
@@ -23,13 +25,34 @@ type RefreshTokenRequest struct {
 
 // ChangePasswordRequest represents a password change request
 type ChangePasswordRequest struct {
-	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=8"`
+	OldPassword         string `json:"old_password" binding:"required"`
+	NewPassword         string `json:"new_password" binding:"required,min=8"`
+	RevokeOtherSessions bool   `json:"revoke_other_sessions"`
 }
 
 // ResetPasswordRequest represents a password reset request
 type ResetPasswordRequest struct {
-	Email string `json:"email" binding:"required,email"`
+	Email    string `json:"email" binding:"required,email"`
+	TenantID string `json:"tenant_id" binding:"required"`
+}
+
+// CompletePasswordResetRequest sets a new password using a reset token
+type CompletePasswordResetRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// SendVerificationRequest asks for a verification code for an email address or phone number
+type SendVerificationRequest struct {
+	Identifier string `json:"identifier" binding:"required"`
+	TenantID   string `json:"tenant_id" binding:"required"`
+}
+
+// VerifyIdentifierRequest confirms an email address or phone number with a code or a link token
+type VerifyIdentifierRequest struct {
+	Identifier string `json:"identifier"`
+	Code       string `json:"code"`
+	Token      string `json:"token"`
 }
 
 // OAuthCallbackRequest represents OAuth callback data
@@ -38,3 +61,107 @@ type OAuthCallbackRequest struct {
 	State    string `json:"state" binding:"required"`
 	Provider string `json:"provider" binding:"required"`
 }
+
+// StartOAuthLinkRequest starts linking a provider account to the signed-in user
+type StartOAuthLinkRequest struct {
+	Provider string `json:"provider" binding:"required"`
+}
+
+// OIDCAuthorizeRequest is an OpenID Connect authorization request
+type OIDCAuthorizeRequest struct {
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	ResponseType        string `form:"response_type"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Prompt              string `form:"prompt"`
+}
+
+// OIDCTokenRequest is a token endpoint request
+type OIDCTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// CreateAPIKeyRequest creates a personal API key for the signed-in user
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Permissions   []string `json:"permissions" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 never expires
+}
+
+// FinishPasskeyRegistrationRequest completes a passkey registration with the browser's credential
+type FinishPasskeyRegistrationRequest struct {
+	Token      string          `json:"token" binding:"required"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential" binding:"required"` // PublicKeyCredential.toJSON()
+}
+
+// StartPasswordlessLoginRequest sends a one-time login code and link to an email or phone
+type StartPasswordlessLoginRequest struct {
+	Identifier string `json:"identifier" binding:"required"`
+	TenantID   string `json:"tenant_id" binding:"required"`
+}
+
+// CompletePasswordlessLoginRequest signs in with the code sent to an identifier, or with the link token
+type CompletePasswordlessLoginRequest struct {
+	Identifier string `json:"identifier"`
+	Code       string `json:"code"`
+	Token      string `json:"token"`
+	TenantID   string `json:"tenant_id" binding:"required"`
+}
+
+// BeginPasskeyLoginRequest starts a passkey login to a tenant
+type BeginPasskeyLoginRequest struct {
+	TenantID string `json:"tenant_id" binding:"required"`
+}
+
+// FinishPasskeyLoginRequest completes a passkey login with the browser's assertion
+type FinishPasskeyLoginRequest struct {
+	Token      string          `json:"token" binding:"required"`
+	TenantID   string          `json:"tenant_id" binding:"required"`
+	Credential json.RawMessage `json:"credential" binding:"required"` // PublicKeyCredential.toJSON()
+}
+
+// CreateServiceAccountRequest creates a service account in a tenant
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+}
+
+// TokenIntrospectionRequest asks whether a token is active (RFC 7662)
+type TokenIntrospectionRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// TokenRevocationRequest revokes an access or refresh token (RFC 7009)
+type TokenRevocationRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// RegisterOIDCClientRequest registers an application with the OpenID Connect provider
+type RegisterOIDCClientRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1"`
+	Scopes       []string `json:"scopes"`
+	Public       bool     `json:"public"`
+}
+
+// RevokeAllSessionsRequest signs the caller out everywhere, optionally except the current session
+type RevokeAllSessionsRequest struct {
+	KeepCurrent bool `json:"keep_current"`
+}
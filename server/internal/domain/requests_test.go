@@ -102,6 +102,16 @@ func TestResetPasswordRequest_Structure(t *testing.T) {
 	assert.NotEmpty(t, req.Email)
 }
 
+func TestCompletePasswordResetRequest_Structure(t *testing.T) {
+	req := &CompletePasswordResetRequest{
+		Token:       "reset_token_123",
+		NewPassword: "NewPassword456!",
+	}
+
+	assert.Equal(t, "reset_token_123", req.Token)
+	assert.Equal(t, "NewPassword456!", req.NewPassword)
+}
+
 func TestOAuthCallbackRequest_Structure(t *testing.T) {
 	req := &OAuthCallbackRequest{
 		Code:     "authorization_code_123",
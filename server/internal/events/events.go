@@ -0,0 +1,164 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/vhvplatform/go-shared/config"
+)
+
+// Event types published by the auth service
+const (
+	// SessionRevoked: the tokens of one login (SessionID), or of one API key, no longer work
+	SessionRevoked = "session_revoked"
+	// UserRevoked: no token of the user works any more in the tenant, or in any tenant when TenantID is empty
+	UserRevoked = "user_revoked"
+	// RolesChanged: the roles or permissions of the user changed in the tenant
+	RolesChanged = "roles_changed"
+)
+
+// Resubscribed is passed to subscribers and never published: the subscription was restored after
+// the connection dropped, so events published in between were missed
+const Resubscribed = "resubscribed"
+
+// DefaultChannel is the Redis channel events are published on
+const DefaultChannel = "auth:events"
+
+// Event tells the services that cache token validations which entries are stale
+type Event struct {
+	Type      string    `json:"type"`
+	UserID    string    `json:"user_id,omitempty"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Publisher sends events to every subscriber
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// Subscriber calls a handler for every event until the context is done
+type Subscriber interface {
+	Subscribe(ctx context.Context, handler func(*Event)) error
+}
+
+// LocalBus delivers events within the process. It stands in for Redis in tests and when the
+// auth service and its subscribers run in one process.
+type LocalBus struct {
+	mu       sync.RWMutex
+	handlers map[int]func(*Event)
+	nextID   int
+}
+
+// NewLocalBus creates an in-process event bus
+func NewLocalBus() *LocalBus {
+	return &LocalBus{handlers: make(map[int]func(*Event))}
+}
+
+// Publish calls every subscribed handler
+func (b *LocalBus) Publish(ctx context.Context, event *Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+	return nil
+}
+
+// Subscribe registers the handler until the context is done
+func (b *LocalBus) Subscribe(ctx context.Context, handler func(*Event)) error {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.handlers, id)
+	b.mu.Unlock()
+	return nil
+}
+
+// RedisBus delivers events over Redis pub/sub. Subscribers only receive the events published
+// while they are connected.
+type RedisBus struct {
+	client  *goredis.Client
+	channel string
+}
+
+// NewRedisBus creates an event bus on a Redis channel
+func NewRedisBus(client *goredis.Client, channel string) *RedisBus {
+	return &RedisBus{client: client, channel: channel}
+}
+
+// DialRedis creates an event bus on DefaultChannel of the configured Redis server.
+// The connection is made when the bus is first used.
+func DialRedis(cfg config.RedisConfig) *RedisBus {
+	client := goredis.NewClient(&goredis.Options{
+		Addr:     cfg.GetRedisAddr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return NewRedisBus(client, DefaultChannel)
+}
+
+// Close closes the connection to Redis
+func (b *RedisBus) Close() error {
+	return b.client.Close()
+}
+
+// Publish sends the event as JSON to the channel
+func (b *RedisBus) Publish(ctx context.Context, event *Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if err := b.client.Publish(ctx, b.channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// Subscribe listens on the channel until the context is done. It returns once the subscription
+// fails to start, and reconnects by itself when the connection drops later; the handler then gets
+// a Resubscribed event.
+func (b *RedisBus) Subscribe(ctx context.Context, handler func(*Event)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", b.channel, err)
+	}
+
+	// go-redis subscribes again after reconnecting, and reports it with a Subscription message
+	messages := pubsub.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			switch msg := msg.(type) {
+			case *goredis.Subscription:
+				if msg.Kind == "subscribe" {
+					handler(&Event{Type: Resubscribed, Time: time.Now()})
+				}
+			case *goredis.Message:
+				var event Event
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					continue
+				}
+				handler(&event)
+			}
+		}
+	}
+}
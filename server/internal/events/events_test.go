@@ -0,0 +1,149 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalBus(t *testing.T) {
+	bus := NewLocalBus()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	received := make(chan *Event, 1)
+	done := make(chan struct{})
+	go func() {
+		_ = bus.Subscribe(ctx, func(event *Event) { received <- event })
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		bus.mu.RLock()
+		defer bus.mu.RUnlock()
+		return len(bus.handlers) == 1
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(t, bus.Publish(ctx, &Event{Type: UserRevoked, UserID: "user-1"}))
+	event := <-received
+	assert.Equal(t, UserRevoked, event.Type)
+	assert.Equal(t, "user-1", event.UserID)
+
+	cancel()
+	<-done
+	assert.Empty(t, bus.handlers)
+}
+
+// fakePubSubServer speaks just enough of the Redis protocol for a subscriber. Each connection is
+// handed to the test once it has subscribed, to publish on or to drop.
+type fakePubSubServer struct {
+	listener    net.Listener
+	subscribers chan net.Conn
+}
+
+func newFakePubSubServer(t *testing.T) *fakePubSubServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	server := &fakePubSubServer{listener: listener, subscribers: make(chan net.Conn, 4)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakePubSubServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		command, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(command[0]) {
+		case "HELLO":
+			fmt.Fprint(conn, "-ERR unknown command\r\n")
+		case "SUBSCRIBE":
+			fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(command[1]), command[1])
+			s.subscribers <- conn
+		case "PING":
+			fmt.Fprint(conn, "*2\r\n$4\r\npong\r\n$0\r\n\r\n")
+		default:
+			fmt.Fprint(conn, "+OK\r\n")
+		}
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	command := make([]string, count)
+	for i := range command {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		command[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return command, nil
+}
+
+func publishTo(conn net.Conn, payload string) {
+	fmt.Fprintf(conn, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(DefaultChannel), DefaultChannel, len(payload), payload)
+}
+
+func TestRedisBus_Resubscribed(t *testing.T) {
+	server := newFakePubSubServer(t)
+	client := goredis.NewClient(&goredis.Options{Addr: server.listener.Addr().String()})
+	defer client.Close()
+	bus := NewRedisBus(client, DefaultChannel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan *Event, 4)
+	go func() { _ = bus.Subscribe(ctx, func(event *Event) { received <- event }) }()
+
+	first := <-server.subscribers
+	publishTo(first, `{"type":"user_revoked","user_id":"user-1"}`)
+	event := <-received
+	assert.Equal(t, UserRevoked, event.Type)
+	assert.Equal(t, "user-1", event.UserID)
+
+	// go-redis reconnects and subscribes again by itself; the subscriber is told events were missed
+	require.NoError(t, first.Close())
+	second := <-server.subscribers
+	select {
+	case event = <-received:
+		assert.Equal(t, Resubscribed, event.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no Resubscribed event after reconnecting")
+	}
+
+	publishTo(second, `{"type":"session_revoked","session_id":"session-1"}`)
+	event = <-received
+	assert.Equal(t, SessionRevoked, event.Type)
+}
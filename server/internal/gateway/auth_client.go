@@ -0,0 +1,223 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/pb"
+	"github.com/vhvplatform/go-auth-service/internal/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// AuthClientConfig configures the connection of the gateway to the auth service
+type AuthClientConfig struct {
+	// Addresses of the auth service replicas (host:port). A single address is resolved through DNS
+	// and every address it resolves to is used, e.g. a Kubernetes headless service.
+	Addresses []string
+
+	// Client certificate, key and CA of the auth service for mTLS. Without a certificate the
+	// connection is not encrypted.
+	CertFile string
+	KeyFile  string
+	CAFile   string
+
+	// Timeout is the deadline of a token validation, retries included
+	Timeout time.Duration
+
+	// Calls failing with Unavailable are retried up to MaxAttempts in total, on the next replica,
+	// with an exponential backoff starting at InitialBackoff
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultAuthClientConfig returns the configuration for a local auth service
+func DefaultAuthClientConfig() AuthClientConfig {
+	return AuthClientConfig{
+		Addresses:      []string{"localhost:50051"},
+		Timeout:        2 * time.Second,
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}
+}
+
+// AuthRPCClient validates tokens with the VerifyToken RPC of the auth service.
+// It keeps one connection to every replica and spreads calls over them round robin.
+type AuthRPCClient struct {
+	conn    *grpc.ClientConn
+	client  pb.AuthServiceClient
+	timeout time.Duration
+}
+
+// LoadAuthClientFromEnv creates an auth client configured by AUTH_GRPC_ADDRESSES (comma separated),
+// AUTH_GRPC_TLS_CERT, AUTH_GRPC_TLS_KEY, AUTH_GRPC_TLS_CA, AUTH_GRPC_TIMEOUT (a Go duration)
+// and AUTH_GRPC_MAX_ATTEMPTS
+func LoadAuthClientFromEnv() (*AuthRPCClient, error) {
+	config := DefaultAuthClientConfig()
+	if addresses := os.Getenv("AUTH_GRPC_ADDRESSES"); addresses != "" {
+		config.Addresses = nil
+		for _, address := range strings.Split(addresses, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				config.Addresses = append(config.Addresses, address)
+			}
+		}
+	}
+	config.CertFile = os.Getenv("AUTH_GRPC_TLS_CERT")
+	config.KeyFile = os.Getenv("AUTH_GRPC_TLS_KEY")
+	config.CAFile = os.Getenv("AUTH_GRPC_TLS_CA")
+	if value := os.Getenv("AUTH_GRPC_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_GRPC_TIMEOUT: %w", err)
+		}
+		config.Timeout = timeout
+	}
+	if value := os.Getenv("AUTH_GRPC_MAX_ATTEMPTS"); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_GRPC_MAX_ATTEMPTS: %w", err)
+		}
+		config.MaxAttempts = attempts
+	}
+	return NewAuthRPCClient(config)
+}
+
+// NewAuthRPCClient creates an auth client. Connections are made in the background, so the
+// auth service does not need to be up yet.
+func NewAuthRPCClient(config AuthClientConfig) (*AuthRPCClient, error) {
+	if len(config.Addresses) == 0 {
+		return nil, fmt.Errorf("no auth service address configured")
+	}
+	if config.Timeout <= 0 {
+		return nil, fmt.Errorf("auth client timeout must be positive")
+	}
+
+	var creds credentials.TransportCredentials = insecure.NewCredentials()
+	if config.CertFile != "" {
+		var err error
+		creds, err = utils.LoadClientTLSCredentials(config.CertFile, config.KeyFile, config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	serviceConfig, err := authServiceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+	}
+
+	target := "dns:///" + config.Addresses[0]
+	if len(config.Addresses) > 1 {
+		// Replicas listed one by one are passed to the balancer as they are. Each keeps its own
+		// host as TLS server name, since the target does not name a host.
+		replicas := manual.NewBuilderWithScheme("auth-replicas")
+		state := resolver.State{}
+		for _, address := range config.Addresses {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth service address %q: %w", address, err)
+			}
+			state.Addresses = append(state.Addresses, resolver.Address{Addr: address, ServerName: host})
+		}
+		replicas.InitialState(state)
+		options = append(options, grpc.WithResolvers(replicas))
+		target = replicas.Scheme() + ":///auth-service"
+	}
+
+	conn, err := grpc.NewClient(target, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create auth service client: %w", err)
+	}
+	return &AuthRPCClient{conn: conn, client: pb.NewAuthServiceClient(conn), timeout: config.Timeout}, nil
+}
+
+// authServiceConfig returns the gRPC service config that balances calls round robin and
+// retries VerifyToken when a replica is unavailable
+func authServiceConfig(config AuthClientConfig) (string, error) {
+	type retryPolicy struct {
+		MaxAttempts          int      `json:"maxAttempts"`
+		InitialBackoff       string   `json:"initialBackoff"`
+		MaxBackoff           string   `json:"maxBackoff"`
+		BackoffMultiplier    float64  `json:"backoffMultiplier"`
+		RetryableStatusCodes []string `json:"retryableStatusCodes"`
+	}
+	type methodConfig struct {
+		Name        []map[string]string `json:"name"`
+		RetryPolicy *retryPolicy        `json:"retryPolicy,omitempty"`
+	}
+
+	method := methodConfig{
+		Name: []map[string]string{{"service": pb.AuthService_ServiceDesc.ServiceName, "method": "VerifyToken"}},
+	}
+	if config.MaxAttempts > 1 {
+		method.RetryPolicy = &retryPolicy{
+			MaxAttempts:          config.MaxAttempts,
+			InitialBackoff:       fmt.Sprintf("%.3fs", config.InitialBackoff.Seconds()),
+			MaxBackoff:           fmt.Sprintf("%.3fs", config.MaxBackoff.Seconds()),
+			BackoffMultiplier:    2,
+			RetryableStatusCodes: []string{"UNAVAILABLE"},
+		}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"loadBalancingConfig": []map[string]interface{}{{"round_robin": map[string]interface{}{}}},
+		"methodConfig":        []methodConfig{method},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ValidateToken verifies a token with the auth service. When a tenant is given, a token issued
+// for another tenant is reported as invalid.
+func (c *AuthRPCClient) ValidateToken(ctx context.Context, token, tenantID string) (*ValidateTokenResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.client.VerifyToken(ctx, &pb.VerifyTokenRequest{Token: token})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Valid || (tenantID != "" && resp.TenantId != tenantID) {
+		return &ValidateTokenResponse{Valid: false}, nil
+	}
+
+	return &ValidateTokenResponse{
+		Valid:       true,
+		UserID:      resp.UserId,
+		TenantID:    resp.TenantId,
+		Email:       resp.Email,
+		Roles:       resp.Roles,
+		Permissions: resp.Permissions,
+		SessionID:   sessionID(resp.Metadata),
+	}, nil
+}
+
+// sessionID returns the login, or the API key, of a verified token
+func sessionID(metadata map[string]string) string {
+	if id := metadata["session_id"]; id != "" {
+		return id
+	}
+	return metadata["api_key_id"]
+}
+
+// Close closes the connections to the auth service
+func (c *AuthRPCClient) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAuthServer is an auth service replica answering VerifyToken
+type fakeAuthServer struct {
+	pb.UnimplementedAuthServiceServer
+	unavailable bool
+	calls       atomic.Int32
+}
+
+func (s *fakeAuthServer) VerifyToken(ctx context.Context, req *pb.VerifyTokenRequest) (*pb.VerifyTokenResponse, error) {
+	s.calls.Add(1)
+	if s.unavailable {
+		return nil, status.Error(codes.Unavailable, "shutting down")
+	}
+	if req.Token != "valid" {
+		return &pb.VerifyTokenResponse{Valid: false}, nil
+	}
+	return &pb.VerifyTokenResponse{Valid: true, UserId: "user-1", TenantId: "tenant-1", Roles: []string{"admin"}}, nil
+}
+
+func startFakeAuthServer(t *testing.T, server *fakeAuthServer) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	pb.RegisterAuthServiceServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+	return listener.Addr().String()
+}
+
+func newTestAuthClient(t *testing.T, addresses ...string) *AuthRPCClient {
+	config := DefaultAuthClientConfig()
+	config.Addresses = addresses
+	config.InitialBackoff = time.Millisecond
+	client, err := NewAuthRPCClient(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestAuthRPCClient_ValidateToken(t *testing.T) {
+	client := newTestAuthClient(t, startFakeAuthServer(t, &fakeAuthServer{}))
+	ctx := context.Background()
+
+	resp, err := client.ValidateToken(ctx, "valid", "tenant-1")
+	require.NoError(t, err)
+	assert.True(t, resp.Valid)
+	assert.Equal(t, "user-1", resp.UserID)
+	assert.Equal(t, []string{"admin"}, resp.Roles)
+
+	resp, err = client.ValidateToken(ctx, "valid", "")
+	require.NoError(t, err)
+	assert.True(t, resp.Valid)
+
+	resp, err = client.ValidateToken(ctx, "valid", "tenant-2")
+	require.NoError(t, err)
+	assert.False(t, resp.Valid)
+
+	resp, err = client.ValidateToken(ctx, "expired", "tenant-1")
+	require.NoError(t, err)
+	assert.False(t, resp.Valid)
+}
+
+func TestAuthRPCClient_RetriesOnOtherReplica(t *testing.T) {
+	down := &fakeAuthServer{unavailable: true}
+	up := &fakeAuthServer{}
+	client := newTestAuthClient(t, startFakeAuthServer(t, down), startFakeAuthServer(t, up))
+
+	for i := 0; i < 10; i++ {
+		resp, err := client.ValidateToken(context.Background(), "valid", "tenant-1")
+		require.NoError(t, err)
+		assert.True(t, resp.Valid)
+	}
+	assert.Positive(t, down.calls.Load())
+	assert.Equal(t, int32(10), up.calls.Load())
+}
+
+func TestAuthRPCClient_Unavailable(t *testing.T) {
+	down := &fakeAuthServer{unavailable: true}
+	client := newTestAuthClient(t, startFakeAuthServer(t, down))
+
+	_, err := client.ValidateToken(context.Background(), "valid", "tenant-1")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int32(3), down.calls.Load())
+}
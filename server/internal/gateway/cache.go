@@ -1,39 +1,262 @@
 package gateway
 
 import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/patrickmn/go-cache"
+	"github.com/vhvplatform/go-auth-service/internal/utils"
+	"golang.org/x/sync/singleflight"
 )
 
-// Cache handles local in-memory caching for the gateway
+// CacheConfig bounds the gateway's token cache
+type CacheConfig struct {
+	// MaxEntries and MaxBytes limit the cache; the least recently used tokens are evicted first.
+	// Sizes are estimated from the cached claims.
+	MaxEntries int
+	MaxBytes   int64
+
+	// TTL is how long a valid token is trusted without asking the auth service again
+	TTL time.Duration
+	// NegativeTTL is how long an invalid token is rejected without asking the auth service again
+	NegativeTTL time.Duration
+}
+
+// DefaultCacheConfig returns the cache limits used by the gateway
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		MaxEntries:  100000,
+		MaxBytes:    64 << 20,
+		TTL:         5 * time.Minute,
+		NegativeTTL: 10 * time.Second,
+	}
+}
+
+// CacheStats are the counters of the token cache
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Cache is the gateway's bounded LRU cache of token validations. Entries are keyed by a hash of
+// the token, so raw tokens are not kept, and can be deleted together by index, e.g. by user.
 type Cache struct {
-	store *cache.Cache
+	config CacheConfig
+
+	mu      sync.Mutex
+	lru     *list.List               // Most recently used first
+	entries map[string]*list.Element // Key -> element of *cacheEntry
+	indexes map[string]map[string]struct{}
+	bytes   int64
+	// generation changes whenever entries are invalidated, so that a validation that was
+	// in flight at that moment is not cached
+	generation uint64
+
+	calls     singleflight.Group
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type cacheEntry struct {
+	key       string
+	resp      *ValidateTokenResponse
+	indexes   []string
+	size      int64
+	expiresAt time.Time
 }
 
 // NewCache creates a new gateway cache
-func NewCache(defaultExpiration, cleanupInterval time.Duration) *Cache {
+func NewCache(config CacheConfig) *Cache {
 	return &Cache{
-		store: cache.New(defaultExpiration, cleanupInterval),
+		config:  config,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		indexes: make(map[string]map[string]struct{}),
 	}
 }
 
-// Set stores a value in the cache
-func (c *Cache) Set(key string, value interface{}, duration time.Duration) {
-	c.store.Set(key, value, duration)
+// Validate returns the cached validation of a token in a tenant, or asks the auth service.
+// Concurrent requests with the same token share one call. Valid and invalid results are cached;
+// errors are not.
+func (c *Cache) Validate(ctx context.Context, authClient AuthClient, token, tenantID string) (*ValidateTokenResponse, error) {
+	key := cacheKey(token, tenantID)
+	if resp, ok := c.get(key); ok {
+		return resp, nil
+	}
+
+	value, err, _ := c.calls.Do(key, func() (interface{}, error) {
+		generation := c.currentGeneration()
+		// The call is shared, so one caller going away must not cancel it for the others
+		resp, err := authClient.ValidateToken(context.WithoutCancel(ctx), token, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		c.set(key, resp, generation)
+		return resp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*ValidateTokenResponse), nil
 }
 
-// Get retrieves a value from the cache
-func (c *Cache) Get(key string) (interface{}, bool) {
-	return c.store.Get(key)
+// Get returns the cached validation of a token in a tenant
+func (c *Cache) Get(token, tenantID string) (*ValidateTokenResponse, bool) {
+	return c.get(cacheKey(token, tenantID))
 }
 
-// Delete removes a value from the cache
-func (c *Cache) Delete(key string) {
-	c.store.Delete(key)
+// Set caches the validation of a token in a tenant
+func (c *Cache) Set(token, tenantID string, resp *ValidateTokenResponse) {
+	c.set(cacheKey(token, tenantID), resp, c.currentGeneration())
+}
+
+// DeleteIndex removes every entry stored under an index and returns how many there were
+func (c *Cache) DeleteIndex(index string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := c.indexes[index]
+	deleted := len(keys)
+	for key := range keys {
+		c.remove(c.entries[key])
+	}
+	c.generation++
+	return deleted
 }
 
 // Flush clears all items from the cache
 func (c *Cache) Flush() {
-	c.store.Flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.indexes = make(map[string]map[string]struct{})
+	c.bytes = 0
+	c.generation++
+}
+
+// Stats returns the cache counters
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	entries, bytes := c.lru.Len(), c.bytes
+	c.mu.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   entries,
+		Bytes:     bytes,
+	}
+}
+
+func (c *Cache) get(key string) (*ValidateTokenResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	c.hits.Add(1)
+	return entry.resp, true
+}
+
+func (c *Cache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// set caches a validation unless entries were invalidated since the generation it was asked in
+func (c *Cache) set(key string, resp *ValidateTokenResponse, generation uint64) {
+	ttl := c.config.TTL
+	var indexes []string
+	if resp.Valid {
+		indexes = cacheIndexes(resp)
+	} else {
+		ttl = c.config.NegativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	entry := &cacheEntry{
+		key:       key,
+		resp:      resp,
+		indexes:   indexes,
+		size:      entrySize(key, resp),
+		expiresAt: time.Now().Add(ttl),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += entry.size
+	for _, index := range indexes {
+		keys, ok := c.indexes[index]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.indexes[index] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	for c.lru.Len() > 0 && ((c.config.MaxEntries > 0 && c.lru.Len() > c.config.MaxEntries) ||
+		(c.config.MaxBytes > 0 && c.bytes > c.config.MaxBytes)) {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// remove deletes an entry and its index references. The caller holds the lock.
+func (c *Cache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+	for _, index := range entry.indexes {
+		delete(c.indexes[index], entry.key)
+		if len(c.indexes[index]) == 0 {
+			delete(c.indexes, index)
+		}
+	}
+}
+
+// cacheKey identifies a token in a tenant without keeping the token
+func cacheKey(token, tenantID string) string {
+	return utils.HashToken(token + "\x00" + tenantID)
+}
+
+// entrySize estimates the memory an entry takes, including the map, list and index overhead
+func entrySize(key string, resp *ValidateTokenResponse) int64 {
+	size := 256 + 2*len(key) + len(resp.UserID) + len(resp.TenantID) + len(resp.Email) + len(resp.SessionID)
+	for _, role := range resp.Roles {
+		size += 16 + len(role)
+	}
+	for _, permission := range resp.Permissions {
+		size += 16 + len(permission)
+	}
+	return int64(size)
 }
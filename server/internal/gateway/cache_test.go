@@ -0,0 +1,129 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingAuthClient counts calls and holds them until released
+type countingAuthClient struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (c *countingAuthClient) ValidateToken(ctx context.Context, token, tenantID string) (*ValidateTokenResponse, error) {
+	c.calls.Add(1)
+	if c.release != nil {
+		<-c.release
+	}
+	return &ValidateTokenResponse{Valid: token == "valid", UserID: "user-1", TenantID: tenantID}, nil
+}
+
+func TestCache_LRUEviction(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxEntries = 2
+	cache := NewCache(config)
+
+	cache.Set("token-a", "tenant-1", &ValidateTokenResponse{Valid: true, UserID: "user-a"})
+	cache.Set("token-b", "tenant-1", &ValidateTokenResponse{Valid: true, UserID: "user-b"})
+	_, ok := cache.Get("token-a", "tenant-1") // token-b is now the least recently used
+	require.True(t, ok)
+	cache.Set("token-c", "tenant-1", &ValidateTokenResponse{Valid: true, UserID: "user-c"})
+
+	_, ok = cache.Get("token-b", "tenant-1")
+	assert.False(t, ok)
+	_, ok = cache.Get("token-a", "tenant-1")
+	assert.True(t, ok)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 2, stats.Entries)
+
+	// Evicted entries leave no index behind
+	assert.NotContains(t, cache.indexes, "user:user-b")
+	for key := range cache.entries {
+		assert.False(t, strings.Contains(key, "token"), "raw token in cache key %q", key)
+	}
+}
+
+func TestCache_MaxBytes(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.MaxBytes = 1024
+	cache := NewCache(config)
+
+	for _, token := range []string{"a", "b", "c", "d", "e", "f"} {
+		cache.Set(token, "tenant-1", &ValidateTokenResponse{Valid: true, UserID: "user-" + token})
+	}
+	stats := cache.Stats()
+	assert.LessOrEqual(t, stats.Bytes, int64(1024))
+	assert.Positive(t, stats.Evictions)
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	config := DefaultCacheConfig()
+	config.NegativeTTL = 50 * time.Millisecond
+	cache := NewCache(config)
+	client := &countingAuthClient{}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		resp, err := cache.Validate(ctx, client, "garbage", "tenant-1")
+		require.NoError(t, err)
+		assert.False(t, resp.Valid)
+	}
+	assert.Equal(t, int32(1), client.calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+	_, err := cache.Validate(ctx, client, "garbage", "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), client.calls.Load())
+}
+
+func TestCache_SingleFlight(t *testing.T) {
+	cache := NewCache(DefaultCacheConfig())
+	client := &countingAuthClient{release: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := cache.Validate(context.Background(), client, "valid", "tenant-1")
+			assert.NoError(t, err)
+			assert.True(t, resp.Valid)
+		}()
+	}
+	assert.Eventually(t, func() bool { return client.calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(client.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), client.calls.Load())
+}
+
+func TestCache_InvalidatedWhileInFlight(t *testing.T) {
+	cache := NewCache(DefaultCacheConfig())
+	client := &countingAuthClient{release: make(chan struct{})}
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = cache.Validate(context.Background(), client, "valid", "tenant-1")
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return client.calls.Load() == 1 }, time.Second, time.Millisecond)
+	cache.DeleteIndex("user:user-1")
+	close(client.release)
+	<-done
+
+	_, ok := cache.Get("valid", "tenant-1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"context"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/events"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// cacheIndexes returns the indexes a token validation is cached under
+func cacheIndexes(resp *ValidateTokenResponse) []string {
+	indexes := []string{"user:" + resp.UserID}
+	if resp.SessionID != "" {
+		indexes = append(indexes, "session:"+resp.SessionID)
+	}
+	return indexes
+}
+
+// Invalidate deletes the cached token validations an auth event makes stale and returns how many
+// there were. Revoking a user or changing their roles drops all of the user's tokens.
+func (c *Cache) Invalidate(event *events.Event) int {
+	switch event.Type {
+	case events.SessionRevoked:
+		if event.SessionID != "" {
+			return c.DeleteIndex("session:" + event.SessionID)
+		}
+	case events.UserRevoked, events.RolesChanged:
+		if event.UserID != "" {
+			return c.DeleteIndex("user:" + event.UserID)
+		}
+	}
+	return 0
+}
+
+// SubscribeInvalidations keeps the cache in sync with the auth events until the context is done.
+// The cache is flushed whenever the subscription has to be started again or was restored after a
+// dropped connection, since events may have been missed in between.
+func SubscribeInvalidations(ctx context.Context, cache *Cache, subscriber events.Subscriber, retryInterval time.Duration, log *logger.Logger) {
+	for {
+		err := subscriber.Subscribe(ctx, func(event *events.Event) {
+			if event.Type == events.Resubscribed {
+				log.Warn("Auth event subscription restored, flushing the token cache")
+				cache.Flush()
+				return
+			}
+			if evicted := cache.Invalidate(event); evicted > 0 {
+				log.Debug("Evicted cached tokens",
+					zap.String("type", event.Type),
+					zap.String("user_id", event.UserID),
+					zap.Int("evicted", evicted))
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Error("Auth event subscription failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+			cache.Flush()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/events"
+	"github.com/vhvplatform/go-shared/logger"
+)
+
+func cacheToken(cache *Cache, token, userID, sessionID string) {
+	cache.Set(token, "tenant-1", &ValidateTokenResponse{Valid: true, UserID: userID, SessionID: sessionID})
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	cache := NewCache(DefaultCacheConfig())
+	cacheToken(cache, "token:a", "user-1", "session-1")
+	cacheToken(cache, "token:b", "user-1", "session-2")
+	cacheToken(cache, "token:c", "user-2", "session-3")
+
+	assert.Equal(t, 1, cache.Invalidate(&events.Event{Type: events.SessionRevoked, SessionID: "session-1"}))
+	_, ok := cache.Get("token:a", "tenant-1")
+	assert.False(t, ok)
+	_, ok = cache.Get("token:b", "tenant-1")
+	assert.True(t, ok)
+
+	assert.Equal(t, 1, cache.Invalidate(&events.Event{Type: events.RolesChanged, UserID: "user-1", TenantID: "tenant-1"}))
+	_, ok = cache.Get("token:b", "tenant-1")
+	assert.False(t, ok)
+	_, ok = cache.Get("token:c", "tenant-1")
+	assert.True(t, ok)
+
+	assert.Equal(t, 0, cache.Invalidate(&events.Event{Type: events.UserRevoked, UserID: "user-1"}))
+
+	// Deleted entries leave no index behind
+	assert.Equal(t, 1, cache.Invalidate(&events.Event{Type: events.SessionRevoked, SessionID: "session-3"}))
+	assert.Empty(t, cache.indexes)
+	assert.Zero(t, cache.Stats().Entries)
+}
+
+func TestSubscribeInvalidations(t *testing.T) {
+	cache := NewCache(DefaultCacheConfig())
+	cacheToken(cache, "token:a", "user-1", "session-1")
+
+	log, err := logger.New("error")
+	require.NoError(t, err)
+	bus := events.NewLocalBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go SubscribeInvalidations(ctx, cache, bus, time.Second, log)
+
+	assert.Eventually(t, func() bool {
+		_ = bus.Publish(ctx, &events.Event{Type: events.UserRevoked, UserID: "user-1"})
+		_, ok := cache.Get("token:a", "tenant-1")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestSubscribeInvalidations_Resubscribed(t *testing.T) {
+	cache := NewCache(DefaultCacheConfig())
+	cacheToken(cache, "token:a", "user-1", "session-1")
+
+	log, err := logger.New("error")
+	require.NoError(t, err)
+	bus := events.NewLocalBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go SubscribeInvalidations(ctx, cache, bus, time.Second, log)
+
+	// Events may have been missed while the connection was down
+	assert.Eventually(t, func() bool {
+		_ = bus.Publish(ctx, &events.Event{Type: events.Resubscribed})
+		return cache.Stats().Entries == 0
+	}, time.Second, 5*time.Millisecond)
+}
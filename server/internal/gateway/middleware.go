@@ -2,13 +2,13 @@ package gateway
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/vhvplatform/go-shared/jwt"
+	"github.com/vhvplatform/go-auth-service/internal/keys"
+	"github.com/vhvplatform/go-auth-service/internal/utils"
 	"github.com/vhvplatform/go-shared/logger"
 	"go.uber.org/zap"
 )
@@ -26,11 +26,37 @@ type ValidateTokenResponse struct {
 	Email       string
 	Roles       []string
 	Permissions []string
+	SessionID   string // Login or API key the token belongs to
 }
 
-// AuthMiddleware handles authentication and tenant verification at the gateway
-func AuthMiddleware(authClient AuthClient, cache *Cache, jwtManager *jwt.Manager, log *logger.Logger) gin.HandlerFunc {
+// Internal tokens are short-lived: downstream services verify them with the gateway's public keys
+const (
+	internalTokenIssuer = "api-gateway"
+	internalTokenTTL    = 5 * time.Minute
+)
+
+// InternalClaims are the claims of the internal token passed to downstream services
+type InternalClaims struct {
+	Issuer      string   `json:"iss"`
+	Subject     string   `json:"sub"`
+	TenantID    string   `json:"tenant_id"`
+	Email       string   `json:"email,omitempty"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	IssuedAt    int64    `json:"iat"`
+	ExpiresAt   int64    `json:"exp"`
+}
+
+// AuthMiddleware handles authentication and tenant verification at the gateway.
+// Public routes are passed through; other routes also need their required permissions.
+func AuthMiddleware(authClient AuthClient, cache *Cache, signingKeys *keys.KeySet, log *logger.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		route, _ := RouteFromContext(c)
+		if route != nil && route.Public {
+			c.Next()
+			return
+		}
+
 		token := extractToken(c.Request)
 		if token == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing authorization token"})
@@ -40,35 +66,59 @@ func AuthMiddleware(authClient AuthClient, cache *Cache, jwtManager *jwt.Manager
 
 		tenantID := c.GetHeader("X-Tenant-ID")
 
-		// Check local cache
-		cacheKey := fmt.Sprintf("token:%s:%s", token, tenantID)
-		if val, ok := cache.Get(cacheKey); ok {
-			claims := val.(*ValidateTokenResponse)
-			injectHeaders(c, claims, jwtManager, log)
-			c.Next()
-			return
-		}
-
-		// Call Auth Service
-		resp, err := authClient.ValidateToken(c.Request.Context(), token, tenantID)
+		// Check local cache, then call Auth Service
+		resp, err := cache.Validate(c.Request.Context(), authClient, token, tenantID)
 		if err != nil || !resp.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		// Cache the result (e.g. for 5 minutes)
-		cache.Set(cacheKey, resp, 5*time.Minute)
-
-		injectHeaders(c, resp, jwtManager, log)
+		if !authorize(c, route, resp) {
+			return
+		}
+		injectHeaders(c, resp, signingKeys, log)
 		c.Next()
 	}
 }
 
+// authorize aborts with 403 unless the caller has the permissions the route requires
+func authorize(c *gin.Context, route *Route, resp *ValidateTokenResponse) bool {
+	if route == nil || len(route.RequiredPermissions) == 0 {
+		return true
+	}
+
+	var missing []string
+	for _, required := range route.RequiredPermissions {
+		if !utils.HasPermission(resp.Permissions, required) {
+			missing = append(missing, required)
+		}
+	}
+	granted := len(missing) == 0
+	if route.PermissionMatch == PermissionMatchAny {
+		granted = len(missing) < len(route.RequiredPermissions)
+	}
+	if granted {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":                "Insufficient permissions",
+		"code":                 "permission_denied",
+		"permission_match":     route.PermissionMatch,
+		"required_permissions": route.RequiredPermissions,
+		"missing_permissions":  missing,
+	})
+	c.Abort()
+	return false
+}
+
+// extractToken reads a bearer token, or a personal API key from the X-API-Key header.
+// The auth service tells API keys and access tokens apart by the key prefix.
 func extractToken(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
-		return ""
+		return strings.TrimSpace(r.Header.Get("X-API-Key"))
 	}
 	parts := strings.Split(authHeader, " ")
 	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
@@ -77,12 +127,23 @@ func extractToken(r *http.Request) string {
 	return parts[1]
 }
 
-func injectHeaders(c *gin.Context, resp *ValidateTokenResponse, jwtManager *jwt.Manager, log *logger.Logger) {
-	// Generate internal-token (JWT)
-	// Note: In a real scenario, use a specific secret for internal communication
-	internalToken, err := jwtManager.GenerateToken(resp.UserID, resp.TenantID, resp.Email, resp.Roles, resp.Permissions)
+func injectHeaders(c *gin.Context, resp *ValidateTokenResponse, signingKeys *keys.KeySet, log *logger.Logger) {
+	// Generate internal-token (JWT), signed with the gateway's current key and identified by its kid
+	now := time.Now()
+	internalToken, err := signingKeys.Sign(InternalClaims{
+		Issuer:      internalTokenIssuer,
+		Subject:     resp.UserID,
+		TenantID:    resp.TenantID,
+		Email:       resp.Email,
+		Roles:       resp.Roles,
+		Permissions: resp.Permissions,
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   now.Add(internalTokenTTL).Unix(),
+	})
 	if err != nil {
 		log.Error("Failed to generate internal token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate request"})
+		c.Abort()
 		return
 	}
 
@@ -91,5 +152,3 @@ func injectHeaders(c *gin.Context, resp *ValidateTokenResponse, jwtManager *jwt.
 
 	// These will be used by the Proxy to set outgoing headers
 }
-
-// Since I used fmt.Sprintf, I need to add fmt to imports
@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/keys"
+	"github.com/vhvplatform/go-shared/logger"
+)
+
+// staticAuthClient accepts one token with fixed permissions
+type staticAuthClient struct {
+	permissions []string
+}
+
+func (c *staticAuthClient) ValidateToken(ctx context.Context, token, tenantID string) (*ValidateTokenResponse, error) {
+	if token != "valid" {
+		return &ValidateTokenResponse{Valid: false}, nil
+	}
+	return &ValidateTokenResponse{Valid: true, UserID: "user-1", TenantID: "tenant-1", Permissions: c.permissions}, nil
+}
+
+func newTestGateway(t *testing.T, route Route, permissions []string) *gin.Engine {
+	signingKeys := keys.NewKeySet(keys.NewMemoryStore(), keys.DefaultConfig())
+	require.NoError(t, signingKeys.Load(context.Background()))
+	log, err := logger.New("error")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.NoRoute(
+		func(c *gin.Context) { c.Set(routeContextKey, &route) },
+		AuthMiddleware(&staticAuthClient{permissions: permissions}, NewCache(DefaultCacheConfig()), signingKeys, log),
+		func(c *gin.Context) { c.Status(http.StatusNoContent) },
+	)
+	return router
+}
+
+func serveWithToken(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/files/1", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthMiddleware_RequiredPermissions(t *testing.T) {
+	allOf := Route{RequiredPermissions: []string{"file.read", "file.write"}, PermissionMatch: PermissionMatchAll}
+	anyOf := Route{RequiredPermissions: []string{"file.read", "file.write"}, PermissionMatch: PermissionMatchAny}
+
+	assert.Equal(t, http.StatusNoContent, serveWithToken(newTestGateway(t, allOf, []string{"file.read", "file.write"}), "valid").Code)
+	assert.Equal(t, http.StatusNoContent, serveWithToken(newTestGateway(t, allOf, []string{"*"}), "valid").Code)
+	assert.Equal(t, http.StatusNoContent, serveWithToken(newTestGateway(t, anyOf, []string{"file.write"}), "valid").Code)
+	assert.Equal(t, http.StatusForbidden, serveWithToken(newTestGateway(t, anyOf, []string{"user.read"}), "valid").Code)
+
+	w := serveWithToken(newTestGateway(t, allOf, []string{"file.read"}), "valid")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "permission_denied", body["code"])
+	assert.Equal(t, []interface{}{"file.write"}, body["missing_permissions"])
+}
+
+func TestAuthMiddleware_PublicRoute(t *testing.T) {
+	router := newTestGateway(t, Route{Public: true, RequiredPermissions: []string{"file.read"}}, nil)
+	assert.Equal(t, http.StatusNoContent, serveWithToken(router, "").Code)
+
+	router = newTestGateway(t, Route{}, nil)
+	assert.Equal(t, http.StatusUnauthorized, serveWithToken(router, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveWithToken(router, "expired").Code)
+	assert.Equal(t, http.StatusNoContent, serveWithToken(router, "valid").Code)
+}
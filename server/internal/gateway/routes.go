@@ -0,0 +1,215 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// Route sends the requests matching a path pattern to an upstream service
+type Route struct {
+	// Path is an exact path, or a prefix when it ends with "/*", e.g. "/api/files/*"
+	Path string `yaml:"path" json:"path"`
+	// Methods the route accepts; any method when empty
+	Methods []string `yaml:"methods" json:"methods"`
+	// Upstream is the base URL of the service, e.g. "http://file-service:8080"
+	Upstream string `yaml:"upstream" json:"upstream"`
+	// StripPrefix is removed from the path before it is passed upstream
+	StripPrefix string `yaml:"strip_prefix" json:"strip_prefix"`
+	// Public routes are proxied without a token, with the caller's headers as they are
+	Public bool `yaml:"public" json:"public"`
+	// RequiredPermissions the caller needs, with the wildcard semantics of the auth service
+	RequiredPermissions []string `yaml:"required_permissions" json:"required_permissions"`
+	// PermissionMatch is "all" (the default) when every required permission is needed,
+	// or "any" when one of them is enough
+	PermissionMatch string `yaml:"permission_match" json:"permission_match"`
+
+	upstream *url.URL
+}
+
+// Permission matching modes of a route
+const (
+	PermissionMatchAll = "all"
+	PermissionMatchAny = "any"
+)
+
+// RouteConfig is the content of a route file. JSON files are read as YAML.
+type RouteConfig struct {
+	Routes []Route `yaml:"routes" json:"routes"`
+}
+
+// routeContextKey is the gin context key of the route matched by RouteTable.Middleware
+const routeContextKey = "gateway_route"
+
+// RouteTable holds the routes of a route file. Reload swaps the routes atomically, so requests
+// in flight keep the route they matched.
+type RouteTable struct {
+	path   string
+	routes atomic.Pointer[[]Route]
+
+	mu      sync.Mutex
+	content []byte
+}
+
+// LoadRouteTable reads the routes of a YAML or JSON file
+func LoadRouteTable(path string) (*RouteTable, error) {
+	table := &RouteTable{path: path}
+	if _, err := table.Reload(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Reload reads the route file again and replaces the routes when it changed. A file that does
+// not parse leaves the current routes in place.
+func (t *RouteTable) Reload() (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	content, err := os.ReadFile(t.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read route file: %w", err)
+	}
+	if t.content != nil && bytes.Equal(content, t.content) {
+		return false, nil
+	}
+
+	routes, err := ParseRoutes(content)
+	if err != nil {
+		return false, fmt.Errorf("invalid route file %s: %w", t.path, err)
+	}
+	t.routes.Store(&routes)
+	t.content = content
+	return true, nil
+}
+
+// Run reloads the route file every interval until the context is done
+func (t *RouteTable) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := t.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Routes returns the current routes
+func (t *RouteTable) Routes() []Route {
+	return *t.routes.Load()
+}
+
+// Match returns the first route, in file order, matching a method and path
+func (t *RouteTable) Match(method, path string) (*Route, bool) {
+	routes := t.Routes()
+	for i := range routes {
+		if routes[i].matches(method, path) {
+			return &routes[i], true
+		}
+	}
+	return nil, false
+}
+
+// Middleware matches the request against the route table. Requests without a route get a 404,
+// the others carry their route for AuthMiddleware and the proxy.
+func (t *RouteTable) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := t.Match(c.Request.Method, c.Request.URL.Path)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+			c.Abort()
+			return
+		}
+		c.Set(routeContextKey, route)
+		c.Next()
+	}
+}
+
+// RouteFromContext returns the route matched by RouteTable.Middleware
+func RouteFromContext(c *gin.Context) (*Route, bool) {
+	value, ok := c.Get(routeContextKey)
+	if !ok {
+		return nil, false
+	}
+	route, ok := value.(*Route)
+	return route, ok
+}
+
+// ParseRoutes parses and validates the content of a route file
+func ParseRoutes(content []byte) ([]Route, error) {
+	var config RouteConfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+
+	for i := range config.Routes {
+		route := &config.Routes[i]
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("route %d: path must start with /", i)
+		}
+		if route.StripPrefix != "" && !strings.HasPrefix(route.Path, route.StripPrefix) {
+			return nil, fmt.Errorf("route %s: strip_prefix %s is not a prefix of the path", route.Path, route.StripPrefix)
+		}
+		upstream, err := url.Parse(route.Upstream)
+		if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+			return nil, fmt.Errorf("route %s: upstream must be an absolute URL", route.Path)
+		}
+		route.upstream = upstream
+		switch route.PermissionMatch {
+		case "":
+			route.PermissionMatch = PermissionMatchAll
+		case PermissionMatchAll, PermissionMatchAny:
+		default:
+			return nil, fmt.Errorf("route %s: permission_match must be %q or %q", route.Path, PermissionMatchAll, PermissionMatchAny)
+		}
+		for j, method := range route.Methods {
+			route.Methods[j] = strings.ToUpper(method)
+		}
+	}
+	return config.Routes, nil
+}
+
+func (r *Route) matches(method, path string) bool {
+	if prefix, ok := strings.CutSuffix(r.Path, "/*"); ok {
+		if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			return false
+		}
+	} else if path != r.Path {
+		return false
+	}
+
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, allowed := range r.Methods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamPath returns the path a request is sent upstream with
+func (r *Route) upstreamPath(path string) string {
+	path = strings.TrimPrefix(path, r.StripPrefix)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
@@ -0,0 +1,89 @@
+package gateway
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRoutes = `
+routes:
+  - path: /api/v1/auth/login
+    methods: [post]
+    upstream: http://auth:8081
+    public: true
+  - path: /api/files/*
+    upstream: http://files:8082/v1
+    strip_prefix: /api/files
+    required_permissions: [file.read]
+`
+
+func writeRoutes(t *testing.T, path, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestRouteTable_Match(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeRoutes(t, path, testRoutes)
+	table, err := LoadRouteTable(path)
+	require.NoError(t, err)
+
+	route, ok := table.Match("POST", "/api/v1/auth/login")
+	require.True(t, ok)
+	assert.True(t, route.Public)
+
+	_, ok = table.Match("GET", "/api/v1/auth/login")
+	assert.False(t, ok)
+	_, ok = table.Match("POST", "/api/v1/auth/login/other")
+	assert.False(t, ok)
+
+	route, ok = table.Match("DELETE", "/api/files/a/b")
+	require.True(t, ok)
+	assert.Equal(t, []string{"file.read"}, route.RequiredPermissions)
+	assert.Equal(t, "/a/b", route.upstreamPath("/api/files/a/b"))
+	assert.Equal(t, "/", route.upstreamPath("/api/files"))
+
+	_, ok = table.Match("GET", "/api/filesystem")
+	assert.False(t, ok)
+}
+
+func TestRouteTable_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeRoutes(t, path, testRoutes)
+	table, err := LoadRouteTable(path)
+	require.NoError(t, err)
+
+	changed, err := table.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	writeRoutes(t, path, `{"routes": [{"path": "/health/*", "upstream": "http://health:80"}]}`)
+	changed, err = table.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	_, ok := table.Match("GET", "/api/files/a")
+	assert.False(t, ok)
+	_, ok = table.Match("GET", "/health/live")
+	assert.True(t, ok)
+
+	// A broken file keeps the routes in place
+	writeRoutes(t, path, "routes: [")
+	_, err = table.Reload()
+	assert.Error(t, err)
+	_, ok = table.Match("GET", "/health/live")
+	assert.True(t, ok)
+}
+
+func TestParseRoutes_Invalid(t *testing.T) {
+	for _, content := range []string{
+		"routes:\n  - path: api\n    upstream: http://api:80",
+		"routes:\n  - path: /api/*\n    upstream: api:80/",
+		"routes:\n  - path: /api/*\n    upstream: http://api:80\n    strip_prefix: /other",
+	} {
+		_, err := ParseRoutes([]byte(content))
+		assert.Error(t, err, content)
+	}
+}
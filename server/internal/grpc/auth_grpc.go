@@ -3,8 +3,8 @@ package grpc
 import (
 	"context"
 
+	"github.com/vhvplatform/go-auth-service/internal/pb"
 	"github.com/vhvplatform/go-auth-service/internal/service"
-	pb "github.com/vhvplatform/go-auth-service/proto"
 	"github.com/vhvplatform/go-shared/logger"
 	"go.uber.org/zap"
 )
@@ -2,10 +2,16 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net"
+	"net/http"
+	"time"
 
 	"github.com/vhvplatform/go-auth-service/internal/domain"
 	"github.com/vhvplatform/go-auth-service/internal/pb"
 	"github.com/vhvplatform/go-auth-service/internal/service"
+	"github.com/vhvplatform/go-shared/errors"
 	"github.com/vhvplatform/go-shared/logger"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
@@ -17,18 +23,22 @@ type MultiTenantAuthServer struct {
 	pb.UnimplementedAuthServiceServer
 	authService       *service.MultiTenantAuthService
 	permissionService *service.PermissionService
+	trustedProxies    []*net.IPNet
 	logger            *logger.Logger
 }
 
-// NewMultiTenantAuthServer creates a new gRPC auth service server
+// NewMultiTenantAuthServer creates a new gRPC auth service server. The X-Forwarded-For header is
+// only trusted from the given proxies.
 func NewMultiTenantAuthServer(
 	authService *service.MultiTenantAuthService,
 	permissionService *service.PermissionService,
+	trustedProxies []*net.IPNet,
 	log *logger.Logger,
 ) *MultiTenantAuthServer {
 	return &MultiTenantAuthServer{
 		authService:       authService,
 		permissionService: permissionService,
+		trustedProxies:    trustedProxies,
 		logger:            log,
 	}
 }
@@ -51,7 +61,7 @@ func (s *MultiTenantAuthServer) Login(ctx context.Context, req *pb.LoginRequest)
 	}
 
 	// Attempt login
-	response, err := s.authService.Login(ctx, req.Identifier, req.Password, req.TenantId)
+	response, err := s.authService.Login(s.withClientInfo(ctx), req.Identifier, req.Password, req.TenantId, s.clientIP(ctx))
 	if err != nil {
 		s.logger.Warn("Login failed",
 			zap.String("identifier", req.Identifier),
@@ -61,10 +71,61 @@ func (s *MultiTenantAuthServer) Login(ctx context.Context, req *pb.LoginRequest)
 	}
 
 	return &pb.LoginResponse{
-		AccessToken:  response.AccessToken,
-		RefreshToken: response.RefreshToken,
-		TokenType:    response.TokenType,
-		ExpiresIn:    response.ExpiresIn,
+		AccessToken:           response.AccessToken,
+		RefreshToken:          response.RefreshToken,
+		TokenType:             response.TokenType,
+		ExpiresIn:             response.ExpiresIn,
+		MfaRequired:           response.MFARequired,
+		MfaEnrollmentRequired: response.MFAEnrollmentRequired,
+		MfaToken:              response.MFAToken,
+	}, nil
+}
+
+// EnrollTOTP starts or confirms TOTP enrollment
+func (s *MultiTenantAuthServer) EnrollTOTP(ctx context.Context, req *pb.EnrollTOTPRequest) (*pb.EnrollTOTPResponse, error) {
+	s.logger.Info("Enroll TOTP request received", zap.Bool("confirm", req.Code != ""))
+
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	enrollment, err := s.authService.EnrollTOTP(ctx, req.Token, req.Code)
+	if err != nil {
+		s.logger.Warn("TOTP enrollment failed", zap.Error(err))
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return &pb.EnrollTOTPResponse{
+		Secret:        enrollment.Secret,
+		OtpauthUri:    enrollment.OTPAuthURI,
+		RecoveryCodes: enrollment.RecoveryCodes,
+		Enabled:       enrollment.Enabled,
+	}, nil
+}
+
+// VerifyMFA completes a login with a TOTP or recovery code
+func (s *MultiTenantAuthServer) VerifyMFA(ctx context.Context, req *pb.VerifyMFARequest) (*pb.VerifyMFAResponse, error) {
+	s.logger.Info("Verify MFA request received")
+
+	if req.MfaToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "mfa_token is required")
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "code or recovery_code is required")
+	}
+
+	response, err := s.authService.VerifyMFA(s.withClientInfo(ctx), req.MfaToken, req.Code, req.RecoveryCode)
+	if err != nil {
+		s.logger.Warn("MFA verification failed", zap.Error(err))
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return &pb.VerifyMFAResponse{
+		AccessToken:   response.AccessToken,
+		RefreshToken:  response.RefreshToken,
+		TokenType:     response.TokenType,
+		ExpiresIn:     response.ExpiresIn,
+		RecoveryCodes: response.RecoveryCodes,
 	}, nil
 }
 
@@ -123,7 +184,7 @@ func (s *MultiTenantAuthServer) RefreshToken(ctx context.Context, req *pb.Refres
 		return nil, status.Error(codes.InvalidArgument, "refresh_token is required")
 	}
 
-	response, err := s.authService.RefreshToken(ctx, req.RefreshToken)
+	response, err := s.authService.RefreshToken(s.withClientInfo(ctx), req.RefreshToken)
 	if err != nil {
 		s.logger.Warn("Refresh token failed", zap.Error(err))
 		return nil, status.Error(codes.Unauthenticated, err.Error())
@@ -178,9 +239,15 @@ func (s *MultiTenantAuthServer) VerifyToken(ctx context.Context, req *pb.VerifyT
 		}, status.Error(codes.InvalidArgument, "token is required")
 	}
 
+	// Only a rejected token is reported as invalid. The gateway caches that answer, so failures to
+	// check the token are returned as errors instead.
 	resp, err := s.authService.VerifyToken(ctx, req.Token)
 	if err != nil {
-		s.logger.Debug("Token verification failed", zap.Error(err))
+		if !tokenRejected(err) {
+			s.logger.Error("Token verification failed", zap.Error(err))
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		s.logger.Debug("Token rejected", zap.Error(err))
 		return &pb.VerifyTokenResponse{
 			Valid: false,
 		}, nil
@@ -197,30 +264,14 @@ func (s *MultiTenantAuthServer) VerifyToken(ctx context.Context, req *pb.VerifyT
 	}, nil
 }
 
-// GetUserRoles gets roles and permissions for a user (not implemented yet)
-func (s *MultiTenantAuthServer) GetUserRoles(ctx context.Context, req *pb.GetUserRolesRequest) (*pb.GetUserRolesResponse, error) {
-	s.logger.Info("Get user roles request received",
-		zap.String("user_id", req.UserId),
-		zap.String("tenant_id", req.TenantId))
-
-	// TODO: Implement when role repository methods are available
-	return &pb.GetUserRolesResponse{
-		Roles:       []string{},
-		Permissions: []string{},
-	}, nil
-}
-
-// CheckPermission checks if a user has a specific permission (not implemented yet)
-func (s *MultiTenantAuthServer) CheckPermission(ctx context.Context, req *pb.CheckPermissionRequest) (*pb.CheckPermissionResponse, error) {
-	s.logger.Info("Check permission request received",
-		zap.String("user_id", req.UserId),
-		zap.String("tenant_id", req.TenantId),
-		zap.String("permission", req.Permission))
-
-	// TODO: Implement when permission checking is available
-	return &pb.CheckPermissionResponse{
-		Allowed: false,
-	}, nil
+// tokenRejected reports whether token verification failed because the token is not valid, rather
+// than because it could not be checked
+func tokenRejected(err error) bool {
+	var appErr *errors.AppError
+	if !stderrors.As(err, &appErr) {
+		return false
+	}
+	return appErr.StatusCode == http.StatusUnauthorized || appErr.StatusCode == http.StatusForbidden
 }
 
 // GetTenantLoginConfig returns the login configuration for a tenant
@@ -239,12 +290,13 @@ func (s *MultiTenantAuthServer) GetTenantLoginConfig(ctx context.Context, req *p
 	}
 
 	return &pb.GetTenantLoginConfigResponse{
-		AllowedIdentifiers:  config.AllowedIdentifiers,
-		Require2Fa:          config.Require2FA,
-		AllowRegistration:   config.AllowRegistration,
-		CustomLogoUrl:       config.CustomLogoURL,
-		CustomBackgroundUrl: config.CustomBackgroundURL,
-		CustomFields:        config.CustomFields,
+		AllowedIdentifiers:        config.AllowedIdentifiers,
+		Require_2Fa:               config.Require2FA,
+		AllowRegistration:         config.AllowRegistration,
+		CustomLogoUrl:             config.CustomLogoURL,
+		CustomBackgroundUrl:       config.CustomBackgroundURL,
+		CustomFields:              config.CustomFields,
+		RequireVerifiedIdentifier: config.RequireVerifiedIdentifier,
 	}, nil
 }
 
@@ -265,7 +317,7 @@ func (s *MultiTenantAuthServer) Logout(ctx context.Context, req *pb.LogoutReques
 		}, nil
 	}
 
-	s.logger.Info("Logout successful", zap.String("session_id", req.Token))
+	s.logger.Info("Logout successful", zap.String("tenant_id", req.TenantId))
 
 	return &pb.LogoutResponse{
 		Success: true,
@@ -273,6 +325,773 @@ func (s *MultiTenantAuthServer) Logout(ctx context.Context, req *pb.LogoutReques
 	}, nil
 }
 
+// RequestPasswordReset starts a password reset.
+// The response does not reveal whether the account exists.
+func (s *MultiTenantAuthServer) RequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest) (*pb.RequestPasswordResetResponse, error) {
+	s.logger.Info("Password reset request received", zap.String("tenant_id", req.TenantId))
+
+	if req.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+
+	if err := s.authService.RequestPasswordReset(ctx, req.Email, req.TenantId, s.clientIP(ctx)); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.RequestPasswordResetResponse{
+		Message: "If the account exists, a password reset link has been sent",
+	}, nil
+}
+
+// ResetPassword completes a password reset
+func (s *MultiTenantAuthServer) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+	s.logger.Info("Reset password request received")
+
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	if req.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "new_password is required")
+	}
+
+	if err := s.authService.ResetPassword(ctx, req.Token, req.NewPassword); err != nil {
+		s.logger.Warn("Password reset failed", zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.ResetPasswordResponse{
+		Message: "Password reset successful",
+	}, nil
+}
+
+// SendVerification sends a verification code and link.
+// The response does not reveal whether the identifier belongs to an account.
+func (s *MultiTenantAuthServer) SendVerification(ctx context.Context, req *pb.SendVerificationRequest) (*pb.SendVerificationResponse, error) {
+	s.logger.Info("Send verification request received", zap.String("tenant_id", req.TenantId))
+
+	if req.Identifier == "" {
+		return nil, status.Error(codes.InvalidArgument, "identifier is required")
+	}
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+
+	if err := s.authService.SendVerification(ctx, req.Identifier, req.TenantId); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.SendVerificationResponse{
+		Message: "If the account exists, a verification code has been sent",
+	}, nil
+}
+
+// VerifyIdentifier confirms an email address or phone number
+func (s *MultiTenantAuthServer) VerifyIdentifier(ctx context.Context, req *pb.VerifyIdentifierRequest) (*pb.VerifyIdentifierResponse, error) {
+	s.logger.Info("Verify identifier request received", zap.Bool("link", req.Token != ""))
+
+	if req.Token == "" && (req.Identifier == "" || req.Code == "") {
+		return nil, status.Error(codes.InvalidArgument, "token, or identifier and code, are required")
+	}
+
+	if err := s.authService.VerifyIdentifier(ctx, req.Identifier, req.Code, req.Token); err != nil {
+		s.logger.Warn("Identifier verification failed", zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.VerifyIdentifierResponse{
+		Message: "Verified successfully",
+	}, nil
+}
+
+// ChangePassword changes the password of the authenticated caller
+func (s *MultiTenantAuthServer) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	s.logger.Info("Change password request received", zap.Bool("revoke_other_sessions", req.RevokeOtherSessions))
+
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+	if req.OldPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "old_password is required")
+	}
+	if req.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "new_password is required")
+	}
+
+	if err := s.authService.ChangePassword(ctx, token, req.OldPassword, req.NewPassword, req.RevokeOtherSessions); err != nil {
+		s.logger.Warn("Change password failed", zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.ChangePasswordResponse{
+		Message: "Password changed successfully",
+	}, nil
+}
+
+// ReleaseLockout releases an identifier or IP address lockout before it expires
+func (s *MultiTenantAuthServer) ReleaseLockout(ctx context.Context, req *pb.ReleaseLockoutRequest) (*pb.ReleaseLockoutResponse, error) {
+	s.logger.Info("Release lockout request received",
+		zap.String("tenant_id", req.TenantId),
+		zap.String("identifier", req.Identifier),
+		zap.String("ip_address", req.IpAddress))
+
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+	if req.Identifier == "" && req.IpAddress == "" {
+		return nil, status.Error(codes.InvalidArgument, "identifier or ip_address is required")
+	}
+
+	if _, err := s.authorize(ctx, req.TenantId, "user.write"); err != nil {
+		return nil, err
+	}
+
+	released, err := s.authService.ReleaseLockout(ctx, req.TenantId, req.Identifier, req.IpAddress)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.ReleaseLockoutResponse{
+		Released: released,
+		Message:  "Lockout released",
+	}, nil
+}
+
+// StartOAuthLogin starts a social login with a provider
+func (s *MultiTenantAuthServer) StartOAuthLogin(ctx context.Context, req *pb.StartOAuthLoginRequest) (*pb.StartOAuthResponse, error) {
+	s.logger.Info("Start OAuth login request received",
+		zap.String("provider", req.Provider),
+		zap.String("tenant_id", req.TenantId))
+
+	if req.Provider == "" {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+
+	authorization, err := s.authService.StartOAuthLogin(ctx, req.Provider, req.TenantId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.StartOAuthResponse{
+		AuthorizationUrl: authorization.AuthorizationURL,
+		State:            authorization.State,
+	}, nil
+}
+
+// OAuthCallback completes a social login
+func (s *MultiTenantAuthServer) OAuthCallback(ctx context.Context, req *pb.OAuthCallbackRequest) (*pb.LoginResponse, error) {
+	s.logger.Info("OAuth callback request received", zap.String("provider", req.Provider))
+
+	if req.Provider == "" {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+	if req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+	if req.State == "" {
+		return nil, status.Error(codes.InvalidArgument, "state is required")
+	}
+
+	response, err := s.authService.HandleOAuthCallback(s.withClientInfo(ctx), req.Provider, req.Code, req.State)
+	if err != nil {
+		s.logger.Warn("OAuth login failed", zap.String("provider", req.Provider), zap.Error(err))
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return &pb.LoginResponse{
+		AccessToken:           response.AccessToken,
+		RefreshToken:          response.RefreshToken,
+		TokenType:             response.TokenType,
+		ExpiresIn:             response.ExpiresIn,
+		MfaRequired:           response.MFARequired,
+		MfaEnrollmentRequired: response.MFAEnrollmentRequired,
+		MfaToken:              response.MFAToken,
+	}, nil
+}
+
+// StartOAuthLink starts linking a provider account to the caller
+func (s *MultiTenantAuthServer) StartOAuthLink(ctx context.Context, req *pb.StartOAuthLinkRequest) (*pb.StartOAuthResponse, error) {
+	s.logger.Info("Start OAuth link request received", zap.String("provider", req.Provider))
+
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+	if req.Provider == "" {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+
+	authorization, err := s.authService.StartOAuthLink(ctx, token, req.Provider)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.StartOAuthResponse{
+		AuthorizationUrl: authorization.AuthorizationURL,
+		State:            authorization.State,
+	}, nil
+}
+
+// LinkOAuthAccount completes linking a provider account to the caller
+func (s *MultiTenantAuthServer) LinkOAuthAccount(ctx context.Context, req *pb.LinkOAuthAccountRequest) (*pb.LinkOAuthAccountResponse, error) {
+	s.logger.Info("Link OAuth account request received", zap.String("provider", req.Provider))
+
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+	if req.Provider == "" {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+	if req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+	if req.State == "" {
+		return nil, status.Error(codes.InvalidArgument, "state is required")
+	}
+
+	account, err := s.authService.LinkOAuthAccount(ctx, token, req.Provider, req.Code, req.State)
+	if err != nil {
+		s.logger.Warn("Link OAuth account failed", zap.String("provider", req.Provider), zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.LinkOAuthAccountResponse{
+		Provider:   string(account.Provider),
+		ProviderId: account.ProviderID,
+		Email:      account.Email,
+	}, nil
+}
+
+// UnlinkOAuthAccount removes a provider account from the caller
+func (s *MultiTenantAuthServer) UnlinkOAuthAccount(ctx context.Context, req *pb.UnlinkOAuthAccountRequest) (*pb.UnlinkOAuthAccountResponse, error) {
+	s.logger.Info("Unlink OAuth account request received", zap.String("provider", req.Provider))
+
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+	if req.Provider == "" {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+
+	if err := s.authService.UnlinkOAuthAccount(ctx, token, req.Provider); err != nil {
+		s.logger.Warn("Unlink OAuth account failed", zap.String("provider", req.Provider), zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.UnlinkOAuthAccountResponse{
+		Message: "Account unlinked successfully",
+	}, nil
+}
+
+// RegisterOIDCClient registers an OpenID Connect application for a tenant
+func (s *MultiTenantAuthServer) RegisterOIDCClient(ctx context.Context, req *pb.RegisterOIDCClientRequest) (*pb.RegisterOIDCClientResponse, error) {
+	s.logger.Info("Register OIDC client request received",
+		zap.String("tenant_id", req.TenantId),
+		zap.String("name", req.Name))
+
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if len(req.RedirectUris) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "redirect_uris is required")
+	}
+
+	if _, err := s.authorize(ctx, req.TenantId, "tenant.write"); err != nil {
+		return nil, err
+	}
+
+	client, secret, err := s.authService.RegisterOIDCClient(ctx, req.TenantId, &domain.RegisterOIDCClientRequest{
+		Name:         req.Name,
+		RedirectURIs: req.RedirectUris,
+		Scopes:       req.Scopes,
+		Public:       req.Public,
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.RegisterOIDCClientResponse{
+		Client:       convertOIDCClientToProto(client),
+		ClientSecret: secret,
+	}, nil
+}
+
+// ListOIDCClients lists the OpenID Connect applications of a tenant
+func (s *MultiTenantAuthServer) ListOIDCClients(ctx context.Context, req *pb.ListOIDCClientsRequest) (*pb.ListOIDCClientsResponse, error) {
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+
+	if _, err := s.authorize(ctx, req.TenantId, "tenant.read"); err != nil {
+		return nil, err
+	}
+
+	clients, err := s.authService.ListOIDCClients(ctx, req.TenantId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &pb.ListOIDCClientsResponse{}
+	for _, client := range clients {
+		response.Clients = append(response.Clients, convertOIDCClientToProto(client))
+	}
+	return response, nil
+}
+
+// DeleteOIDCClient removes an OpenID Connect application of a tenant
+func (s *MultiTenantAuthServer) DeleteOIDCClient(ctx context.Context, req *pb.DeleteOIDCClientRequest) (*pb.DeleteOIDCClientResponse, error) {
+	s.logger.Info("Delete OIDC client request received",
+		zap.String("tenant_id", req.TenantId),
+		zap.String("client_id", req.ClientId))
+
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+	if req.ClientId == "" {
+		return nil, status.Error(codes.InvalidArgument, "client_id is required")
+	}
+
+	if _, err := s.authorize(ctx, req.TenantId, "tenant.write"); err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.DeleteOIDCClient(ctx, req.TenantId, req.ClientId); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &pb.DeleteOIDCClientResponse{
+		Message: "Client deleted",
+	}, nil
+}
+
+// CreateAPIKey creates a personal API key for the caller
+func (s *MultiTenantAuthServer) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if len(req.Permissions) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "permissions is required")
+	}
+
+	key, rawKey, err := s.authService.CreateAPIKey(ctx, token, &domain.CreateAPIKeyRequest{
+		Name:          req.Name,
+		Permissions:   req.Permissions,
+		ExpiresInDays: int(req.ExpiresInDays),
+	})
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return &pb.CreateAPIKeyResponse{
+		ApiKey: convertAPIKeyToProto(key),
+		Key:    rawKey,
+	}, nil
+}
+
+// ListAPIKeys lists the caller's API keys
+func (s *MultiTenantAuthServer) ListAPIKeys(ctx context.Context, req *pb.ListAPIKeysRequest) (*pb.ListAPIKeysResponse, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	keys, err := s.authService.ListAPIKeys(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	response := &pb.ListAPIKeysResponse{}
+	for _, key := range keys {
+		response.ApiKeys = append(response.ApiKeys, convertAPIKeyToProto(key))
+	}
+	return response, nil
+}
+
+// RevokeAPIKey revokes one of the caller's API keys
+func (s *MultiTenantAuthServer) RevokeAPIKey(ctx context.Context, req *pb.RevokeAPIKeyRequest) (*pb.RevokeAPIKeyResponse, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+	if req.ApiKeyId == "" {
+		return nil, status.Error(codes.InvalidArgument, "api_key_id is required")
+	}
+
+	if err := s.authService.RevokeAPIKey(ctx, token, req.ApiKeyId); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &pb.RevokeAPIKeyResponse{
+		Message: "API key revoked",
+	}, nil
+}
+
+// BeginPasskeyRegistration starts registering a passkey for the caller
+func (s *MultiTenantAuthServer) BeginPasskeyRegistration(ctx context.Context, req *pb.BeginPasskeyRegistrationRequest) (*pb.PasskeyCeremonyResponse, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	ceremony, err := s.authService.BeginPasskeyRegistration(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return convertPasskeyCeremonyToProto(ceremony)
+}
+
+// FinishPasskeyRegistration stores the passkey created by the browser
+func (s *MultiTenantAuthServer) FinishPasskeyRegistration(ctx context.Context, req *pb.FinishPasskeyRegistrationRequest) (*pb.FinishPasskeyRegistrationResponse, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	if req.CredentialJson == "" {
+		return nil, status.Error(codes.InvalidArgument, "credential_json is required")
+	}
+
+	passkey, err := s.authService.FinishPasskeyRegistration(ctx, token, &domain.FinishPasskeyRegistrationRequest{
+		Token:      req.Token,
+		Name:       req.Name,
+		Credential: json.RawMessage(req.CredentialJson),
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.FinishPasskeyRegistrationResponse{
+		Passkey: convertPasskeyToProto(passkey),
+	}, nil
+}
+
+// BeginPasskeyLogin starts a passwordless login to a tenant
+func (s *MultiTenantAuthServer) BeginPasskeyLogin(ctx context.Context, req *pb.BeginPasskeyLoginRequest) (*pb.PasskeyCeremonyResponse, error) {
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+
+	ceremony, err := s.authService.BeginPasskeyLogin(ctx, req.TenantId)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return convertPasskeyCeremonyToProto(ceremony)
+}
+
+// FinishPasskeyLogin signs in with the browser's passkey assertion
+func (s *MultiTenantAuthServer) FinishPasskeyLogin(ctx context.Context, req *pb.FinishPasskeyLoginRequest) (*pb.LoginResponse, error) {
+	s.logger.Info("Passkey login request received", zap.String("tenant_id", req.TenantId))
+
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+	if req.CredentialJson == "" {
+		return nil, status.Error(codes.InvalidArgument, "credential_json is required")
+	}
+
+	response, err := s.authService.FinishPasskeyLogin(s.withClientInfo(ctx), &domain.FinishPasskeyLoginRequest{
+		Token:      req.Token,
+		TenantID:   req.TenantId,
+		Credential: json.RawMessage(req.CredentialJson),
+	})
+	if err != nil {
+		s.logger.Warn("Passkey login failed", zap.String("tenant_id", req.TenantId), zap.Error(err))
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return &pb.LoginResponse{
+		AccessToken:           response.AccessToken,
+		RefreshToken:          response.RefreshToken,
+		TokenType:             response.TokenType,
+		ExpiresIn:             response.ExpiresIn,
+		MfaRequired:           response.MFARequired,
+		MfaEnrollmentRequired: response.MFAEnrollmentRequired,
+		MfaToken:              response.MFAToken,
+	}, nil
+}
+
+// ListPasskeys lists the caller's passkeys
+func (s *MultiTenantAuthServer) ListPasskeys(ctx context.Context, req *pb.ListPasskeysRequest) (*pb.ListPasskeysResponse, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	passkeys, err := s.authService.ListPasskeys(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	response := &pb.ListPasskeysResponse{}
+	for _, passkey := range passkeys {
+		response.Passkeys = append(response.Passkeys, convertPasskeyToProto(passkey))
+	}
+	return response, nil
+}
+
+// DeletePasskey removes one of the caller's passkeys
+func (s *MultiTenantAuthServer) DeletePasskey(ctx context.Context, req *pb.DeletePasskeyRequest) (*pb.DeletePasskeyResponse, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+	if req.PasskeyId == "" {
+		return nil, status.Error(codes.InvalidArgument, "passkey_id is required")
+	}
+
+	if err := s.authService.DeletePasskey(ctx, token, req.PasskeyId); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &pb.DeletePasskeyResponse{
+		Message: "Passkey deleted",
+	}, nil
+}
+
+// StartPasswordlessLogin sends a one-time code and login link to an email or phone.
+// The response does not reveal whether the account exists.
+func (s *MultiTenantAuthServer) StartPasswordlessLogin(ctx context.Context, req *pb.StartPasswordlessLoginRequest) (*pb.StartPasswordlessLoginResponse, error) {
+	s.logger.Info("Passwordless login request received", zap.String("tenant_id", req.TenantId))
+
+	if req.Identifier == "" {
+		return nil, status.Error(codes.InvalidArgument, "identifier is required")
+	}
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+
+	if err := s.authService.StartPasswordlessLogin(ctx, req.Identifier, req.TenantId, s.clientIP(ctx)); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return &pb.StartPasswordlessLoginResponse{
+		Message: "If the account exists, a login code has been sent",
+	}, nil
+}
+
+// CompletePasswordlessLogin signs in with a one-time code or login link token
+func (s *MultiTenantAuthServer) CompletePasswordlessLogin(ctx context.Context, req *pb.CompletePasswordlessLoginRequest) (*pb.LoginResponse, error) {
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+	if req.Token == "" && (req.Identifier == "" || req.Code == "") {
+		return nil, status.Error(codes.InvalidArgument, "token, or identifier and code, is required")
+	}
+
+	response, err := s.authService.CompletePasswordlessLogin(s.withClientInfo(ctx), req.Identifier, req.Code, req.Token, req.TenantId, s.clientIP(ctx))
+	if err != nil {
+		s.logger.Warn("Passwordless login failed", zap.String("tenant_id", req.TenantId), zap.Error(err))
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return &pb.LoginResponse{
+		AccessToken:           response.AccessToken,
+		RefreshToken:          response.RefreshToken,
+		TokenType:             response.TokenType,
+		ExpiresIn:             response.ExpiresIn,
+		MfaRequired:           response.MFARequired,
+		MfaEnrollmentRequired: response.MFAEnrollmentRequired,
+		MfaToken:              response.MFAToken,
+	}, nil
+}
+
+// CreateServiceAccount creates a service account in a tenant
+func (s *MultiTenantAuthServer) CreateServiceAccount(ctx context.Context, req *pb.CreateServiceAccountRequest) (*pb.CreateServiceAccountResponse, error) {
+	s.logger.Info("Create service account request received",
+		zap.String("tenant_id", req.TenantId),
+		zap.String("name", req.Name))
+
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	caller, err := s.authorize(ctx, req.TenantId, "tenant.write")
+	if err != nil {
+		return nil, err
+	}
+
+	account, secret, err := s.authService.CreateServiceAccount(ctx, req.TenantId, caller, &domain.CreateServiceAccountRequest{
+		Name:        req.Name,
+		Description: req.Description,
+		Roles:       req.Roles,
+	})
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return &pb.CreateServiceAccountResponse{
+		ServiceAccount: convertServiceAccountToProto(account),
+		ClientSecret:   secret,
+	}, nil
+}
+
+// ListServiceAccounts lists the service accounts of a tenant
+func (s *MultiTenantAuthServer) ListServiceAccounts(ctx context.Context, req *pb.ListServiceAccountsRequest) (*pb.ListServiceAccountsResponse, error) {
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+
+	if _, err := s.authorize(ctx, req.TenantId, "tenant.read"); err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.authService.ListServiceAccounts(ctx, req.TenantId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &pb.ListServiceAccountsResponse{}
+	for _, account := range accounts {
+		response.ServiceAccounts = append(response.ServiceAccounts, convertServiceAccountToProto(account))
+	}
+	return response, nil
+}
+
+// DeleteServiceAccount removes a service account of a tenant
+func (s *MultiTenantAuthServer) DeleteServiceAccount(ctx context.Context, req *pb.DeleteServiceAccountRequest) (*pb.DeleteServiceAccountResponse, error) {
+	s.logger.Info("Delete service account request received",
+		zap.String("tenant_id", req.TenantId),
+		zap.String("service_account_id", req.ServiceAccountId))
+
+	if req.TenantId == "" {
+		return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+	}
+	if req.ServiceAccountId == "" {
+		return nil, status.Error(codes.InvalidArgument, "service_account_id is required")
+	}
+
+	if _, err := s.authorize(ctx, req.TenantId, "tenant.write"); err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.DeleteServiceAccount(ctx, req.TenantId, req.ServiceAccountId); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &pb.DeleteServiceAccountResponse{
+		Message: "Service account deleted",
+	}, nil
+}
+
+// ListSessions lists the caller's sessions, or with user_id the sessions of a user in tenant_id for tenant admins
+func (s *MultiTenantAuthServer) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	var sessions []*domain.SessionInfo
+	var err error
+	if req.UserId != "" {
+		if req.TenantId == "" {
+			return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+		}
+		if _, err := s.authorize(ctx, req.TenantId, "user.read"); err != nil {
+			return nil, err
+		}
+		sessions, err = s.authService.ListUserSessions(ctx, req.TenantId, req.UserId)
+	} else {
+		token := bearerToken(ctx)
+		if token == "" {
+			return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+		}
+		sessions, err = s.authService.ListSessions(ctx, token)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	response := &pb.ListSessionsResponse{}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, convertSessionToProto(session))
+	}
+	return response, nil
+}
+
+// RevokeSession signs the caller, or with user_id a user in tenant_id, out of one session
+func (s *MultiTenantAuthServer) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*pb.RevokeSessionResponse, error) {
+	s.logger.Info("Revoke session request received",
+		zap.String("session_id", req.SessionId),
+		zap.String("user_id", req.UserId))
+
+	if req.SessionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id is required")
+	}
+
+	var err error
+	if req.UserId != "" {
+		if req.TenantId == "" {
+			return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+		}
+		if _, err := s.authorize(ctx, req.TenantId, "user.write"); err != nil {
+			return nil, err
+		}
+		err = s.authService.RevokeUserSession(ctx, req.TenantId, req.UserId, req.SessionId)
+	} else {
+		token := bearerToken(ctx)
+		if token == "" {
+			return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+		}
+		err = s.authService.RevokeSession(ctx, token, req.SessionId)
+	}
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &pb.RevokeSessionResponse{
+		Message: "Session revoked",
+	}, nil
+}
+
+// RevokeAllSessions signs the caller, or with user_id a user in tenant_id, out of every session
+func (s *MultiTenantAuthServer) RevokeAllSessions(ctx context.Context, req *pb.RevokeAllSessionsRequest) (*pb.RevokeAllSessionsResponse, error) {
+	s.logger.Info("Revoke all sessions request received",
+		zap.String("user_id", req.UserId),
+		zap.Bool("keep_current", req.KeepCurrent))
+
+	var err error
+	if req.UserId != "" {
+		if req.TenantId == "" {
+			return nil, status.Error(codes.InvalidArgument, "tenant_id is required")
+		}
+		if _, err := s.authorize(ctx, req.TenantId, "user.write"); err != nil {
+			return nil, err
+		}
+		err = s.authService.RevokeAllUserSessions(ctx, req.TenantId, req.UserId)
+	} else {
+		token := bearerToken(ctx)
+		if token == "" {
+			return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+		}
+		err = s.authService.RevokeAllSessions(ctx, token, req.KeepCurrent)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.RevokeAllSessionsResponse{
+		Message: "Sessions revoked",
+	}, nil
+}
+
 // CheckPermission checks if a user has a specific permission
 func (s *MultiTenantAuthServer) CheckPermission(ctx context.Context, req *pb.CheckPermissionRequest) (*pb.CheckPermissionResponse, error) {
 	s.logger.Debug("CheckPermission request",
@@ -303,11 +1122,11 @@ func (s *MultiTenantAuthServer) CheckPermission(ctx context.Context, req *pb.Che
 	}
 
 	return &pb.CheckPermissionResponse{
-		HasPermission: hasPermission,
+		Allowed: hasPermission,
 	}, nil
 }
 
-// GetUserRoles gets all roles for a user in a tenant
+// GetUserRoles gets the roles and permissions of a user in a tenant
 func (s *MultiTenantAuthServer) GetUserRoles(ctx context.Context, req *pb.GetUserRolesRequest) (*pb.GetUserRolesResponse, error) {
 	s.logger.Debug("GetUserRoles request",
 		zap.String("user_id", req.UserId),
@@ -331,25 +1150,108 @@ func (s *MultiTenantAuthServer) GetUserRoles(ctx context.Context, req *pb.GetUse
 		return nil, status.Error(codes.Internal, "failed to get user roles")
 	}
 
+	permissions, err := s.permissionService.GetUserPermissions(ctx, req.UserId, req.TenantId)
+	if err != nil {
+		s.logger.Error("Failed to get user permissions",
+			zap.String("user_id", req.UserId),
+			zap.String("tenant_id", req.TenantId),
+			zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get user roles")
+	}
+
 	return &pb.GetUserRolesResponse{
-		Roles: roles,
+		Roles:       roles,
+		Permissions: permissions,
 	}, nil
 }
 
-// Helper function to convert domain user to proto user
-func convertUserToProto(user *domain.User) *pb.User {
-	if user == nil {
-		return nil
+// Helper function to convert a domain session to a proto session
+func convertSessionToProto(session *domain.SessionInfo) *pb.Session {
+	return &pb.Session{
+		Id:         session.ID,
+		TenantId:   session.TenantID,
+		ClientId:   session.ClientID,
+		Device:     session.Device,
+		IpAddress:  session.IPAddress,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+		LastSeenAt: session.LastSeenAt.Format(time.RFC3339),
+		ExpiresAt:  session.ExpiresAt.Format(time.RFC3339),
+		Current:    session.Current,
 	}
-	return &pb.User{
-		Id:         user.ID.Hex(),
-		Email:      user.Email,
-		Username:   user.Username,
-		Phone:      user.Phone,
-		DocNumber:  user.DocNumber,
-		IsActive:   user.IsActive,
-		IsVerified: user.IsVerified,
-		CreatedAt:  user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		UpdatedAt:  user.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+}
+
+// Helper function to convert a domain API key to a proto API key
+func convertAPIKeyToProto(key *domain.APIKey) *pb.APIKey {
+	result := &pb.APIKey{
+		Id:          key.ID.Hex(),
+		TenantId:    key.TenantID,
+		Name:        key.Name,
+		Prefix:      key.Prefix,
+		Permissions: key.Permissions,
+		CreatedAt:   key.CreatedAt.Format(time.RFC3339),
+	}
+	if key.ExpiresAt != nil {
+		result.ExpiresAt = key.ExpiresAt.Format(time.RFC3339)
+	}
+	if key.LastUsedAt != nil {
+		result.LastUsedAt = key.LastUsedAt.Format(time.RFC3339)
+	}
+	return result
+}
+
+// Helper function to convert a domain passkey to a proto passkey
+func convertPasskeyToProto(passkey *domain.WebAuthnCredential) *pb.Passkey {
+	result := &pb.Passkey{
+		Id:         passkey.ID.Hex(),
+		Name:       passkey.Name,
+		Transports: passkey.Transports,
+		CreatedAt:  passkey.CreatedAt.Format(time.RFC3339),
+	}
+	if passkey.LastUsedAt != nil {
+		result.LastUsedAt = passkey.LastUsedAt.Format(time.RFC3339)
+	}
+	return result
+}
+
+// Helper function to convert a passkey ceremony to proto, with its options as JSON
+func convertPasskeyCeremonyToProto(ceremony *domain.PasskeyCeremony) (*pb.PasskeyCeremonyResponse, error) {
+	options, err := json.Marshal(ceremony.Options)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to encode passkey options")
+	}
+	return &pb.PasskeyCeremonyResponse{
+		Token:       ceremony.Token,
+		OptionsJson: string(options),
+	}, nil
+}
+
+// Helper function to convert a domain service account to a proto service account
+func convertServiceAccountToProto(account *domain.ServiceAccount) *pb.ServiceAccount {
+	result := &pb.ServiceAccount{
+		Id:          account.ID.Hex(),
+		TenantId:    account.TenantID,
+		Name:        account.Name,
+		Description: account.Description,
+		ClientId:    account.ClientID,
+		Roles:       account.Roles,
+		IsActive:    account.IsActive,
+		CreatedAt:   account.CreatedAt.Format(time.RFC3339),
+	}
+	if account.LastUsedAt != nil {
+		result.LastUsedAt = account.LastUsedAt.Format(time.RFC3339)
+	}
+	return result
+}
+
+// Helper function to convert a domain OIDC client to a proto client
+func convertOIDCClientToProto(client *domain.OIDCClient) *pb.OIDCClient {
+	return &pb.OIDCClient{
+		ClientId:     client.ClientID,
+		TenantId:     client.TenantID,
+		Name:         client.Name,
+		RedirectUris: client.RedirectURIs,
+		Scopes:       client.Scopes,
+		Public:       client.Public,
 	}
 }
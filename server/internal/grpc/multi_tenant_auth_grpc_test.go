@@ -0,0 +1,27 @@
+package grpc
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vhvplatform/go-shared/errors"
+)
+
+func TestTokenRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Unknown token", errors.Unauthorized("Invalid or expired token"), true},
+		{"Deactivated user", errors.Forbidden("User account is deactivated"), true},
+		{"Store failure", errors.Internal("Failed to verify token"), false},
+		{"Unexpected error", fmt.Errorf("store: connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tokenRejected(tt.err))
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/service"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// bearerToken extracts the bearer token from the incoming authorization metadata
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+	parts := strings.SplitN(values[0], " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// ParseTrustedProxies parses a comma separated list of the IP addresses and CIDR ranges of the
+// proxies whose X-Forwarded-For header is trusted
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// clientIP returns the caller's IP address. Starting from the peer, X-Forwarded-For is followed
+// from right to left only through the trusted proxies, so a client cannot choose its address.
+func (s *MultiTenantAuthServer) clientIP(ctx context.Context) string {
+	var hops []string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get("x-forwarded-for") {
+			for _, hop := range strings.Split(value, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+	}
+
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remote = p.Addr.String()
+		if host, _, err := net.SplitHostPort(remote); err == nil {
+			remote = host
+		}
+	} else if len(hops) > 0 {
+		// REST requests are passed in-process, and grpc-gateway appends the HTTP peer as last hop
+		remote, hops = hops[len(hops)-1], hops[:len(hops)-1]
+	}
+
+	for len(hops) > 0 && s.trustedProxy(remote) {
+		remote, hops = hops[len(hops)-1], hops[:len(hops)-1]
+	}
+	return remote
+}
+
+// trustedProxy reports whether an address belongs to a trusted proxy
+func (s *MultiTenantAuthServer) trustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range s.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// withClientInfo returns a context that records the caller's device on the sessions it creates
+func (s *MultiTenantAuthServer) withClientInfo(ctx context.Context) context.Context {
+	info := domain.ClientInfo{IPAddress: s.clientIP(ctx)}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		// grpc-gateway forwards the browser's User-Agent with its own prefix
+		for _, key := range []string{"grpcgateway-user-agent", "user-agent"} {
+			if values := md.Get(key); len(values) > 0 {
+				info.UserAgent = values[0]
+				break
+			}
+		}
+		if values := md.Get("x-device-name"); len(values) > 0 {
+			info.Device = values[0]
+		}
+	}
+	return service.WithClientInfo(ctx, info)
+}
+
+// authorize verifies the caller's access token and checks that it grants a permission in the tenant
+func (s *MultiTenantAuthServer) authorize(ctx context.Context, tenantID, permission string) (*domain.ValidateTokenResponse, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	caller, err := s.authService.VerifyToken(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if caller.TenantID != tenantID && caller.TenantID != domain.SystemTenantID {
+		return nil, status.Error(codes.PermissionDenied, "caller does not belong to this tenant")
+	}
+
+	allowed, err := s.callerHasPermission(ctx, caller, permission)
+	if err != nil {
+		s.logger.Error("Failed to check caller permission",
+			zap.String("user_id", caller.UserID),
+			zap.String("permission", permission),
+			zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to check permission")
+	}
+	if !allowed {
+		return nil, status.Error(codes.PermissionDenied, "missing permission "+permission)
+	}
+
+	return caller, nil
+}
+
+// callerHasPermission checks a permission of a verified caller. API keys and tokens issued to
+// clients only have the permissions on the token; user logins have all permissions of the user.
+func (s *MultiTenantAuthServer) callerHasPermission(ctx context.Context, caller *domain.ValidateTokenResponse, permission string) (bool, error) {
+	if caller.Metadata["api_key_id"] != "" || caller.Metadata["client_id"] != "" {
+		return authutils.HasPermission(caller.Permissions, permission), nil
+	}
+	return s.permissionService.CheckPermission(ctx, caller.UserID, caller.TenantID, permission)
+}
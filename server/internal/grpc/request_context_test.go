@@ -0,0 +1,88 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies(" 10.0.0.0/8, 192.168.1.5 ,::1,")
+	require.NoError(t, err)
+	require.Len(t, proxies, 3)
+	assert.True(t, proxies[0].Contains(net.ParseIP("10.1.2.3")))
+	assert.True(t, proxies[1].Contains(net.ParseIP("192.168.1.5")))
+	assert.False(t, proxies[1].Contains(net.ParseIP("192.168.1.6")))
+	assert.True(t, proxies[2].Contains(net.ParseIP("::1")))
+
+	_, err = ParseTrustedProxies("gateway")
+	assert.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8")
+	require.NoError(t, err)
+	server := &MultiTenantAuthServer{trustedProxies: proxies}
+
+	withPeer := func(ctx context.Context, ip string) context.Context {
+		return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 4000}})
+	}
+	withXFF := func(value string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-forwarded-for", value))
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"gRPC peer", withPeer(context.Background(), "203.0.113.7"), "203.0.113.7"},
+		{"gRPC client sets the header", withPeer(withXFF("198.51.100.1"), "203.0.113.7"), "203.0.113.7"},
+		{"gRPC through a trusted proxy", withPeer(withXFF("198.51.100.1"), "10.0.0.2"), "198.51.100.1"},
+		{"REST without proxy", withXFF("203.0.113.7"), "203.0.113.7"},
+		{"REST client sets the header", withXFF("198.51.100.1, 203.0.113.7"), "203.0.113.7"},
+		{"REST through a trusted proxy", withXFF("198.51.100.1, 203.0.113.9, 10.0.0.2"), "203.0.113.9"},
+		{"REST through trusted proxies only", withXFF("10.0.0.3, 10.0.0.2"), "10.0.0.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, server.clientIP(tt.ctx))
+		})
+	}
+}
+
+func TestCallerHasPermission_ScopedTokens(t *testing.T) {
+	// Scoped tokens are checked against their own permissions, never the user's
+	server := &MultiTenantAuthServer{}
+	ctx := context.Background()
+
+	apiKey := &domain.ValidateTokenResponse{
+		UserID:      "user-1",
+		TenantID:    "tenant-1",
+		Permissions: []string{"user.read"},
+		Metadata:    map[string]string{"user_id": "user-1", "api_key_id": "key-1"},
+	}
+	allowed, err := server.callerHasPermission(ctx, apiKey, "user.read")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = server.callerHasPermission(ctx, apiKey, "tenant.write")
+	require.NoError(t, err)
+	assert.False(t, allowed, "a key scoped to user.read cannot manage the tenant")
+
+	oidcClient := &domain.ValidateTokenResponse{
+		UserID:      "user-1",
+		TenantID:    "tenant-1",
+		Permissions: []string{},
+		Metadata:    map[string]string{"user_id": "user-1", "client_id": "portal", "scope": "openid"},
+	}
+	allowed, err = server.callerHasPermission(ctx, oidcClient, "user.read")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"net/textproto"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/vhvplatform/go-auth-service/internal/pb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// NewRESTHandler serves the REST API declared by the google.api.http options of auth.proto.
+// Requests are transcoded and passed to the server in-process, so REST and gRPC share the
+// same validation, errors and responses. JSON uses the proto field names, e.g. access_token.
+func NewRESTHandler(ctx context.Context, server *MultiTenantAuthServer) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+		runtime.WithIncomingHeaderMatcher(restHeaderMatcher),
+	)
+	if err := pb.RegisterAuthServiceHandlerServer(ctx, mux, server); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+// restHeaderMatcher forwards the headers read from gRPC metadata in addition to the defaults.
+// Authorization and X-Forwarded-For are always forwarded by grpc-gateway, which appends the
+// address of the HTTP peer to X-Forwarded-For.
+func restHeaderMatcher(key string) (string, bool) {
+	switch key = textproto.CanonicalMIMEHeaderKey(key); key {
+	case "X-Device-Name":
+		return key, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
@@ -1,91 +0,0 @@
-package handler
-
-import (
-	"net/http"
-
-	"github.com/gin-gonic/gin"
-	"github.com/vhvplatform/go-auth-service/internal/domain"
-	"github.com/vhvplatform/go-auth-service/internal/service"
-	"github.com/vhvplatform/go-shared/logger"
-	"go.uber.org/zap"
-)
-
-// AuthHandler handles authentication HTTP requests
-type AuthHandler struct {
-	authService *service.AuthService
-	logger      *logger.Logger
-}
-
-// NewAuthHandler creates a new auth handler
-func NewAuthHandler(authService *service.AuthService, log *logger.Logger) *AuthHandler {
-	return &AuthHandler{
-		authService: authService,
-		logger:      log,
-	}
-}
-
-// Register handles user registration
-func (h *AuthHandler) Register(c *gin.Context) {
-	var req domain.User
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	user, err := h.authService.Register(c.Request.Context(), &req)
-	if err != nil {
-		h.logger.Error("Registration failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusCreated, user)
-}
-
-// Login handles user login
-func (h *AuthHandler) Login(c *gin.Context) {
-	var req domain.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	resp, err := h.authService.Login(c.Request.Context(), req.Email, req.Password, req.TenantID)
-	if err != nil {
-		h.logger.Warn("Login failed", zap.Error(err))
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, resp)
-}
-
-// Logout handles user logout
-func (h *AuthHandler) Logout(c *gin.Context) {
-	userID := c.GetString("user_id")
-	token := c.GetString("refresh_token")
-
-	if err := h.authService.Logout(c.Request.Context(), userID, token); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
-}
-
-// RefreshToken handles token refresh
-func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	var req domain.RefreshTokenRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	resp, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
-		return
-	}
-
-	c.JSON(http.StatusOK, resp)
-}
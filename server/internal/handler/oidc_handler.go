@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/oidc"
+	"github.com/vhvplatform/go-auth-service/internal/service"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// sessionCookie may carry the user's access token to the authorization endpoint, since browsers
+// cannot add an Authorization header to a redirect
+const sessionCookie = "access_token"
+
+// OIDCHandler serves the OpenID Connect provider endpoints
+type OIDCHandler struct {
+	authService *service.MultiTenantAuthService
+	provider    *oidc.Provider
+	logger      *logger.Logger
+}
+
+// NewOIDCHandler creates a new OpenID Connect handler
+func NewOIDCHandler(authService *service.MultiTenantAuthService, provider *oidc.Provider, log *logger.Logger) *OIDCHandler {
+	return &OIDCHandler{
+		authService: authService,
+		provider:    provider,
+		logger:      log,
+	}
+}
+
+// RegisterRoutes mounts the provider endpoints relative to the issuer
+func (h *OIDCHandler) RegisterRoutes(router gin.IRouter) {
+	router.GET(oidc.DiscoveryPath, h.Discovery)
+	router.GET(oidc.JWKSPath, h.JWKS)
+	router.GET(oidc.AuthorizationPath, h.Authorize)
+	router.POST(oidc.AuthorizationPath, h.Authorize)
+	router.POST(oidc.TokenPath, h.Token)
+	router.GET(oidc.UserInfoPath, h.UserInfo)
+	router.POST(oidc.UserInfoPath, h.UserInfo)
+	router.POST(oidc.IntrospectionPath, h.Introspect)
+	router.POST(oidc.RevocationPath, h.Revoke)
+}
+
+// Discovery serves the OpenID Provider Metadata
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, h.provider.Discovery())
+}
+
+// JWKS serves the public keys that verify ID tokens
+func (h *OIDCHandler) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, h.provider.Keys.JWKS())
+}
+
+// Authorize handles an authorization request. A user who is not signed in is sent to the login page,
+// which signs them in and returns to this request.
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	var req domain.OIDCAuthorizeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, oidc.NewError(oidc.ErrInvalidRequest, err.Error()))
+		return
+	}
+
+	token := bearerToken(c)
+	if token == "" {
+		token, _ = c.Cookie(sessionCookie)
+	}
+
+	redirectURL, err := h.authService.AuthorizeOIDC(c.Request.Context(), token, &req)
+	if err != nil {
+		var oidcErr *oidc.Error
+		if !errors.As(err, &oidcErr) {
+			oidcErr = oidc.NewError(oidc.ErrServerError, "")
+		}
+		if oidcErr.Code == oidc.ErrLoginRequired {
+			returnTo := h.provider.Issuer + oidc.AuthorizationPath + "?" + authorizeQuery(&req).Encode()
+			if loginURL := h.provider.LoginRedirect(returnTo); loginURL != "" {
+				c.Redirect(http.StatusFound, loginURL)
+				return
+			}
+			c.JSON(http.StatusUnauthorized, oidcErr)
+			return
+		}
+		c.JSON(oidcStatus(oidcErr), oidcErr)
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// Token redeems an authorization code, or issues a service account token for the client_credentials
+// grant. Clients authenticate with HTTP Basic or form credentials.
+func (h *OIDCHandler) Token(c *gin.Context) {
+	var req domain.OIDCTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, oidc.NewError(oidc.ErrInvalidRequest, err.Error()))
+		return
+	}
+
+	basicAuth := clientCredentials(c, &req.ClientID, &req.ClientSecret)
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var response *domain.OIDCTokenResponse
+	var err error
+	if req.GrantType == "client_credentials" {
+		response, err = h.authService.ClientCredentialsToken(c.Request.Context(), &req)
+	} else {
+		response, err = h.authService.ExchangeOIDCCode(c.Request.Context(), &req)
+	}
+	if err != nil {
+		h.clientError(c, "token", req.ClientID, basicAuth, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Introspect tells a resource server whether a token is active (RFC 7662). Only confidential clients may call it.
+func (h *OIDCHandler) Introspect(c *gin.Context) {
+	var req domain.TokenIntrospectionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, oidc.NewError(oidc.ErrInvalidRequest, err.Error()))
+		return
+	}
+	basicAuth := clientCredentials(c, &req.ClientID, &req.ClientSecret)
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	response, err := h.authService.IntrospectToken(c.Request.Context(), &req)
+	if err != nil {
+		h.clientError(c, "introspect", req.ClientID, basicAuth, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Revoke revokes an access or refresh token (RFC 7009). Unknown tokens are not an error.
+func (h *OIDCHandler) Revoke(c *gin.Context) {
+	var req domain.TokenRevocationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, oidc.NewError(oidc.ErrInvalidRequest, err.Error()))
+		return
+	}
+	basicAuth := clientCredentials(c, &req.ClientID, &req.ClientSecret)
+
+	if err := h.authService.RevokeOAuthToken(c.Request.Context(), &req); err != nil {
+		h.clientError(c, "revoke", req.ClientID, basicAuth, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// UserInfo returns the claims of the user behind the bearer token
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	claims, err := h.authService.OIDCUserInfo(c.Request.Context(), bearerToken(c))
+	if err != nil {
+		var oidcErr *oidc.Error
+		if !errors.As(err, &oidcErr) {
+			oidcErr = oidc.NewError(oidc.ErrServerError, "")
+		}
+		c.Header("WWW-Authenticate", `Bearer error="`+oidcErr.Code+`"`)
+		c.JSON(oidcStatus(oidcErr), oidcErr)
+		return
+	}
+
+	c.JSON(http.StatusOK, claims)
+}
+
+// clientError responds with the protocol error of a client-authenticated endpoint
+func (h *OIDCHandler) clientError(c *gin.Context, realm, clientID string, basicAuth bool, err error) {
+	var oidcErr *oidc.Error
+	if !errors.As(err, &oidcErr) {
+		oidcErr = oidc.NewError(oidc.ErrServerError, "")
+	}
+	h.logger.Warn("OAuth "+realm+" request failed", zap.String("client_id", clientID), zap.String("error", oidcErr.Error()))
+	if oidcErr.Code == oidc.ErrInvalidClient && basicAuth {
+		c.Header("WWW-Authenticate", `Basic realm="`+realm+`"`)
+	}
+	c.JSON(oidcStatus(oidcErr), oidcErr)
+}
+
+// clientCredentials reads client_secret_basic credentials, which are form-encoded before base64
+// (RFC 6749 2.3.1). It reports whether the client used HTTP Basic.
+func clientCredentials(c *gin.Context, clientID, clientSecret *string) bool {
+	id, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		return false
+	}
+	*clientID, _ = url.QueryUnescape(id)
+	*clientSecret, _ = url.QueryUnescape(secret)
+	return true
+}
+
+// oidcStatus maps a protocol error to its HTTP status
+func oidcStatus(err *oidc.Error) int {
+	switch err.Code {
+	case oidc.ErrInvalidClient, oidc.ErrInvalidToken, oidc.ErrLoginRequired:
+		return http.StatusUnauthorized
+	case oidc.ErrServerError:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// authorizeQuery rebuilds the query of an authorization request
+func authorizeQuery(req *domain.OIDCAuthorizeRequest) url.Values {
+	query := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	set("client_id", req.ClientID)
+	set("redirect_uri", req.RedirectURI)
+	set("response_type", req.ResponseType)
+	set("scope", req.Scope)
+	set("state", req.State)
+	set("nonce", req.Nonce)
+	set("code_challenge", req.CodeChallenge)
+	set("code_challenge_method", req.CodeChallengeMethod)
+	set("prompt", req.Prompt)
+	return query
+}
+
+// bearerToken extracts the bearer token from the Authorization header
+func bearerToken(c *gin.Context) string {
+	parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
@@ -0,0 +1,129 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Token verification errors
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrMissingExpiry    = errors.New("token has no expiry")
+)
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid"`
+}
+
+// sign encodes claims as a compact JWS with the key's algorithm and kid
+func sign(key *Key, claims interface{}) (string, error) {
+	encodedHeader, err := json.Marshal(header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode claims: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := key.sign([]byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsed is a token split into its parts
+type parsed struct {
+	header       header
+	payload      []byte
+	signature    []byte
+	signingInput []byte
+}
+
+func parse(token string) (*parsed, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	var h header
+	if err := json.Unmarshal(rawHeader, &h); err != nil {
+		return nil, ErrMalformedToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	return &parsed{
+		header:       h,
+		payload:      payload,
+		signature:    signature,
+		signingInput: []byte(parts[0] + "." + parts[1]),
+	}, nil
+}
+
+// verify checks the signature with a public key, then decodes the claims and rejects tokens that
+// have expired or have no expiry
+func (p *parsed) verify(algorithm string, public crypto.PublicKey, claims interface{}) error {
+	// The algorithm comes from the key, never from the token header alone
+	if p.header.Algorithm != algorithm {
+		return ErrInvalidSignature
+	}
+
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(p.signingInput)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], p.signature) != nil {
+			return ErrInvalidSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, p.signingInput, p.signature) {
+			return ErrInvalidSignature
+		}
+	default:
+		return ErrUnknownKey
+	}
+
+	var registered struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(p.payload, &registered); err != nil {
+		return ErrMalformedToken
+	}
+	// Tokens without an expiry would be valid for as long as their key is retained
+	if registered.ExpiresAt == 0 {
+		return ErrMissingExpiry
+	}
+	if time.Now().Unix() > registered.ExpiresAt {
+		return ErrTokenExpired
+	}
+
+	if err := json.Unmarshal(p.payload, claims); err != nil {
+		return fmt.Errorf("failed to decode claims: %w", err)
+	}
+	return nil
+}
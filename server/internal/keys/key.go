@@ -0,0 +1,160 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Key is a private signing key identified by its kid
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	private   crypto.Signer
+}
+
+// GenerateKey creates a new signing key for an algorithm
+func GenerateKey(algorithm string) (*Key, error) {
+	var private crypto.Signer
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		private = key
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	return NewKey(private, time.Now())
+}
+
+// NewKey wraps an RSA or Ed25519 private key. The kid is the RFC 7638 thumbprint of its public key.
+func NewKey(private crypto.Signer, createdAt time.Time) (*Key, error) {
+	key := &Key{private: private, CreatedAt: createdAt}
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm = AlgorithmRS256
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgorithmEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+	key.ID = key.JWK().thumbprint()
+	return key, nil
+}
+
+// Public returns the public key
+func (k *Key) Public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// JWK returns the public key as a JSON Web Key
+func (k *Key) JWK() JSONWebKey {
+	jwk := JSONWebKey{Use: "sig", Algorithm: k.Algorithm, KeyID: k.ID}
+	switch public := k.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+func (k *Key) sign(signingInput []byte) ([]byte, error) {
+	if k.Algorithm == AlgorithmEdDSA {
+		return k.private.Sign(rand.Reader, signingInput, crypto.Hash(0))
+	}
+	digest := sha256.Sum256(signingInput)
+	return k.private.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// JSONWebKey is the public part of a signing key (RFC 7517, RFC 8037)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet is served at a jwks_uri
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// PublicKey decodes the public key of a JWK
+func (jwk JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+	}
+}
+
+// algorithm returns the JWK's alg, or the one implied by its key type when alg is omitted
+func (jwk JSONWebKey) algorithm() string {
+	if jwk.Algorithm != "" {
+		return jwk.Algorithm
+	}
+	if jwk.KeyType == "OKP" {
+		return AlgorithmEdDSA
+	}
+	return AlgorithmRS256
+}
+
+// thumbprint computes the RFC 7638 thumbprint over the required members in lexicographic order
+func (jwk JSONWebKey) thumbprint() string {
+	var canonical string
+	if jwk.KeyType == "OKP" {
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, jwk.Curve, jwk.X)
+	} else {
+		canonical = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk.E, jwk.N)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
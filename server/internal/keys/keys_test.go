@@ -0,0 +1,200 @@
+package keys
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// validClaims are claims for a subject that expire in an hour
+func validClaims(subject string) testClaims {
+	return testClaims{Subject: subject, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+}
+
+func newKeySet(t *testing.T, algorithm string) *KeySet {
+	config := DefaultConfig()
+	config.Algorithm = algorithm
+	keySet := NewKeySet(NewMemoryStore(), config)
+	require.NoError(t, keySet.Load(context.Background()))
+	return keySet
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			keySet := newKeySet(t, algorithm)
+
+			token, err := keySet.Sign(validClaims("user-1"))
+			require.NoError(t, err)
+
+			var claims testClaims
+			require.NoError(t, keySet.Verify(token, &claims))
+			assert.Equal(t, "user-1", claims.Subject)
+
+			jwks := keySet.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, algorithm, jwks.Keys[0].Algorithm)
+		})
+	}
+}
+
+func TestKeySet_RejectsExpiredAndForeignTokens(t *testing.T) {
+	keySet := newKeySet(t, AlgorithmEdDSA)
+	other := newKeySet(t, AlgorithmEdDSA)
+
+	expired, err := keySet.Sign(testClaims{Subject: "user-1", ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	require.NoError(t, err)
+	assert.ErrorIs(t, keySet.Verify(expired, &testClaims{}), ErrTokenExpired)
+
+	foreign, err := other.Sign(validClaims("user-1"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, keySet.Verify(foreign, &testClaims{}), ErrUnknownKey)
+
+	unlimited, err := keySet.Sign(testClaims{Subject: "user-1"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, keySet.Verify(unlimited, &testClaims{}), ErrMissingExpiry)
+}
+
+func TestKeySet_RotationKeepsPreviousKeyForVerification(t *testing.T) {
+	ctx := context.Background()
+	keySet := newKeySet(t, AlgorithmRS256)
+
+	before, err := keySet.Sign(validClaims("user-1"))
+	require.NoError(t, err)
+
+	require.NoError(t, keySet.Rotate(ctx))
+	after, err := keySet.Sign(validClaims("user-1"))
+	require.NoError(t, err)
+
+	assert.NoError(t, keySet.Verify(before, &testClaims{}))
+	assert.NoError(t, keySet.Verify(after, &testClaims{}))
+	assert.Len(t, keySet.JWKS().Keys, 2)
+}
+
+func TestKeySet_RotationPublishesKeyBeforeSigning(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	keySet := NewKeySet(store, DefaultConfig())
+	require.NoError(t, keySet.Load(ctx))
+	first := keySet.JWKS().Keys[0].KeyID
+
+	require.NoError(t, keySet.Rotate(ctx))
+	next := keySet.JWKS().Keys[0].KeyID
+	require.NotEqual(t, first, next)
+
+	// Other instances load the new key before any token is signed with it
+	token, err := keySet.Sign(validClaims("user-1"))
+	require.NoError(t, err)
+	assert.Equal(t, first, signingKeyID(t, token))
+	other := NewKeySet(store, DefaultConfig())
+	require.NoError(t, other.Load(ctx))
+	assert.Len(t, other.JWKS().Keys, 2)
+
+	// A pending key is not rotated again
+	require.NoError(t, keySet.RotateIfDue(ctx))
+	assert.Len(t, keySet.JWKS().Keys, 2)
+
+	keySet.keys[0].CreatedAt = time.Now().Add(-DefaultConfig().PublishAhead)
+	token, err = keySet.Sign(validClaims("user-1"))
+	require.NoError(t, err)
+	assert.Equal(t, next, signingKeyID(t, token))
+	assert.NoError(t, other.Verify(token, &testClaims{}))
+}
+
+func signingKeyID(t *testing.T, token string) string {
+	p, err := parse(token)
+	require.NoError(t, err)
+	return p.header.KeyID
+}
+
+func TestKeySet_PrunesKeysAfterRetention(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	old, err := GenerateKey(AlgorithmEdDSA)
+	require.NoError(t, err)
+	old.CreatedAt = time.Now().Add(-60 * 24 * time.Hour)
+	replacement, err := GenerateKey(AlgorithmEdDSA)
+	require.NoError(t, err)
+	replacement.CreatedAt = time.Now().Add(-31 * 24 * time.Hour)
+	require.NoError(t, store.Save(ctx, old))
+	require.NoError(t, store.Save(ctx, replacement))
+
+	config := DefaultConfig()
+	config.Algorithm = AlgorithmEdDSA
+	keySet := NewKeySet(store, config)
+
+	// The replacement is due for rotation; the old key was replaced more than a week ago
+	require.NoError(t, keySet.RotateIfDue(ctx))
+
+	jwks := keySet.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.NotEqual(t, old.ID, jwks.Keys[0].KeyID)
+	assert.Equal(t, replacement.ID, jwks.Keys[1].KeyID)
+
+	stored, err := store.Load(ctx)
+	require.NoError(t, err)
+	assert.Len(t, stored, 2)
+}
+
+func TestFileStore_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		key, err := GenerateKey(algorithm)
+		require.NoError(t, err)
+		require.NoError(t, store.Save(ctx, key))
+	}
+
+	// A second instance sharing the directory signs with the same keys
+	first := NewKeySet(store, DefaultConfig())
+	require.NoError(t, first.Load(ctx))
+	second := NewKeySet(store, DefaultConfig())
+	require.NoError(t, second.Load(ctx))
+
+	token, err := first.Sign(validClaims("user-1"))
+	require.NoError(t, err)
+	assert.NoError(t, second.Verify(token, &testClaims{}))
+	assert.Len(t, second.JWKS().Keys, 2)
+}
+
+func TestRemoteKeySet_PicksUpRotatedKeys(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.Algorithm = AlgorithmEdDSA
+	config.PublishAhead = 0 // Sign with a new key at once, as if it had been published long ago
+	keySet := NewKeySet(NewMemoryStore(), config)
+	require.NoError(t, keySet.Load(ctx))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(keySet.JWKS())
+	}))
+	defer server.Close()
+	remote := NewRemoteKeySet(server.URL)
+
+	token, err := keySet.Sign(validClaims("user-1"))
+	require.NoError(t, err)
+	var claims testClaims
+	require.NoError(t, remote.Verify(ctx, token, &claims))
+	assert.Equal(t, "user-1", claims.Subject)
+
+	// Allow an immediate refetch for the new kid
+	remote.fetchedAt = time.Time{}
+	require.NoError(t, keySet.Rotate(ctx))
+	rotated, err := keySet.Sign(validClaims("user-2"))
+	require.NoError(t, err)
+	assert.NoError(t, remote.Verify(ctx, rotated, &claims))
+}
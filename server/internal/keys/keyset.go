@@ -0,0 +1,263 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReloadInterval is how often instances sharing a key store run RotateIfDue, which picks up keys
+// generated by the others
+const ReloadInterval = time.Hour
+
+// Config controls the algorithm and rotation of a key set
+type Config struct {
+	Algorithm        string
+	RotationInterval time.Duration // A new signing key is generated once the newest one is this old
+	PublishAhead     time.Duration // A new key is published this long before it signs; must exceed ReloadInterval plus how long verifiers cache the JWKS
+	Retention        time.Duration // Replaced keys stay published for verification this long; must exceed the longest token lifetime
+}
+
+// DefaultConfig rotates RS256 keys monthly, publishes each key two hours before it signs and keeps
+// replaced keys for a week
+func DefaultConfig() Config {
+	return Config{
+		Algorithm:        AlgorithmRS256,
+		RotationInterval: 30 * 24 * time.Hour,
+		PublishAhead:     2 * time.Hour,
+		Retention:        7 * 24 * time.Hour,
+	}
+}
+
+// KeySet signs with its newest key and verifies with every key that is still retained
+type KeySet struct {
+	mu     sync.RWMutex
+	store  Store
+	config Config
+	keys   []*Key // Newest first
+}
+
+// NewKeySet creates a key set backed by a store. Call Load before signing.
+func NewKeySet(store Store, config Config) *KeySet {
+	return &KeySet{store: store, config: config}
+}
+
+// LoadFromEnv creates and loads a key set configured by <prefix>_KEYS_DIR, <prefix>_KEY_ALGORITHM,
+// <prefix>_KEY_ROTATION, <prefix>_KEY_PUBLISH_AHEAD and <prefix>_KEY_RETENTION (Go durations, e.g. "720h").
+// Without a directory the keys are kept in memory and change on every restart.
+func LoadFromEnv(ctx context.Context, prefix string) (*KeySet, error) {
+	config := DefaultConfig()
+	if algorithm := os.Getenv(prefix + "_KEY_ALGORITHM"); algorithm != "" {
+		config.Algorithm = algorithm
+	}
+	for env, target := range map[string]*time.Duration{
+		prefix + "_KEY_ROTATION":      &config.RotationInterval,
+		prefix + "_KEY_PUBLISH_AHEAD": &config.PublishAhead,
+		prefix + "_KEY_RETENTION":     &config.Retention,
+	} {
+		if value := os.Getenv(env); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+			*target = duration
+		}
+	}
+
+	var store Store = NewMemoryStore()
+	if dir := os.Getenv(prefix + "_KEYS_DIR"); dir != "" {
+		fileStore, err := NewFileStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		store = fileStore
+	}
+
+	keySet := NewKeySet(store, config)
+	if err := keySet.Load(ctx); err != nil {
+		return nil, err
+	}
+	return keySet, nil
+}
+
+// Algorithm returns the algorithm new keys are generated for
+func (ks *KeySet) Algorithm() string {
+	return ks.config.Algorithm
+}
+
+// Load reads the keys from the store and generates the first key when there is none
+func (ks *KeySet) Load(ctx context.Context) error {
+	keys, err := ks.store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	ks.setKeys(keys)
+
+	if ks.current() == nil {
+		return ks.Rotate(ctx)
+	}
+	return nil
+}
+
+// Rotate generates the next signing key. It is published at once but only signs once it is older
+// than PublishAhead, so every instance and verifier has loaded it by then. Previous keys keep
+// verifying until their retention ends.
+func (ks *KeySet) Rotate(ctx context.Context) error {
+	key, err := GenerateKey(ks.config.Algorithm)
+	if err != nil {
+		return err
+	}
+	if err := ks.store.Save(ctx, key); err != nil {
+		return fmt.Errorf("failed to save signing key: %w", err)
+	}
+
+	ks.mu.Lock()
+	ks.keys = append([]*Key{key}, ks.keys...)
+	ks.mu.Unlock()
+
+	return ks.prune(ctx)
+}
+
+// RotateIfDue reloads the keys, which picks up rotations by other instances, and rotates when the newest key is due
+func (ks *KeySet) RotateIfDue(ctx context.Context) error {
+	if err := ks.Load(ctx); err != nil {
+		return err
+	}
+	newest := ks.newest()
+	if newest != nil && time.Since(newest.CreatedAt) < ks.config.RotationInterval {
+		return ks.prune(ctx)
+	}
+	return ks.Rotate(ctx)
+}
+
+// Run checks for due rotations every interval until the context ends
+func (ks *KeySet) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.RotateIfDue(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Sign encodes claims as a JWT signed by the current key
+func (ks *KeySet) Sign(claims interface{}) (string, error) {
+	key := ks.current()
+	if key == nil {
+		return "", errors.New("no signing key loaded")
+	}
+	return sign(key, claims)
+}
+
+// Verify checks a token signed by any retained key and decodes its claims
+func (ks *KeySet) Verify(token string, claims interface{}) error {
+	p, err := parse(token)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.RLock()
+	var key *Key
+	for _, k := range ks.keys {
+		if k.ID == p.header.KeyID {
+			key = k
+			break
+		}
+	}
+	ks.mu.RUnlock()
+
+	if key == nil {
+		return ErrUnknownKey
+	}
+	return p.verify(key.Algorithm, key.Public(), claims)
+}
+
+// JWKS returns the public keys of every retained key, newest first
+func (ks *KeySet) JWKS() *JSONWebKeySet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := &JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
+
+// current returns the signing key: the newest key published for at least PublishAhead. The first
+// key of a set signs at once, since no token exists yet that another key could have signed.
+func (ks *KeySet) current() *Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if len(ks.keys) == 0 {
+		return nil
+	}
+	for _, key := range ks.keys {
+		if ks.active(key) {
+			return key
+		}
+	}
+	return ks.keys[len(ks.keys)-1]
+}
+
+// newest returns the most recently generated key, which may not sign yet
+func (ks *KeySet) newest() *Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if len(ks.keys) == 0 {
+		return nil
+	}
+	return ks.keys[0]
+}
+
+// active reports whether a key has been published long enough to sign
+func (ks *KeySet) active(key *Key) bool {
+	return time.Since(key.CreatedAt) >= ks.config.PublishAhead
+}
+
+func (ks *KeySet) setKeys(keys []*Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+}
+
+// prune deletes keys that were replaced longer than the retention ago
+func (ks *KeySet) prune(ctx context.Context) error {
+	ks.mu.Lock()
+	var expired []*Key
+	kept := ks.keys[:0:0]
+	for i, key := range ks.keys {
+		// A key is replaced when the next newer key starts signing
+		if i > 0 && time.Since(ks.keys[i-1].CreatedAt) > ks.config.PublishAhead+ks.config.Retention {
+			expired = append(expired, key)
+			continue
+		}
+		kept = append(kept, key)
+	}
+	ks.keys = kept
+	ks.mu.Unlock()
+
+	for _, key := range expired {
+		if err := ks.store.Delete(ctx, key.ID); err != nil {
+			return fmt.Errorf("failed to delete expired signing key: %w", err)
+		}
+	}
+	return nil
+}
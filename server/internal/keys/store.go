@@ -0,0 +1,175 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store persists signing keys so that every instance of a service signs with the same keys
+type Store interface {
+	Load(ctx context.Context) ([]*Key, error)
+	Save(ctx context.Context, key *Key) error
+	Delete(ctx context.Context, keyID string) error
+}
+
+// MemoryStore keeps keys in memory. Keys are lost on restart, so it suits tests and single-instance development.
+type MemoryStore struct {
+	mu   sync.Mutex
+	keys map[string]*Key
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: map[string]*Key{}}
+}
+
+// Load returns all stored keys
+func (s *MemoryStore) Load(ctx context.Context) ([]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Save stores a key
+func (s *MemoryStore) Save(ctx context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = key
+	return nil
+}
+
+// Delete removes a key
+func (s *MemoryStore) Delete(ctx context.Context, keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, keyID)
+	return nil
+}
+
+// FileStore keeps each key as a PKCS#8 PEM file in a directory, which may be a volume shared by all instances
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store in a directory, creating it when missing
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Load reads every key file in the directory
+func (s *FileStore) Load(ctx context.Context) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		key, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Save writes a key file. The file is written under a temporary name and renamed so readers never see a partial key.
+func (s *FileStore) Save(ctx context.Context, key *Key) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{"Created-At": key.CreatedAt.UTC().Format(time.RFC3339)},
+		Bytes:   der,
+	})
+
+	path := s.path(key.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a key file
+func (s *FileStore) Delete(ctx context.Context, keyID string) error {
+	if err := os.Remove(s.path(keyID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) path(keyID string) string {
+	// Key IDs are base64url thumbprints, so they are safe file names
+	return filepath.Join(s.dir, keyID+".pem")
+}
+
+func readKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %w", filepath.Base(path), err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode key %s: no PEM data", filepath.Base(path))
+	}
+
+	var private interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s: %w", filepath.Base(path), err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse key %s: not a signing key", filepath.Base(path))
+	}
+
+	// Keys placed by hand may lack the header; the file time stands in for the creation time
+	createdAt, err := time.Parse(time.RFC3339, block.Headers["Created-At"])
+	if err != nil {
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", filepath.Base(path), statErr)
+		}
+		createdAt = info.ModTime()
+	}
+
+	key, err := NewKey(signer, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key %s: %w", filepath.Base(path), err)
+	}
+	if name := strings.TrimSuffix(filepath.Base(path), ".pem"); name != key.ID {
+		// Hand-placed keys keep their file name as kid so existing tokens still verify
+		key.ID = name
+	}
+	return key, nil
+}
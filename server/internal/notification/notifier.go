@@ -0,0 +1,101 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vhvplatform/go-shared/logger"
+	"go.uber.org/zap"
+)
+
+// Channel identifies how a message reaches the user
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+)
+
+// Templates rendered by the delivery side
+const (
+	TemplatePasswordReset     = "password_reset"
+	TemplatePasswordChanged   = "password_changed"
+	TemplateVerification      = "verification"
+	TemplatePasswordlessLogin = "passwordless_login"
+)
+
+// Message is a templated notification for a single recipient.
+// Data holds the template values, e.g. the reset token and its expiry.
+type Message struct {
+	Channel  Channel           `json:"channel"`
+	To       string            `json:"to"`
+	Template string            `json:"template"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+// Notifier delivers messages to users
+type Notifier interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// LogNotifier writes messages to the service log instead of delivering them.
+// It is meant for local development only, since messages may contain secrets such as reset tokens.
+type LogNotifier struct {
+	logger *logger.Logger
+}
+
+// NewLogNotifier creates a notifier that logs every message
+func NewLogNotifier(log *logger.Logger) *LogNotifier {
+	return &LogNotifier{logger: log}
+}
+
+// Send logs the message
+func (n *LogNotifier) Send(ctx context.Context, msg *Message) error {
+	n.logger.Info("Notification",
+		zap.String("channel", string(msg.Channel)),
+		zap.String("to", msg.To),
+		zap.String("template", msg.Template),
+		zap.Any("data", msg.Data))
+	return nil
+}
+
+// FileNotifier appends every message as a JSON line to a file, so local tools and tests
+// can pick up codes and links. Like LogNotifier it is not meant for production.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileNotifier creates a notifier that writes to the given file
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// Send appends the message to the file
+func (n *FileNotifier) Send(ctx context.Context, msg *Message) error {
+	line, err := json.Marshal(struct {
+		*Message
+		SentAt time.Time `json:"sent_at"`
+	}{msg, time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package notification
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileNotifier_AppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	notifier := NewFileNotifier(path)
+
+	require.NoError(t, notifier.Send(context.Background(), &Message{
+		Channel:  ChannelEmail,
+		To:       "user@example.com",
+		Template: TemplateVerification,
+		Data:     map[string]string{"code": "123456"},
+	}))
+	require.NoError(t, notifier.Send(context.Background(), &Message{
+		Channel:  ChannelSMS,
+		To:       "+84901234567",
+		Template: TemplateVerification,
+		Data:     map[string]string{"code": "654321"},
+	}))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var messages []Message
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var msg Message
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &msg))
+		messages = append(messages, msg)
+	}
+
+	require.Len(t, messages, 2)
+	assert.Equal(t, ChannelEmail, messages[0].Channel)
+	assert.Equal(t, "123456", messages[0].Data["code"])
+	assert.Equal(t, ChannelSMS, messages[1].Channel)
+	assert.Equal(t, "+84901234567", messages[1].To)
+}
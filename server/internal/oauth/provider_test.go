@@ -0,0 +1,159 @@
+package oauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockIdP is a minimal authorization server that enforces PKCE and echoes the nonce in the ID token
+type mockIdP struct {
+	server    *httptest.Server
+	challenge string
+	nonce     string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	idp := &mockIdP{}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.Form.Get("code") != "good-code" || CodeChallengeS256(r.Form.Get("code_verifier")) != idp.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		claims, _ := json.Marshal(map[string]interface{}{
+			"iss":   idp.server.URL,
+			"aud":   "client-id",
+			"nonce": idp.nonce,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+		idToken := "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "idp-access-token",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer idp-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"sub":            "user-123",
+			"email":          "user@example.com",
+			"email_verified": true,
+			"name":           "Test User",
+		})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) client() *Client {
+	return NewClient(ProviderConfig{
+		Name:         "mock",
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost/callback",
+		Issuer:       idp.server.URL,
+		AuthURL:      idp.server.URL + "/authorize",
+		TokenURL:     idp.server.URL + "/token",
+		UserInfoURL:  idp.server.URL + "/userinfo",
+		Scopes:       []string{"openid", "email"},
+	})
+}
+
+func TestClient_AuthorizationCodeFlowWithPKCE(t *testing.T) {
+	idp := newMockIdP(t)
+	client := idp.client()
+
+	verifier, challenge, err := GeneratePKCE()
+	require.NoError(t, err)
+	idp.challenge = challenge
+	idp.nonce = "nonce-1"
+
+	authURL, err := url.Parse(client.AuthCodeURL("state-1", "nonce-1", challenge))
+	require.NoError(t, err)
+	query := authURL.Query()
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, "state-1", query.Get("state"))
+	assert.Equal(t, "nonce-1", query.Get("nonce"))
+	assert.Equal(t, challenge, query.Get("code_challenge"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+
+	token, err := client.Exchange(context.Background(), "good-code", verifier)
+	require.NoError(t, err)
+	require.NoError(t, client.VerifyIDToken(token.IDToken, "nonce-1"))
+	assert.Error(t, client.VerifyIDToken(token.IDToken, "other-nonce"))
+
+	info, err := client.FetchUserInfo(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", info.Subject)
+	assert.Equal(t, "user@example.com", info.Email)
+	assert.True(t, info.EmailVerified)
+}
+
+func TestClient_VerifyIDToken(t *testing.T) {
+	client := NewClient(ProviderConfig{Name: "mock", ClientID: "client-id", Issuer: "https://idp.example.com"})
+	idToken := func(claims map[string]interface{}) string {
+		payload, _ := json.Marshal(claims)
+		return "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+	}
+
+	assert.NoError(t, client.VerifyIDToken(idToken(map[string]interface{}{
+		"iss": "https://idp.example.com", "aud": "client-id", "nonce": "n",
+	}), "n"))
+	assert.Error(t, client.VerifyIDToken("", "n"), "an OIDC provider must return an id token")
+	assert.Error(t, client.VerifyIDToken(idToken(map[string]interface{}{
+		"iss": "https://evil.example.com", "aud": "client-id", "nonce": "n",
+	}), "n"))
+	assert.Error(t, client.VerifyIDToken(idToken(map[string]interface{}{
+		"aud": "client-id", "nonce": "n",
+	}), "n"))
+
+	github := NewClient(ProviderConfig{Name: "github", Kind: KindGitHub, ClientID: "client-id"})
+	assert.NoError(t, github.VerifyIDToken("", ""))
+}
+
+func TestClient_ExchangeRejectsWrongVerifier(t *testing.T) {
+	idp := newMockIdP(t)
+	client := idp.client()
+
+	_, challenge, err := GeneratePKCE()
+	require.NoError(t, err)
+	idp.challenge = challenge
+
+	_, err = client.Exchange(context.Background(), "good-code", "wrong-verifier")
+	assert.Error(t, err)
+}
+
+func TestCodeChallengeS256_RFC7636Vector(t *testing.T) {
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		CodeChallengeS256("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
+
+func TestProviders_SkipsUnconfigured(t *testing.T) {
+	providers := NewProviders(
+		ProviderConfig{Name: "google", ClientID: "id"},
+		ProviderConfig{Name: "github"},
+	)
+	assert.NotNil(t, providers.Get("google"))
+	assert.Nil(t, providers.Get("github"))
+
+	var none *Providers
+	assert.Nil(t, none.Get("google"))
+}
@@ -0,0 +1,33 @@
+package oidc
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider_Discovery(t *testing.T) {
+	provider := NewProvider("https://auth.example.com/", "", nil)
+	config := provider.Discovery()
+
+	assert.Equal(t, "https://auth.example.com", config.Issuer)
+	assert.Equal(t, "https://auth.example.com/authorize", config.AuthorizationEndpoint)
+	assert.Equal(t, "https://auth.example.com/token", config.TokenEndpoint)
+	assert.Equal(t, "https://auth.example.com/userinfo", config.UserInfoEndpoint)
+	assert.Equal(t, "https://auth.example.com/.well-known/jwks.json", config.JWKSURI)
+	assert.Equal(t, "https://auth.example.com/oauth/introspect", config.IntrospectionEndpoint)
+	assert.Equal(t, "https://auth.example.com/oauth/revoke", config.RevocationEndpoint)
+	assert.Equal(t, []string{"S256"}, config.CodeChallengeMethodsSupported)
+}
+
+func TestRedirectError_KeepsState(t *testing.T) {
+	redirect, err := url.Parse(RedirectError("https://app.example.com/cb?x=1", "state-1", NewError(ErrAccessDenied, "denied")))
+	require.NoError(t, err)
+
+	query := redirect.Query()
+	assert.Equal(t, "1", query.Get("x"))
+	assert.Equal(t, ErrAccessDenied, query.Get("error"))
+	assert.Equal(t, "state-1", query.Get("state"))
+}
@@ -0,0 +1,197 @@
+package oidc
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/keys"
+)
+
+// Scopes understood by the provider
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopePhone   = "phone"
+)
+
+// SupportedScopes lists the scopes a client may be registered for
+var SupportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopePhone}
+
+// Endpoint paths, relative to the issuer
+const (
+	DiscoveryPath     = "/.well-known/openid-configuration"
+	JWKSPath          = "/.well-known/jwks.json"
+	AuthorizationPath = "/authorize"
+	TokenPath         = "/token"
+	UserInfoPath      = "/userinfo"
+	IntrospectionPath = "/oauth/introspect"
+	RevocationPath    = "/oauth/revoke"
+)
+
+// Provider holds the issuer settings of the OpenID Connect provider
+type Provider struct {
+	Issuer     string
+	LoginURL   string       // Page that signs the user in and sends them back to return_to
+	Keys       *keys.KeySet // Signs ID tokens; its public keys are served at the jwks_uri
+	IDTokenTTL time.Duration
+}
+
+// NewProvider creates a provider for an issuer URL
+func NewProvider(issuer, loginURL string, keySet *keys.KeySet) *Provider {
+	return &Provider{
+		Issuer:     strings.TrimRight(issuer, "/"),
+		LoginURL:   loginURL,
+		Keys:       keySet,
+		IDTokenTTL: time.Hour,
+	}
+}
+
+// LoadProviderFromEnv configures the provider from OIDC_ISSUER and OIDC_LOGIN_URL
+func LoadProviderFromEnv(keySet *keys.KeySet) *Provider {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		issuer = "http://localhost:8081"
+	}
+	return NewProvider(issuer, os.Getenv("OIDC_LOGIN_URL"), keySet)
+}
+
+// Configuration is the OpenID Provider Metadata served at the discovery endpoint
+type Configuration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// Discovery returns the provider metadata
+func (p *Provider) Discovery() *Configuration {
+	algorithm := keys.AlgorithmRS256
+	if p.Keys != nil {
+		algorithm = p.Keys.Algorithm()
+	}
+
+	return &Configuration{
+		Issuer:                            p.Issuer,
+		AuthorizationEndpoint:             p.Issuer + AuthorizationPath,
+		TokenEndpoint:                     p.Issuer + TokenPath,
+		UserInfoEndpoint:                  p.Issuer + UserInfoPath,
+		JWKSURI:                           p.Issuer + JWKSPath,
+		IntrospectionEndpoint:             p.Issuer + IntrospectionPath,
+		RevocationEndpoint:                p.Issuer + RevocationPath,
+		ScopesSupported:                   SupportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{algorithm},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "tenant_id",
+			"preferred_username", "email", "email_verified", "phone_number", "phone_number_verified",
+		},
+	}
+}
+
+// LoginRedirect builds the login page URL that returns to the given authorization request
+func (p *Provider) LoginRedirect(returnTo string) string {
+	if p.LoginURL == "" {
+		return ""
+	}
+	separator := "?"
+	if strings.Contains(p.LoginURL, "?") {
+		separator = "&"
+	}
+	return p.LoginURL + separator + url.Values{"return_to": {returnTo}}.Encode()
+}
+
+// ParseScope splits a space separated scope parameter
+func ParseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// HasScope reports whether a scope list contains a scope
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Error codes from RFC 6749 and OpenID Connect Core
+const (
+	ErrInvalidRequest          = "invalid_request"
+	ErrInvalidClient           = "invalid_client"
+	ErrInvalidGrant            = "invalid_grant"
+	ErrInvalidScope            = "invalid_scope"
+	ErrUnauthorizedClient      = "unauthorized_client"
+	ErrUnsupportedGrantType    = "unsupported_grant_type"
+	ErrUnsupportedResponseType = "unsupported_response_type"
+	ErrAccessDenied            = "access_denied"
+	ErrServerError             = "server_error"
+	ErrLoginRequired           = "login_required"
+	ErrInvalidToken            = "invalid_token"
+)
+
+// Error is a protocol error returned to the client as {"error", "error_description"}
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// NewError creates a protocol error
+func NewError(code, description string) *Error {
+	return &Error{Code: code, Description: description}
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// RedirectError appends an error to a client redirect URI, keeping the state (RFC 6749 4.1.2.1)
+func RedirectError(redirectURI, state string, err *Error) string {
+	params := url.Values{"error": {err.Code}}
+	if err.Description != "" {
+		params.Set("error_description", err.Description)
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return appendQuery(redirectURI, params)
+}
+
+// RedirectCode appends an authorization code and state to a client redirect URI
+func RedirectCode(redirectURI, code, state string) string {
+	params := url.Values{"code": {code}}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return appendQuery(redirectURI, params)
+}
+
+func appendQuery(rawURL string, params url.Values) string {
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+	return rawURL + separator + params.Encode()
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKeyRepository handles personal API keys
+type APIKeyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *mongo.Database) *APIKeyRepository {
+	collection := db.Collection("api_keys")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "keyHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "tenantId", Value: 1}},
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &APIKeyRepository{collection: collection}
+}
+
+// Create stores an API key
+func (r *APIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	key.CreatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	key.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByKeyHash finds an API key by the hash of the key, revoked or not
+func (r *APIKeyRepository) FindByKeyHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := r.collection.FindOne(ctx, bson.M{"keyHash": keyHash}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find api key: %w", err)
+	}
+	return &key, nil
+}
+
+// FindByUser lists the keys of a user in a tenant that are not revoked
+func (r *APIKeyRepository) FindByUser(ctx context.Context, userID, tenantID string) ([]*domain.APIKey, error) {
+	filter := bson.M{"userId": userID, "tenantId": tenantID, "revokedAt": nil}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find api keys: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var keys []*domain.APIKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// UpdateLastUsed records that a key was used
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lastUsedAt": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("failed to update api key: %w", err)
+	}
+	return nil
+}
+
+// Revoke revokes a key of a user. It returns false when the user has no such active key.
+func (r *APIKeyRepository) Revoke(ctx context.Context, userID, id string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "userId": userID, "revokedAt": nil},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IdentifierVerificationRepository handles email and phone verification data access
+type IdentifierVerificationRepository struct {
+	collection *mongo.Collection
+}
+
+// NewIdentifierVerificationRepository creates a new identifier verification repository
+func NewIdentifierVerificationRepository(db *mongo.Database) *IdentifierVerificationRepository {
+	collection := db.Collection("identifier_verifications")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "identifier", Value: 1},
+				{Key: "createdAt", Value: -1},
+			},
+		},
+		{
+			Keys:    bson.D{{Key: "linkTokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &IdentifierVerificationRepository{collection: collection}
+}
+
+// Create creates a new verification
+func (r *IdentifierVerificationRepository) Create(ctx context.Context, verification *domain.IdentifierVerification) error {
+	verification.CreatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, verification)
+	if err != nil {
+		return fmt.Errorf("failed to create identifier verification: %w", err)
+	}
+
+	verification.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindPending finds the newest unused, unexpired verification for an identifier
+func (r *IdentifierVerificationRepository) FindPending(ctx context.Context, identifier string) (*domain.IdentifierVerification, error) {
+	filter := bson.M{
+		"identifier": identifier,
+		"usedAt":     nil,
+		"expiresAt":  bson.M{"$gt": time.Now()},
+	}
+
+	var verification domain.IdentifierVerification
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&verification)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find identifier verification: %w", err)
+	}
+	return &verification, nil
+}
+
+// FindPendingByLinkToken finds an unused, unexpired verification by its link token hash
+func (r *IdentifierVerificationRepository) FindPendingByLinkToken(ctx context.Context, linkTokenHash string) (*domain.IdentifierVerification, error) {
+	filter := bson.M{
+		"linkTokenHash": linkTokenHash,
+		"usedAt":        nil,
+		"expiresAt":     bson.M{"$gt": time.Now()},
+	}
+
+	var verification domain.IdentifierVerification
+	err := r.collection.FindOne(ctx, filter).Decode(&verification)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find identifier verification: %w", err)
+	}
+	return &verification, nil
+}
+
+// ClaimAttempt counts an attempt at the code of a pending verification. It returns false when the
+// verification is used, expired or out of attempts, so concurrent guesses cannot exceed the limit.
+func (r *IdentifierVerificationRepository) ClaimAttempt(ctx context.Context, id primitive.ObjectID, maxAttempts int) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":       id,
+			"usedAt":    nil,
+			"expiresAt": bson.M{"$gt": time.Now()},
+			"attempts":  bson.M{"$lt": maxAttempts},
+		},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update identifier verification: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// MarkUsed marks a verification as used. It returns false when it was already used.
+func (r *IdentifierVerificationRepository) MarkUsed(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "usedAt": nil},
+		bson.M{"$set": bson.M{"usedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark identifier verification used: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// InvalidateForIdentifier marks all outstanding verifications of an identifier as used
+func (r *IdentifierVerificationRepository) InvalidateForIdentifier(ctx context.Context, identifier string) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"identifier": identifier, "usedAt": nil},
+		bson.M{"$set": bson.M{"usedAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate identifier verifications: %w", err)
+	}
+	return nil
+}
+
+// CountSince counts verifications sent to an identifier since a point in time
+func (r *IdentifierVerificationRepository) CountSince(ctx context.Context, identifier string, since time.Time) (int64, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{
+		"identifier": identifier,
+		"createdAt":  bson.M{"$gte": since},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count identifier verifications: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginAttemptRepository handles login attempt data access
+type LoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+// NewLoginAttemptRepository creates a new login attempt repository
+func NewLoginAttemptRepository(db *mongo.Database) *LoginAttemptRepository {
+	collection := db.Collection("login_attempts")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "identifier", Value: 1},
+				{Key: "tenantId", Value: 1},
+				{Key: "attemptAt", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "ipAddress", Value: 1},
+				{Key: "tenantId", Value: 1},
+				{Key: "attemptAt", Value: -1},
+			},
+		},
+		{
+			Keys:    bson.D{{Key: "attemptAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(86400), // Keep attempts for 24 hours
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &LoginAttemptRepository{collection: collection}
+}
+
+// Create records a login attempt
+func (r *LoginAttemptRepository) Create(ctx context.Context, attempt *domain.LoginAttempt) error {
+	if attempt.AttemptAt.IsZero() {
+		attempt.AttemptAt = time.Now()
+	}
+
+	result, err := r.collection.InsertOne(ctx, attempt)
+	if err != nil {
+		return fmt.Errorf("failed to create login attempt: %w", err)
+	}
+
+	attempt.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// CountFailuresByIdentifier counts failed attempts for an identifier since the given time,
+// ignoring failures that happened before the most recent successful login
+func (r *LoginAttemptRepository) CountFailuresByIdentifier(ctx context.Context, tenantID, identifier string, since time.Time) (int64, error) {
+	filter := bson.M{"tenantId": tenantID, "identifier": identifier}
+
+	// A successful login resets the failure counter of the identifier
+	var lastSuccess domain.LoginAttempt
+	successFilter := bson.M{"success": true, "attemptAt": bson.M{"$gt": since}}
+	for k, v := range filter {
+		successFilter[k] = v
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "attemptAt", Value: -1}})
+	err := r.collection.FindOne(ctx, successFilter, opts).Decode(&lastSuccess)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, fmt.Errorf("failed to find last successful login: %w", err)
+	}
+	if err == nil {
+		since = lastSuccess.AttemptAt
+	}
+	return r.countFailures(ctx, filter, since)
+}
+
+// CountFailuresByIP counts failed attempts from an IP address since the given time. Successful
+// logins do not reset it, or an attacker could keep guessing by signing in to their own account.
+func (r *LoginAttemptRepository) CountFailuresByIP(ctx context.Context, tenantID, ipAddress string, since time.Time) (int64, error) {
+	return r.countFailures(ctx, bson.M{"tenantId": tenantID, "ipAddress": ipAddress}, since)
+}
+
+func (r *LoginAttemptRepository) countFailures(ctx context.Context, filter bson.M, since time.Time) (int64, error) {
+	failureFilter := bson.M{"success": false, "attemptAt": bson.M{"$gt": since}}
+	for k, v := range filter {
+		failureFilter[k] = v
+	}
+	count, err := r.collection.CountDocuments(ctx, failureFilter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count failed login attempts: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OAuthAccountRepository handles linked social account data access
+type OAuthAccountRepository struct {
+	collection *mongo.Collection
+}
+
+// NewOAuthAccountRepository creates a new OAuth account repository
+func NewOAuthAccountRepository(db *mongo.Database) *OAuthAccountRepository {
+	collection := db.Collection("oauth_accounts")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "provider", Value: 1},
+				{Key: "providerId", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "provider", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &OAuthAccountRepository{collection: collection}
+}
+
+// Create links a provider account to a user
+func (r *OAuthAccountRepository) Create(ctx context.Context, account *domain.OAuthAccount) error {
+	account.CreatedAt = time.Now()
+	account.UpdatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, account)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("oauth account already linked: %w", err)
+		}
+		return fmt.Errorf("failed to create oauth account: %w", err)
+	}
+
+	account.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByProviderID finds the linked account of a provider user
+func (r *OAuthAccountRepository) FindByProviderID(ctx context.Context, provider domain.OAuthProvider, providerID string) (*domain.OAuthAccount, error) {
+	var account domain.OAuthAccount
+	err := r.collection.FindOne(ctx, bson.M{"provider": provider, "providerId": providerID}).Decode(&account)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find oauth account: %w", err)
+	}
+	return &account, nil
+}
+
+// FindByUser finds all accounts linked to a user
+func (r *OAuthAccountRepository) FindByUser(ctx context.Context, userID string) ([]*domain.OAuthAccount, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find oauth accounts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var accounts []*domain.OAuthAccount
+	if err := cursor.All(ctx, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to decode oauth accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// Delete unlinks a provider from a user. It returns false when nothing was linked.
+func (r *OAuthAccountRepository) Delete(ctx context.Context, userID string, provider domain.OAuthProvider) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"userId": userID, "provider": provider})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete oauth account: %w", err)
+	}
+	return result.DeletedCount == 1, nil
+}
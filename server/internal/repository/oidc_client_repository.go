@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OIDCClientRepository handles OpenID Connect client registrations
+type OIDCClientRepository struct {
+	collection *mongo.Collection
+}
+
+// NewOIDCClientRepository creates a new OIDC client repository
+func NewOIDCClientRepository(db *mongo.Database) *OIDCClientRepository {
+	collection := db.Collection("oidc_clients")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "clientId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "tenantId", Value: 1}},
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &OIDCClientRepository{collection: collection}
+}
+
+// Create registers a client
+func (r *OIDCClientRepository) Create(ctx context.Context, client *domain.OIDCClient) error {
+	client.CreatedAt = time.Now()
+	client.UpdatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, client)
+	if err != nil {
+		return fmt.Errorf("failed to create oidc client: %w", err)
+	}
+
+	client.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByClientID finds an active client by its client ID
+func (r *OIDCClientRepository) FindByClientID(ctx context.Context, clientID string) (*domain.OIDCClient, error) {
+	var client domain.OIDCClient
+	err := r.collection.FindOne(ctx, bson.M{"clientId": clientID, "isActive": true}).Decode(&client)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find oidc client: %w", err)
+	}
+	return &client, nil
+}
+
+// FindByTenant lists the clients of a tenant
+func (r *OIDCClientRepository) FindByTenant(ctx context.Context, tenantID string) ([]*domain.OIDCClient, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"tenantId": tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find oidc clients: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var clients []*domain.OIDCClient
+	if err := cursor.All(ctx, &clients); err != nil {
+		return nil, fmt.Errorf("failed to decode oidc clients: %w", err)
+	}
+	return clients, nil
+}
+
+// Delete removes a client of a tenant. It returns false when the tenant has no such client.
+func (r *OIDCClientRepository) Delete(ctx context.Context, tenantID, clientID string) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"tenantId": tenantID, "clientId": clientID})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete oidc client: %w", err)
+	}
+	return result.DeletedCount == 1, nil
+}
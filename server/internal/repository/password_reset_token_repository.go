@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PasswordResetTokenRepository handles password reset token data access
+type PasswordResetTokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewPasswordResetTokenRepository creates a new password reset token repository
+func NewPasswordResetTokenRepository(db *mongo.Database) *PasswordResetTokenRepository {
+	collection := db.Collection("password_reset_tokens")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &PasswordResetTokenRepository{collection: collection}
+}
+
+// Create creates a new password reset token
+func (r *PasswordResetTokenRepository) Create(ctx context.Context, token *domain.PasswordResetToken) error {
+	token.CreatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, token)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+
+	token.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindValid finds an unused, unexpired token by its hash
+func (r *PasswordResetTokenRepository) FindValid(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error) {
+	var token domain.PasswordResetToken
+	err := r.collection.FindOne(ctx, bson.M{
+		"tokenHash": tokenHash,
+		"usedAt":    nil,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find password reset token: %w", err)
+	}
+	return &token, nil
+}
+
+// Consume marks an unused, unexpired token as used and returns it.
+// It returns nil when the token is unknown, expired or already used, so a token works only once.
+func (r *PasswordResetTokenRepository) Consume(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error) {
+	now := time.Now()
+	filter := bson.M{
+		"tokenHash": tokenHash,
+		"usedAt":    nil,
+		"expiresAt": bson.M{"$gt": now},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var token domain.PasswordResetToken
+	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"usedAt": now}}, opts).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to consume password reset token: %w", err)
+	}
+	return &token, nil
+}
+
+// InvalidateForUser marks all outstanding tokens of a user as used
+func (r *PasswordResetTokenRepository) InvalidateForUser(ctx context.Context, userID string) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"userId": userID, "usedAt": nil},
+		bson.M{"$set": bson.M{"usedAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate password reset tokens: %w", err)
+	}
+	return nil
+}
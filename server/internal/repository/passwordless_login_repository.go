@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PasswordlessLoginRepository handles pending passwordless logins
+type PasswordlessLoginRepository struct {
+	collection *mongo.Collection
+}
+
+// NewPasswordlessLoginRepository creates a new passwordless login repository
+func NewPasswordlessLoginRepository(db *mongo.Database) *PasswordlessLoginRepository {
+	collection := db.Collection("passwordless_logins")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "identifier", Value: 1},
+				{Key: "tenantId", Value: 1},
+				{Key: "createdAt", Value: -1},
+			},
+		},
+		{
+			Keys:    bson.D{{Key: "linkTokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &PasswordlessLoginRepository{collection: collection}
+}
+
+// Create creates a new passwordless login
+func (r *PasswordlessLoginRepository) Create(ctx context.Context, login *domain.PasswordlessLogin) error {
+	login.CreatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, login)
+	if err != nil {
+		return fmt.Errorf("failed to create passwordless login: %w", err)
+	}
+
+	login.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindPending finds the newest unused, unexpired passwordless login for an identifier in a tenant
+func (r *PasswordlessLoginRepository) FindPending(ctx context.Context, identifier, tenantID string) (*domain.PasswordlessLogin, error) {
+	filter := bson.M{
+		"identifier": identifier,
+		"tenantId":   tenantID,
+		"usedAt":     nil,
+		"expiresAt":  bson.M{"$gt": time.Now()},
+	}
+
+	var login domain.PasswordlessLogin
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&login)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find passwordless login: %w", err)
+	}
+	return &login, nil
+}
+
+// FindPendingByLinkToken finds an unused, unexpired passwordless login by its link token hash
+func (r *PasswordlessLoginRepository) FindPendingByLinkToken(ctx context.Context, linkTokenHash string) (*domain.PasswordlessLogin, error) {
+	filter := bson.M{
+		"linkTokenHash": linkTokenHash,
+		"usedAt":        nil,
+		"expiresAt":     bson.M{"$gt": time.Now()},
+	}
+
+	var login domain.PasswordlessLogin
+	err := r.collection.FindOne(ctx, filter).Decode(&login)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find passwordless login: %w", err)
+	}
+	return &login, nil
+}
+
+// ClaimAttempt counts an attempt at the code of a pending passwordless login. It returns false when
+// the login is used, expired or out of attempts, so concurrent guesses cannot exceed the limit.
+func (r *PasswordlessLoginRepository) ClaimAttempt(ctx context.Context, id primitive.ObjectID, maxAttempts int) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":       id,
+			"usedAt":    nil,
+			"expiresAt": bson.M{"$gt": time.Now()},
+			"attempts":  bson.M{"$lt": maxAttempts},
+		},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update passwordless login: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// MarkUsed marks a passwordless login as used. It returns false when it was already used.
+func (r *PasswordlessLoginRepository) MarkUsed(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "usedAt": nil},
+		bson.M{"$set": bson.M{"usedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark passwordless login used: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// InvalidateForIdentifier marks all outstanding passwordless logins of an identifier in a tenant as used
+func (r *PasswordlessLoginRepository) InvalidateForIdentifier(ctx context.Context, identifier, tenantID string) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"identifier": identifier, "tenantId": tenantID, "usedAt": nil},
+		bson.M{"$set": bson.M{"usedAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate passwordless logins: %w", err)
+	}
+	return nil
+}
+
+// CountSince counts passwordless logins sent to an identifier, in any tenant, since a point in time
+func (r *PasswordlessLoginRepository) CountSince(ctx context.Context, identifier string, since time.Time) (int64, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{
+		"identifier": identifier,
+		"createdAt":  bson.M{"$gte": since},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count passwordless logins: %w", err)
+	}
+	return count, nil
+}
@@ -30,9 +30,12 @@ func NewRefreshTokenRepository(db *mongo.Database) *RefreshTokenRepository {
 			Keys: bson.D{{Key: "userId", Value: 1}},
 		},
 		{
-			Keys:    bson.D{{Key: "token", Value: 1}},
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
 			Options: options.Index().SetUnique(true),
 		},
+		{
+			Keys: bson.D{{Key: "familyId", Value: 1}},
+		},
 		{
 			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
 			Options: options.Index().SetExpireAfterSeconds(0),
@@ -44,51 +47,59 @@ func NewRefreshTokenRepository(db *mongo.Database) *RefreshTokenRepository {
 	return &RefreshTokenRepository{collection: collection}
 }
 
-// Create creates a new refresh token
+// Create creates a new refresh token. A token without a family starts a new one named after its own ID.
 func (r *RefreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
 	token.CreatedAt = time.Now()
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	if token.FamilyID == "" {
+		token.FamilyID = token.ID.Hex()
+	}
 
-	result, err := r.collection.InsertOne(ctx, token)
-	if err != nil {
+	if _, err := r.collection.InsertOne(ctx, token); err != nil {
 		return fmt.Errorf("failed to create refresh token: %w", err)
 	}
-
-	token.ID = result.InsertedID.(primitive.ObjectID)
 	return nil
 }
 
-// FindByToken finds a refresh token by token string
-func (r *RefreshTokenRepository) FindByToken(ctx context.Context, token string) (*domain.RefreshToken, error) {
+// FindByTokenHash finds a refresh token by the hash of its token string, including revoked
+// and expired tokens so that callers can detect reuse
+func (r *RefreshTokenRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
 	var refreshToken domain.RefreshToken
 	// Optimize query with projection
 	opts := options.FindOne().SetProjection(bson.M{
 		"_id":       1,
 		"userId":    1,
-		"token":     1,
+		"tokenHash": 1,
+		"tenantId":  1,
+		"familyId":  1,
+		"parentId":  1,
 		"expiresAt": 1,
 		"createdAt": 1,
+		"rotatedAt": 1,
 		"revokedAt": 1,
 	})
-	err := r.collection.FindOne(ctx, bson.M{
-		"token":     token,
-		"revokedAt": nil,
-		"expiresAt": bson.M{"$gt": time.Now()},
-	}, opts).Decode(&refreshToken)
+	err := r.collection.FindOne(ctx, bson.M{"tokenHash": tokenHash}, opts).Decode(&refreshToken)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to find refresh token: %w", err)
 	}
+	// Tokens issued before families existed form their own family
+	if refreshToken.FamilyID == "" {
+		refreshToken.FamilyID = refreshToken.ID.Hex()
+	}
 	return &refreshToken, nil
 }
 
-// Revoke revokes a refresh token
-func (r *RefreshTokenRepository) Revoke(ctx context.Context, token string) error {
+// Revoke revokes a refresh token by the hash of its token string
+func (r *RefreshTokenRepository) Revoke(ctx context.Context, tokenHash string) error {
 	now := time.Now()
 	_, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"token": token},
+		bson.M{"tokenHash": tokenHash},
 		bson.M{"$set": bson.M{"revokedAt": now}},
 	)
 	if err != nil {
@@ -97,6 +108,39 @@ func (r *RefreshTokenRepository) Revoke(ctx context.Context, token string) error
 	return nil
 }
 
+// MarkRotated revokes a token that is being exchanged for a child. It returns false when the
+// token was already rotated or revoked, so only one of two concurrent refreshes succeeds.
+func (r *RefreshTokenRepository) MarkRotated(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	now := time.Now()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "revokedAt": nil},
+		bson.M{"$set": bson.M{"rotatedAt": now, "revokedAt": now}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// RevokeFamily revokes every token descended from the same login
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	if familyID == "" {
+		return nil
+	}
+
+	now := time.Now()
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"familyId": familyID, "revokedAt": nil},
+		bson.M{"$set": bson.M{"revokedAt": now}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
 // RevokeAllForUser revokes all refresh tokens for a user
 func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID string) error {
 	now := time.Now()
@@ -111,6 +155,21 @@ func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID st
 	return nil
 }
 
+// RevokeAllForUserExceptFamily revokes all refresh tokens for a user except those of one token family
+func (r *RefreshTokenRepository) RevokeAllForUserExceptFamily(ctx context.Context, userID, keepFamilyID string) error {
+	filter := bson.M{"userId": userID, "revokedAt": nil}
+	if keepFamilyID != "" {
+		filter["familyId"] = bson.M{"$ne": keepFamilyID}
+	}
+
+	now := time.Now()
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": now}})
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+	return nil
+}
+
 // DeleteExpiredTokens removes all expired and revoked tokens (for manual cleanup)
 func (r *RefreshTokenRepository) DeleteExpiredTokens(ctx context.Context) (int64, error) {
 	result, err := r.collection.DeleteMany(
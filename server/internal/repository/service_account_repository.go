@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ServiceAccountRepository handles tenant service accounts
+type ServiceAccountRepository struct {
+	collection *mongo.Collection
+}
+
+// NewServiceAccountRepository creates a new service account repository
+func NewServiceAccountRepository(db *mongo.Database) *ServiceAccountRepository {
+	collection := db.Collection("service_accounts")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "clientId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "tenantId", Value: 1}},
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &ServiceAccountRepository{collection: collection}
+}
+
+// Create creates a service account
+func (r *ServiceAccountRepository) Create(ctx context.Context, account *domain.ServiceAccount) error {
+	account.CreatedAt = time.Now()
+	account.UpdatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, account)
+	if err != nil {
+		return fmt.Errorf("failed to create service account: %w", err)
+	}
+
+	account.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByID finds a service account by its ID, active or not
+func (r *ServiceAccountRepository) FindByID(ctx context.Context, id string) (*domain.ServiceAccount, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var account domain.ServiceAccount
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&account)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find service account: %w", err)
+	}
+	return &account, nil
+}
+
+// FindByClientID finds an active service account by its client ID
+func (r *ServiceAccountRepository) FindByClientID(ctx context.Context, clientID string) (*domain.ServiceAccount, error) {
+	var account domain.ServiceAccount
+	err := r.collection.FindOne(ctx, bson.M{"clientId": clientID, "isActive": true}).Decode(&account)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find service account: %w", err)
+	}
+	return &account, nil
+}
+
+// FindByTenant lists the service accounts of a tenant
+func (r *ServiceAccountRepository) FindByTenant(ctx context.Context, tenantID string) ([]*domain.ServiceAccount, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"tenantId": tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find service accounts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var accounts []*domain.ServiceAccount
+	if err := cursor.All(ctx, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to decode service accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// UpdateLastUsed records that a token was issued to a service account
+func (r *ServiceAccountRepository) UpdateLastUsed(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lastUsedAt": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("failed to update service account: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a service account of a tenant. It returns false when the tenant has no such account.
+func (r *ServiceAccountRepository) Delete(ctx context.Context, tenantID, id string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID, "tenantId": tenantID})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete service account: %w", err)
+	}
+	return result.DeletedCount == 1, nil
+}
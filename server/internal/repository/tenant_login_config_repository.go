@@ -52,12 +52,18 @@ func (r *TenantLoginConfigRepository) Create(ctx context.Context, config *domain
 	if config.SessionTimeout == 0 {
 		config.SessionTimeout = 1440 // 24 hours
 	}
+	if config.RefreshTokenLifetime == 0 {
+		config.RefreshTokenLifetime = 10080 // 7 days
+	}
 	if config.MaxLoginAttempts == 0 {
 		config.MaxLoginAttempts = 5
 	}
 	if config.LockoutDuration == 0 {
 		config.LockoutDuration = 30 // 30 minutes
 	}
+	if config.SessionLimitPolicy == "" {
+		config.SessionLimitPolicy = domain.SessionLimitReject
+	}
 
 	result, err := r.collection.InsertOne(ctx, config)
 	if err != nil {
@@ -159,9 +165,11 @@ func (r *TenantLoginConfigRepository) GetDefaultConfig(tenantID string) *domain.
 		PasswordRequireLower: true,
 		PasswordRequireDigit: true,
 		PasswordRequireSpec:  false,
-		SessionTimeout:       1440, // 24 hours
+		SessionTimeout:       1440,  // 24 hours
+		RefreshTokenLifetime: 10080, // 7 days
 		MaxLoginAttempts:     5,
 		LockoutDuration:      30, // 30 minutes
+		SessionLimitPolicy:   domain.SessionLimitReject,
 		CreatedAt:            time.Now(),
 		UpdatedAt:            time.Now(),
 	}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserLockoutRepository handles user lockout data access
+type UserLockoutRepository struct {
+	collection *mongo.Collection
+}
+
+// NewUserLockoutRepository creates a new user lockout repository
+func NewUserLockoutRepository(db *mongo.Database) *UserLockoutRepository {
+	collection := db.Collection("user_lockouts")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "tenantId", Value: 1},
+				{Key: "isActive", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "identifier", Value: 1},
+				{Key: "tenantId", Value: 1},
+				{Key: "unlockAt", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "ipAddress", Value: 1},
+				{Key: "tenantId", Value: 1},
+				{Key: "unlockAt", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{{Key: "unlockAt", Value: 1}},
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &UserLockoutRepository{collection: collection}
+}
+
+// Create creates a new lockout
+func (r *UserLockoutRepository) Create(ctx context.Context, lockout *domain.UserLockout) error {
+	lockout.CreatedAt = time.Now()
+	lockout.IsActive = true
+
+	result, err := r.collection.InsertOne(ctx, lockout)
+	if err != nil {
+		return fmt.Errorf("failed to create user lockout: %w", err)
+	}
+
+	lockout.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindActive finds an unexpired lockout for the identifier or the IP address.
+// Lockouts whose UnlockAt has passed are ignored, so they expire automatically.
+func (r *UserLockoutRepository) FindActive(ctx context.Context, tenantID, identifier, ipAddress string) (*domain.UserLockout, error) {
+	targets := lockoutTargets(identifier, ipAddress)
+	if len(targets) == 0 {
+		return nil, nil
+	}
+
+	filter := bson.M{
+		"tenantId": tenantID,
+		"isActive": true,
+		"unlockAt": bson.M{"$gt": time.Now()},
+		"$or":      targets,
+	}
+
+	var lockout domain.UserLockout
+	opts := options.FindOne().SetSort(bson.D{{Key: "unlockAt", Value: -1}})
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&lockout)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find user lockout: %w", err)
+	}
+	return &lockout, nil
+}
+
+// FindLatest finds the most recent lockout (active or not) for the identifier or the IP address
+func (r *UserLockoutRepository) FindLatest(ctx context.Context, tenantID, identifier, ipAddress string) (*domain.UserLockout, error) {
+	targets := lockoutTargets(identifier, ipAddress)
+	if len(targets) == 0 {
+		return nil, nil
+	}
+
+	filter := bson.M{
+		"tenantId": tenantID,
+		"$or":      targets,
+	}
+
+	var lockout domain.UserLockout
+	opts := options.FindOne().SetSort(bson.D{{Key: "lockedAt", Value: -1}})
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&lockout)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find user lockout: %w", err)
+	}
+	return &lockout, nil
+}
+
+// Release releases all active lockouts for the identifier or the IP address before they expire
+func (r *UserLockoutRepository) Release(ctx context.Context, tenantID, identifier, ipAddress string) (int64, error) {
+	targets := lockoutTargets(identifier, ipAddress)
+	if len(targets) == 0 {
+		return 0, nil
+	}
+
+	filter := bson.M{
+		"tenantId": tenantID,
+		"isActive": true,
+		"$or":      targets,
+	}
+
+	now := time.Now()
+	result, err := r.collection.UpdateMany(
+		ctx,
+		filter,
+		bson.M{"$set": bson.M{"isActive": false, "releasedAt": now}},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release user lockout: %w", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// ReleaseExpired marks lockouts whose UnlockAt has passed as inactive (for manual cleanup)
+func (r *UserLockoutRepository) ReleaseExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"isActive": true, "unlockAt": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"isActive": false, "releasedAt": now}},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release expired lockouts: %w", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+func lockoutTargets(identifier, ipAddress string) []bson.M {
+	targets := []bson.M{}
+	if identifier != "" {
+		targets = append(targets, bson.M{"identifier": identifier})
+	}
+	if ipAddress != "" {
+		targets = append(targets, bson.M{"ipAddress": ipAddress})
+	}
+	return targets
+}
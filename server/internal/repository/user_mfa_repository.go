@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserMFARepository handles second factor data access
+type UserMFARepository struct {
+	collection *mongo.Collection
+}
+
+// NewUserMFARepository creates a new user MFA repository
+func NewUserMFARepository(db *mongo.Database) *UserMFARepository {
+	collection := db.Collection("user_mfa")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &UserMFARepository{collection: collection}
+}
+
+// FindByUser finds the second factor of a user
+func (r *UserMFARepository) FindByUser(ctx context.Context, userID string) (*domain.UserMFA, error) {
+	var mfa domain.UserMFA
+	err := r.collection.FindOne(ctx, bson.M{"userId": userID}).Decode(&mfa)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find user mfa: %w", err)
+	}
+	return &mfa, nil
+}
+
+// SetPendingSecret stores a new, not yet confirmed TOTP secret for a user.
+// An already enabled factor is never overwritten.
+func (r *UserMFARepository) SetPendingSecret(ctx context.Context, userID, secret string) error {
+	now := time.Now()
+	filter := bson.M{"userId": userID, "enabled": bson.M{"$ne": true}}
+	update := bson.M{
+		"$set": bson.M{
+			"totpSecret":         secret,
+			"enabled":            false,
+			"recoveryCodeHashes": []string{},
+			"lastUsedStep":       0,
+			"updatedAt":          now,
+		},
+		"$setOnInsert": bson.M{
+			"userId":    userID,
+			"createdAt": now,
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("two-factor authentication is already enabled")
+		}
+		return fmt.Errorf("failed to store totp secret: %w", err)
+	}
+	return nil
+}
+
+// Enable confirms a pending TOTP secret and stores the hashed recovery codes
+func (r *UserMFARepository) Enable(ctx context.Context, userID string, recoveryCodeHashes []string, step int64) error {
+	now := time.Now()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"userId": userID, "enabled": false},
+		bson.M{"$set": bson.M{
+			"enabled":            true,
+			"recoveryCodeHashes": recoveryCodeHashes,
+			"lastUsedStep":       step,
+			"enabledAt":          now,
+			"updatedAt":          now,
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enable user mfa: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("pending totp enrollment not found")
+	}
+	return nil
+}
+
+// UseStep records an accepted TOTP time step. It returns false when the step
+// (or a later one) was already used, which rejects replayed codes.
+func (r *UserMFARepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"userId": userID, "lastUsedStep": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"lastUsedStep": step, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp step: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// ConsumeRecoveryCode removes a recovery code hash. It returns false when the code is unknown or already used.
+func (r *UserMFARepository) ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"userId": userID, "enabled": true, "recoveryCodeHashes": codeHash},
+		bson.M{
+			"$pull": bson.M{"recoveryCodeHashes": codeHash},
+			"$set":  bson.M{"updatedAt": time.Now()},
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
@@ -140,6 +140,58 @@ func (r *UserRepository) UpdateLastLogin(ctx context.Context, userID string) err
 	return nil
 }
 
+// UpdatePassword replaces the password hash and records when it was changed
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	now := time.Now()
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{
+			"passwordHash":      passwordHash,
+			"passwordChangedAt": now,
+			"updatedAt":         now,
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return nil
+}
+
+// MarkIdentifierVerified marks the user's email or phone as verified, as long as it
+// still matches the given value, and sets isVerified
+func (r *UserRepository) MarkIdentifierVerified(ctx context.Context, userID string, identifierType domain.IdentifierType, identifier string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	var field, flag string
+	switch identifierType {
+	case domain.IdentifierTypeEmail:
+		field, flag = "email", "emailVerified"
+	case domain.IdentifierTypePhone:
+		field, flag = "phone", "phoneVerified"
+	default:
+		return false, fmt.Errorf("identifier type %s cannot be verified", identifierType)
+	}
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID, field: identifier},
+		bson.M{"$set": bson.M{flag: true, "isVerified": true, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark identifier verified: %w", err)
+	}
+	return result.MatchedCount == 1, nil
+}
+
 // AddTenant adds a tenant to a user
 func (r *UserRepository) AddTenant(ctx context.Context, userID, tenantID string) error {
 	objectID, err := primitive.ObjectIDFromHex(userID)
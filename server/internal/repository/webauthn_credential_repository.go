@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WebAuthnCredentialRepository handles users' passkeys
+type WebAuthnCredentialRepository struct {
+	collection *mongo.Collection
+}
+
+// NewWebAuthnCredentialRepository creates a new WebAuthn credential repository
+func NewWebAuthnCredentialRepository(db *mongo.Database) *WebAuthnCredentialRepository {
+	collection := db.Collection("webauthn_credentials")
+
+	// Create indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "credentialId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "rpId", Value: 1}},
+		},
+	}
+
+	_, _ = collection.Indexes().CreateMany(ctx, indexes)
+
+	return &WebAuthnCredentialRepository{collection: collection}
+}
+
+// Create stores a newly registered credential
+func (r *WebAuthnCredentialRepository) Create(ctx context.Context, credential *domain.WebAuthnCredential) error {
+	credential.CreatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, credential)
+	if err != nil {
+		return fmt.Errorf("failed to create webauthn credential: %w", err)
+	}
+
+	credential.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByCredentialID finds a credential by its base64url credential ID
+func (r *WebAuthnCredentialRepository) FindByCredentialID(ctx context.Context, credentialID string) (*domain.WebAuthnCredential, error) {
+	var credential domain.WebAuthnCredential
+	err := r.collection.FindOne(ctx, bson.M{"credentialId": credentialID}).Decode(&credential)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find webauthn credential: %w", err)
+	}
+	return &credential, nil
+}
+
+// FindByUser lists a user's credentials for a relying party
+func (r *WebAuthnCredentialRepository) FindByUser(ctx context.Context, userID, rpID string) ([]*domain.WebAuthnCredential, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": userID, "rpId": rpID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find webauthn credentials: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var credentials []*domain.WebAuthnCredential
+	if err := cursor.All(ctx, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to decode webauthn credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// UpdateSignCount records a login with a credential and its new signature counter
+func (r *WebAuthnCredentialRepository) UpdateSignCount(ctx context.Context, id primitive.ObjectID, signCount uint32) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"signCount":  signCount,
+		"lastUsedAt": time.Now(),
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to update webauthn credential: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a user's credential. It returns false when the user has no such credential.
+func (r *WebAuthnCredentialRepository) Delete(ctx context.Context, userID, id string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID, "userId": userID})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webauthn credential: %w", err)
+	}
+	return result.DeletedCount == 1, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/vhvplatform/go-auth-service/internal/domain"
 	"github.com/vhvplatform/go-auth-service/internal/repository"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
 	"github.com/vhvplatform/go-shared/errors"
 	"github.com/vhvplatform/go-shared/jwt"
 	"github.com/vhvplatform/go-shared/logger"
@@ -22,6 +23,7 @@ type AuthService struct {
 	refreshTokenRepo *repository.RefreshTokenRepository
 	roleRepo         *repository.RoleRepository
 	jwtManager       *jwt.Manager
+	tokenHasher      *authutils.TokenHasher
 	redisCache       *redis.Cache
 	logger           *logger.Logger
 }
@@ -33,6 +35,7 @@ func NewAuthService(
 	refreshTokenRepo *repository.RefreshTokenRepository,
 	roleRepo *repository.RoleRepository,
 	jwtManager *jwt.Manager,
+	tokenHasher *authutils.TokenHasher,
 	redisClient *redis.Client,
 	log *logger.Logger,
 ) *AuthService {
@@ -50,6 +53,7 @@ func NewAuthService(
 		refreshTokenRepo: refreshTokenRepo,
 		roleRepo:         roleRepo,
 		jwtManager:       jwtManager,
+		tokenHasher:      tokenHasher,
 		redisCache:       redisCache,
 		logger:           log,
 	}
@@ -120,7 +124,7 @@ func (s *AuthService) Login(ctx context.Context, identifier, password, tenantID
 	}
 
 	// 6. Generate tokens
-	return s.generateTokens(ctx, user, tenantID)
+	return s.generateTokens(ctx, user, tenantID, nil)
 }
 
 func (s *AuthService) detectLoginMethod(identifier string, user *domain.User) string {
@@ -147,7 +151,7 @@ func (s *AuthService) ValidateToken(ctx context.Context, token string, tenantID
 	// 1. Try to validate as Opaque token from Redis
 	if s.redisCache != nil {
 		var session domain.Session
-		err := s.redisCache.Get(ctx, fmt.Sprintf("session:%s", token), &session)
+		err := s.redisCache.Get(ctx, sessionKey(s.tokenHasher.Hash(token)), &session)
 		if err == nil {
 			userID = session.UserID
 			tenantID = session.TenantID
@@ -252,12 +256,12 @@ func (s *AuthService) CheckPermission(ctx context.Context, userID, tenantID, per
 func (s *AuthService) Logout(ctx context.Context, userID, token string) error {
 	// Revoke refresh token (if it's a refresh token)
 	if token != "" {
-		_ = s.refreshTokenRepo.Revoke(ctx, token)
+		_ = s.refreshTokenRepo.Revoke(ctx, s.tokenHasher.Hash(token))
 	}
 
 	// Remove session from Redis
 	if s.redisCache != nil && token != "" {
-		_ = s.redisCache.Delete(ctx, fmt.Sprintf("session:%s", token))
+		_ = s.redisCache.Delete(ctx, sessionKey(s.tokenHasher.Hash(token)))
 	}
 
 	return nil
@@ -266,7 +270,7 @@ func (s *AuthService) Logout(ctx context.Context, userID, token string) error {
 // RefreshToken refreshes an access token
 func (s *AuthService) RefreshToken(ctx context.Context, refreshTokenStr string) (*domain.LoginResponse, error) {
 	// Validate refresh token exists in DB
-	token, err := s.refreshTokenRepo.FindByToken(ctx, refreshTokenStr)
+	token, err := s.refreshTokenRepo.FindByTokenHash(ctx, s.tokenHasher.Hash(refreshTokenStr))
 	if err != nil {
 		return nil, errors.Internal("Failed to refresh token")
 	}
@@ -274,6 +278,27 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshTokenStr string)
 		return nil, errors.Unauthorized("Invalid refresh token")
 	}
 
+	// Replaying an exchanged token revokes every token issued since the same login
+	if token.RotatedAt != nil {
+		s.revokeTokenFamily(ctx, token)
+		return nil, errors.Unauthorized("Invalid refresh token")
+	}
+	if token.RevokedAt != nil {
+		return nil, errors.Unauthorized("Invalid refresh token")
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, errors.Unauthorized("Refresh token expired")
+	}
+
+	rotated, err := s.refreshTokenRepo.MarkRotated(ctx, token.ID)
+	if err != nil {
+		return nil, errors.Internal("Failed to refresh token")
+	}
+	if !rotated {
+		s.revokeTokenFamily(ctx, token)
+		return nil, errors.Unauthorized("Invalid refresh token")
+	}
+
 	user, err := s.userRepo.FindByID(ctx, token.UserID)
 	if err != nil {
 		return nil, errors.Internal("Failed to refresh token")
@@ -282,12 +307,24 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshTokenStr string)
 		return nil, errors.Unauthorized("User not found")
 	}
 
-	// Generate new tokens
-	return s.generateTokens(ctx, user, token.TenantID)
+	// Generate new tokens in the same family
+	return s.generateTokens(ctx, user, token.TenantID, token)
+}
+
+// revokeTokenFamily revokes every refresh token descended from the same login
+func (s *AuthService) revokeTokenFamily(ctx context.Context, token *domain.RefreshToken) {
+	s.logger.Warn("Refresh token reuse detected, revoking token family",
+		zap.String("user_id", token.UserID),
+		zap.String("family_id", token.FamilyID))
+
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		s.logger.Error("Failed to revoke refresh token family", zap.Error(err))
+	}
 }
 
-// generateTokens generates access and refresh tokens
-func (s *AuthService) generateTokens(ctx context.Context, user *domain.User, tenantID string) (*domain.LoginResponse, error) {
+// generateTokens generates access and refresh tokens. With a parent the refresh token
+// joins the parent's family, otherwise it starts a new one.
+func (s *AuthService) generateTokens(ctx context.Context, user *domain.User, tenantID string, parent *domain.RefreshToken) (*domain.LoginResponse, error) {
 	userID := user.ID.Hex()
 
 	// Generate Opaque Access Token
@@ -308,7 +345,7 @@ func (s *AuthService) generateTokens(ctx context.Context, user *domain.User, ten
 
 	// Store in Redis
 	if s.redisCache != nil {
-		if err := s.redisCache.Set(ctx, fmt.Sprintf("session:%s", accessToken), session, 24*time.Hour); err != nil {
+		if err := s.redisCache.Set(ctx, sessionKey(s.tokenHasher.Hash(accessToken)), session, 24*time.Hour); err != nil {
 			s.logger.Error("Failed to store session in Redis", zap.Error(err))
 			// Fallback to JWT if Redis fails? User requested opaque, but we should handle failure.
 			// For now, return error.
@@ -325,10 +362,14 @@ func (s *AuthService) generateTokens(ctx context.Context, user *domain.User, ten
 	// Store refresh token in DB
 	refreshTokenDoc := &domain.RefreshToken{
 		UserID:    userID,
-		Token:     refreshToken,
+		TokenHash: s.tokenHasher.Hash(refreshToken),
 		TenantID:  tenantID,
 		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
 	}
+	if parent != nil {
+		refreshTokenDoc.FamilyID = parent.FamilyID
+		refreshTokenDoc.ParentID = parent.ID.Hex()
+	}
 	if err := s.refreshTokenRepo.Create(ctx, refreshTokenDoc); err != nil {
 		return nil, err
 	}
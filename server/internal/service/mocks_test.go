@@ -0,0 +1,554 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/notification"
+	"github.com/vhvplatform/go-auth-service/internal/oidc"
+	"github.com/vhvplatform/go-auth-service/internal/service"
+	"github.com/vhvplatform/go-auth-service/internal/store"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+	"github.com/vhvplatform/go-shared/jwt"
+	"github.com/vhvplatform/go-shared/logger"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testHashKey keys the token hashes of the service under test
+const testHashKey = "test-key"
+
+// testRepos are the repositories of a MultiTenantAuthService under test. Repositories left nil
+// are not expected to be used.
+type testRepos struct {
+	users          *MockUserRepository
+	userTenants    *MockUserTenantRepository
+	loginConfigs   *MockTenantLoginConfigRepository
+	refreshTokens  *MockRefreshTokenRepository
+	roles          *MockRoleRepository
+	attempts       *MockLoginAttemptRepository
+	lockouts       *MockUserLockoutRepository
+	mfa            *MockUserMFARepository
+	passwordResets *MockPasswordResetTokenRepository
+	oidcClients    *MockOIDCClientRepository
+	serviceAccts   *MockServiceAccountRepository
+	apiKeys        *MockAPIKeyRepository
+	passkeys       *MockWebAuthnCredentialRepository
+	passwordless   *MockPasswordlessLoginRepository
+	permissions    *service.PermissionService
+	oidcProvider   *oidc.Provider
+	store          store.Store
+}
+
+// newTestAuthService creates a MultiTenantAuthService backed by the given mocks
+func newTestAuthService(repos testRepos) *service.MultiTenantAuthService {
+	log := logger.NewLogger()
+
+	return service.NewMultiTenantAuthService(
+		repos.users, repos.userTenants, repos.loginConfigs, repos.refreshTokens, repos.roles,
+		repos.attempts, repos.lockouts, repos.mfa, repos.passwordResets,
+		nil, nil, repos.oidcClients, repos.serviceAccts, repos.apiKeys, repos.passkeys, repos.passwordless,
+		notification.NewLogNotifier(log), nil, repos.permissions, nil, repos.oidcProvider,
+		jwt.NewManager("test-secret", 3600, 86400),
+		authutils.NewTokenHasher(testHashKey),
+		repos.store,
+		log,
+	)
+}
+
+const (
+	testTenantID = "tenant123"
+	testEmail    = "user@example.com"
+	testPassword = "Correct-Horse-1"
+	testIP       = "203.0.113.7"
+)
+
+func testLoginConfig() *domain.TenantLoginConfig {
+	return &domain.TenantLoginConfig{
+		TenantID:           testTenantID,
+		AllowedIdentifiers: []string{"email"},
+		SessionTimeout:     60,
+		MaxLoginAttempts:   3,
+		LockoutDuration:    15,
+	}
+}
+
+func testUser(t *testing.T) *domain.User {
+	passwordHash, err := utils.HashPassword(testPassword)
+	require.NoError(t, err)
+	return &domain.User{
+		ID:            primitive.NewObjectID(),
+		Email:         testEmail,
+		PasswordHash:  passwordHash,
+		IsActive:      true,
+		EmailVerified: true,
+	}
+}
+
+func testMembership(user *domain.User, roles ...string) *domain.UserTenant {
+	return &domain.UserTenant{
+		ID:       primitive.NewObjectID(),
+		UserID:   user.ID.Hex(),
+		TenantID: testTenantID,
+		Roles:    roles,
+		IsActive: true,
+	}
+}
+
+// sessionRepos returns mocks for password logins of an active user of the tenant, and the refresh
+// tokens they issue in order
+func sessionRepos(t *testing.T, config *domain.TenantLoginConfig) (testRepos, *domain.User, *[]*domain.RefreshToken) {
+	user := testUser(t)
+	repos := testRepos{
+		users:          &MockUserRepository{},
+		userTenants:    &MockUserTenantRepository{},
+		loginConfigs:   &MockTenantLoginConfigRepository{},
+		refreshTokens:  &MockRefreshTokenRepository{},
+		roles:          &MockRoleRepository{},
+		attempts:       &MockLoginAttemptRepository{},
+		lockouts:       &MockUserLockoutRepository{},
+		mfa:            &MockUserMFARepository{},
+		passwordResets: &MockPasswordResetTokenRepository{},
+		store:          store.NewMemoryStore(),
+	}
+	repos.loginConfigs.On("FindByTenant", mock.Anything, testTenantID).Return(config, nil)
+	repos.lockouts.On("FindActive", mock.Anything, testTenantID, testEmail, mock.Anything).Return(nil, nil)
+	repos.users.On("FindByIdentifier", mock.Anything, testEmail).Return(user, nil)
+	repos.users.On("FindByID", mock.Anything, user.ID.Hex()).Return(user, nil)
+	repos.users.On("UpdateLastLogin", mock.Anything, user.ID.Hex()).Return(nil)
+	repos.userTenants.On("FindByUserAndTenant", mock.Anything, user.ID.Hex(), testTenantID).Return(testMembership(user, "member"), nil)
+	repos.mfa.On("FindByUser", mock.Anything, user.ID.Hex()).Return(nil, nil)
+	repos.roles.On("GetPermissionsForRoles", mock.Anything, []string{"member"}, testTenantID).Return([]string{"user.read"}, nil)
+	repos.attempts.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	issued := &[]*domain.RefreshToken{}
+	var mu sync.Mutex
+	repos.refreshTokens.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+		token := args.Get(1).(*domain.RefreshToken)
+		token.ID = primitive.NewObjectID()
+		if token.FamilyID == "" {
+			token.FamilyID = token.ID.Hex()
+		}
+		token.CreatedAt = time.Now()
+		*issued = append(*issued, token)
+	}).Return(nil)
+	return repos, user, issued
+}
+
+// MockUserRepository
+type MockUserRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserRepository) Create(ctx context.Context, user *domain.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) FindByID(ctx context.Context, id string) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserRepository) FindByIdentifier(ctx context.Context, identifier string) (*domain.User, error) {
+	args := m.Called(ctx, identifier)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserRepository) MarkIdentifierVerified(ctx context.Context, userID string, identifierType domain.IdentifierType, identifier string) (bool, error) {
+	args := m.Called(ctx, userID, identifierType, identifier)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) UpdateLastLogin(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	args := m.Called(ctx, userID, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserTenantRepository) Create(ctx context.Context, userTenant *domain.UserTenant) error {
+	args := m.Called(ctx, userTenant)
+	return args.Error(0)
+}
+
+func (m *MockUserTenantRepository) Deactivate(ctx context.Context, userID, tenantID string) error {
+	args := m.Called(ctx, userID, tenantID)
+	return args.Error(0)
+}
+
+func (m *MockUserTenantRepository) FindByUser(ctx context.Context, userID string) ([]*domain.UserTenant, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.UserTenant), args.Error(1)
+}
+
+func (m *MockUserTenantRepository) UpdateRoles(ctx context.Context, userID, tenantID string, roles []string) error {
+	args := m.Called(ctx, userID, tenantID, roles)
+	return args.Error(0)
+}
+
+// MockTenantLoginConfigRepository
+type MockTenantLoginConfigRepository struct {
+	mock.Mock
+}
+
+func (m *MockTenantLoginConfigRepository) FindByTenant(ctx context.Context, tenantID string) (*domain.TenantLoginConfig, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TenantLoginConfig), args.Error(1)
+}
+
+// MockLoginAttemptRepository
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) Create(ctx context.Context, attempt *domain.LoginAttempt) error {
+	args := m.Called(ctx, attempt)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) CountFailuresByIdentifier(ctx context.Context, tenantID, identifier string, since time.Time) (int64, error) {
+	args := m.Called(ctx, tenantID, identifier, since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) CountFailuresByIP(ctx context.Context, tenantID, ipAddress string, since time.Time) (int64, error) {
+	args := m.Called(ctx, tenantID, ipAddress, since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockUserLockoutRepository
+type MockUserLockoutRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserLockoutRepository) Create(ctx context.Context, lockout *domain.UserLockout) error {
+	args := m.Called(ctx, lockout)
+	return args.Error(0)
+}
+
+func (m *MockUserLockoutRepository) FindActive(ctx context.Context, tenantID, identifier, ipAddress string) (*domain.UserLockout, error) {
+	args := m.Called(ctx, tenantID, identifier, ipAddress)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.UserLockout), args.Error(1)
+}
+
+func (m *MockUserLockoutRepository) FindLatest(ctx context.Context, tenantID, identifier, ipAddress string) (*domain.UserLockout, error) {
+	args := m.Called(ctx, tenantID, identifier, ipAddress)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.UserLockout), args.Error(1)
+}
+
+func (m *MockUserLockoutRepository) Release(ctx context.Context, tenantID, identifier, ipAddress string) (int64, error) {
+	args := m.Called(ctx, tenantID, identifier, ipAddress)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockUserMFARepository
+type MockUserMFARepository struct {
+	mock.Mock
+}
+
+func (m *MockUserMFARepository) FindByUser(ctx context.Context, userID string) (*domain.UserMFA, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.UserMFA), args.Error(1)
+}
+
+func (m *MockUserMFARepository) SetPendingSecret(ctx context.Context, userID, secret string) error {
+	args := m.Called(ctx, userID, secret)
+	return args.Error(0)
+}
+
+func (m *MockUserMFARepository) Enable(ctx context.Context, userID string, recoveryCodeHashes []string, step int64) error {
+	args := m.Called(ctx, userID, recoveryCodeHashes, step)
+	return args.Error(0)
+}
+
+func (m *MockUserMFARepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	args := m.Called(ctx, userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserMFARepository) ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockRefreshTokenRepository
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) MarkRotated(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeAllForUserExceptFamily(ctx context.Context, userID, keepFamilyID string) error {
+	args := m.Called(ctx, userID, keepFamilyID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+// MockPasswordResetTokenRepository
+type MockPasswordResetTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockPasswordResetTokenRepository) Create(ctx context.Context, token *domain.PasswordResetToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetTokenRepository) FindValid(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockPasswordResetTokenRepository) Consume(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockPasswordResetTokenRepository) InvalidateForUser(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// MockOIDCClientRepository
+type MockOIDCClientRepository struct {
+	mock.Mock
+}
+
+func (m *MockOIDCClientRepository) Create(ctx context.Context, client *domain.OIDCClient) error {
+	args := m.Called(ctx, client)
+	return args.Error(0)
+}
+
+func (m *MockOIDCClientRepository) Delete(ctx context.Context, tenantID, clientID string) (bool, error) {
+	args := m.Called(ctx, tenantID, clientID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOIDCClientRepository) FindByClientID(ctx context.Context, clientID string) (*domain.OIDCClient, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OIDCClient), args.Error(1)
+}
+
+func (m *MockOIDCClientRepository) FindByTenant(ctx context.Context, tenantID string) ([]*domain.OIDCClient, error) {
+	args := m.Called(ctx, tenantID)
+	return args.Get(0).([]*domain.OIDCClient), args.Error(1)
+}
+
+// MockServiceAccountRepository
+type MockServiceAccountRepository struct {
+	mock.Mock
+}
+
+func (m *MockServiceAccountRepository) Create(ctx context.Context, account *domain.ServiceAccount) error {
+	args := m.Called(ctx, account)
+	return args.Error(0)
+}
+
+func (m *MockServiceAccountRepository) Delete(ctx context.Context, tenantID, id string) (bool, error) {
+	args := m.Called(ctx, tenantID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) FindByClientID(ctx context.Context, clientID string) (*domain.ServiceAccount, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ServiceAccount), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) FindByID(ctx context.Context, id string) (*domain.ServiceAccount, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ServiceAccount), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) FindByTenant(ctx context.Context, tenantID string) ([]*domain.ServiceAccount, error) {
+	args := m.Called(ctx, tenantID)
+	return args.Get(0).([]*domain.ServiceAccount), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) UpdateLastUsed(ctx context.Context, id primitive.ObjectID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockAPIKeyRepository
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) FindByKeyHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) FindByUser(ctx context.Context, userID, tenantID string) ([]*domain.APIKey, error) {
+	args := m.Called(ctx, userID, tenantID)
+	return args.Get(0).([]*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Revoke(ctx context.Context, userID, id string) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) UpdateLastUsed(ctx context.Context, id primitive.ObjectID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockWebAuthnCredentialRepository
+type MockWebAuthnCredentialRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebAuthnCredentialRepository) Create(ctx context.Context, credential *domain.WebAuthnCredential) error {
+	args := m.Called(ctx, credential)
+	return args.Error(0)
+}
+
+func (m *MockWebAuthnCredentialRepository) Delete(ctx context.Context, userID, id string) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWebAuthnCredentialRepository) FindByCredentialID(ctx context.Context, credentialID string) (*domain.WebAuthnCredential, error) {
+	args := m.Called(ctx, credentialID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WebAuthnCredential), args.Error(1)
+}
+
+func (m *MockWebAuthnCredentialRepository) FindByUser(ctx context.Context, userID, rpID string) ([]*domain.WebAuthnCredential, error) {
+	args := m.Called(ctx, userID, rpID)
+	return args.Get(0).([]*domain.WebAuthnCredential), args.Error(1)
+}
+
+func (m *MockWebAuthnCredentialRepository) UpdateSignCount(ctx context.Context, id primitive.ObjectID, signCount uint32) error {
+	args := m.Called(ctx, id, signCount)
+	return args.Error(0)
+}
+
+// MockPasswordlessLoginRepository
+type MockPasswordlessLoginRepository struct {
+	mock.Mock
+}
+
+func (m *MockPasswordlessLoginRepository) ClaimAttempt(ctx context.Context, id primitive.ObjectID, maxAttempts int) (bool, error) {
+	args := m.Called(ctx, id, maxAttempts)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPasswordlessLoginRepository) CountSince(ctx context.Context, identifier string, since time.Time) (int64, error) {
+	args := m.Called(ctx, identifier, since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPasswordlessLoginRepository) Create(ctx context.Context, login *domain.PasswordlessLogin) error {
+	args := m.Called(ctx, login)
+	return args.Error(0)
+}
+
+func (m *MockPasswordlessLoginRepository) FindPending(ctx context.Context, identifier, tenantID string) (*domain.PasswordlessLogin, error) {
+	args := m.Called(ctx, identifier, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PasswordlessLogin), args.Error(1)
+}
+
+func (m *MockPasswordlessLoginRepository) FindPendingByLinkToken(ctx context.Context, linkTokenHash string) (*domain.PasswordlessLogin, error) {
+	args := m.Called(ctx, linkTokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PasswordlessLogin), args.Error(1)
+}
+
+func (m *MockPasswordlessLoginRepository) InvalidateForIdentifier(ctx context.Context, identifier, tenantID string) error {
+	args := m.Called(ctx, identifier, tenantID)
+	return args.Error(0)
+}
+
+func (m *MockPasswordlessLoginRepository) MarkUsed(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
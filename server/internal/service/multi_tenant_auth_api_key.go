@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/events"
+	"github.com/vhvplatform/go-shared/auth"
+	"github.com/vhvplatform/go-shared/errors"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// APIKeyPrefix starts every personal API key, so keys can be told apart from access tokens and found by secret scanners
+	APIKeyPrefix = "vak_"
+	// apiKeyDisplayLength is how much of a key is kept to recognise it in listings
+	apiKeyDisplayLength = len(APIKeyPrefix) + 6
+)
+
+// CreateAPIKey creates a personal API key for the user behind an access token, in the token's tenant.
+// The key is returned once; only its hash is stored. It can only hold permissions the user has.
+func (s *MultiTenantAuthService) CreateAPIKey(ctx context.Context, accessToken string, req *domain.CreateAPIKeyRequest) (*domain.APIKey, string, error) {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(req.Permissions) == 0 {
+		return nil, "", errors.BadRequest("At least one permission is required")
+	}
+	if req.ExpiresInDays < 0 {
+		return nil, "", errors.BadRequest("expires_in_days cannot be negative")
+	}
+
+	granted, err := s.userPermissionSet(ctx, session.UserID, session.TenantID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, permission := range req.Permissions {
+		if !granted.Has(permission) {
+			return nil, "", errors.Forbidden("You do not have the permission " + permission)
+		}
+	}
+
+	secret, err := utils.GenerateRandomString(40)
+	if err != nil {
+		return nil, "", errors.Internal("Failed to generate API key")
+	}
+	rawKey := APIKeyPrefix + secret
+
+	key := &domain.APIKey{
+		UserID:      session.UserID,
+		TenantID:    session.TenantID,
+		Name:        req.Name,
+		Prefix:      rawKey[:apiKeyDisplayLength],
+		KeyHash:     s.tokenHasher.Hash(rawKey),
+		Permissions: req.Permissions,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		s.logger.Error("Failed to create api key", zap.Error(err))
+		return nil, "", errors.Internal("Failed to create API key")
+	}
+
+	s.logger.Info("API key created",
+		zap.String("user_id", session.UserID),
+		zap.String("tenant_id", session.TenantID),
+		zap.String("prefix", key.Prefix))
+
+	return key, rawKey, nil
+}
+
+// ListAPIKeys lists the active API keys of the user behind an access token in the token's tenant
+func (s *MultiTenantAuthService) ListAPIKeys(ctx context.Context, accessToken string) ([]*domain.APIKey, error) {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.apiKeyRepo.FindByUser(ctx, session.UserID, session.TenantID)
+	if err != nil {
+		s.logger.Error("Failed to list api keys", zap.Error(err))
+		return nil, errors.Internal("Failed to list API keys")
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of the API keys of the user behind an access token
+func (s *MultiTenantAuthService) RevokeAPIKey(ctx context.Context, accessToken, keyID string) error {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := s.apiKeyRepo.Revoke(ctx, session.UserID, keyID)
+	if err != nil {
+		s.logger.Error("Failed to revoke api key", zap.Error(err))
+		return errors.Internal("Failed to revoke API key")
+	}
+	if !revoked {
+		return errors.NotFound("API key not found")
+	}
+
+	s.publishEvent(ctx, &events.Event{Type: events.SessionRevoked, UserID: session.UserID, SessionID: keyID})
+
+	s.logger.Info("API key revoked", zap.String("user_id", session.UserID), zap.String("api_key_id", keyID))
+	return nil
+}
+
+// IsAPIKey reports whether a credential is a personal API key rather than an access token
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// verifyAPIKey is VerifyToken for a personal API key. The key grants the permissions it was created
+// with that the user still has, and no roles.
+func (s *MultiTenantAuthService) verifyAPIKey(ctx context.Context, rawKey string) (*domain.ValidateTokenResponse, error) {
+	key, err := s.apiKeyRepo.FindByKeyHash(ctx, s.tokenHasher.Hash(rawKey))
+	if err != nil {
+		s.logger.Error("Failed to get api key", zap.Error(err))
+		return nil, errors.Internal("Failed to verify token")
+	}
+	if key == nil || key.RevokedAt != nil {
+		return nil, errors.Unauthorized("Invalid API key")
+	}
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, errors.Unauthorized("API key expired")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, key.UserID)
+	if err != nil {
+		s.logger.Error("Failed to get user", zap.Error(err))
+		return nil, errors.Internal("Failed to verify token")
+	}
+	if user == nil {
+		return nil, errors.Unauthorized("User not found")
+	}
+	if !user.IsActive {
+		return nil, errors.Forbidden("User account is deactivated")
+	}
+
+	granted, err := s.userPermissionSet(ctx, key.UserID, key.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	permissions := []string{}
+	for _, permission := range key.Permissions {
+		if granted.Has(permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) >= lastSeenInterval {
+		if err := s.apiKeyRepo.UpdateLastUsed(ctx, key.ID); err != nil {
+			s.logger.Warn("Failed to update api key", zap.Error(err))
+		}
+	}
+
+	return &domain.ValidateTokenResponse{
+		Valid:       true,
+		UserID:      key.UserID,
+		TenantID:    key.TenantID,
+		Email:       user.Email,
+		Roles:       []string{},
+		Permissions: permissions,
+		Metadata: map[string]string{
+			"user_id":    key.UserID,
+			"tenant_id":  key.TenantID,
+			"api_key_id": key.ID.Hex(),
+		},
+	}, nil
+}
+
+// userPermissionSet returns the permissions a user currently has in a tenant
+func (s *MultiTenantAuthService) userPermissionSet(ctx context.Context, userID, tenantID string) (*auth.PermissionSet, error) {
+	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, userID, tenantID)
+	if err != nil {
+		s.logger.Error("Failed to get user tenant", zap.Error(err))
+		return nil, errors.Internal("Failed to get permissions")
+	}
+	if userTenant == nil || !userTenant.IsActive {
+		return nil, errors.Forbidden("User does not have access to this tenant")
+	}
+
+	permissions, err := s.roleRepo.GetPermissionsForRoles(ctx, userTenant.Roles, tenantID)
+	if err != nil {
+		return nil, errors.Internal("Failed to get permissions")
+	}
+	set, err := auth.NewPermissionSet(permissions)
+	if err != nil {
+		return nil, errors.Internal("Failed to get permissions")
+	}
+	return set, nil
+}
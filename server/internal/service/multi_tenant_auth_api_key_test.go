@@ -0,0 +1,164 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/service"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// apiKeyRepos returns mocks for a signed-in user who can create API keys, like sessionRepos
+func apiKeyRepos(t *testing.T) (testRepos, *domain.User) {
+	repos, user, _ := sessionRepos(t, testLoginConfig())
+	repos.apiKeys = &MockAPIKeyRepository{}
+	repos.apiKeys.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		key := args.Get(1).(*domain.APIKey)
+		key.ID = primitive.NewObjectID()
+		key.CreatedAt = time.Now()
+	}).Return(nil)
+	repos.apiKeys.On("UpdateLastUsed", mock.Anything, mock.Anything).Return(nil)
+	return repos, user
+}
+
+// createAPIKey signs in and creates an API key, which the repository then finds by its hash
+func createAPIKey(t *testing.T, authService *service.MultiTenantAuthService, repos testRepos, req *domain.CreateAPIKeyRequest) (*domain.LoginResponse, *domain.APIKey, string) {
+	ctx := context.Background()
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+
+	key, rawKey, err := authService.CreateAPIKey(ctx, login.AccessToken, req)
+	require.NoError(t, err)
+	repos.apiKeys.On("FindByKeyHash", mock.Anything, key.KeyHash).Return(key, nil)
+	return login, key, rawKey
+}
+
+func TestMultiTenantAuthService_CreateAPIKey_Permissions(t *testing.T) {
+	ctx := context.Background()
+	repos, user := apiKeyRepos(t)
+	authService := newTestAuthService(repos)
+
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	_, _, err = authService.CreateAPIKey(ctx, login.AccessToken, &domain.CreateAPIKeyRequest{
+		Name:        "ci",
+		Permissions: []string{"user.read", "user.delete"},
+	})
+	require.Error(t, err, "a key cannot hold a permission the user does not have")
+	assert.Contains(t, err.Error(), "user.delete")
+	repos.apiKeys.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+
+	_, key, rawKey := createAPIKey(t, authService, repos, &domain.CreateAPIKeyRequest{
+		Name:        "ci",
+		Permissions: []string{"user.read"},
+	})
+	assert.True(t, strings.HasPrefix(rawKey, service.APIKeyPrefix))
+	assert.Equal(t, rawKey[:len(key.Prefix)], key.Prefix)
+	assert.Equal(t, authutils.NewTokenHasher(testHashKey).Hash(rawKey), key.KeyHash)
+	assert.Nil(t, key.ExpiresAt)
+
+	resp, err := authService.VerifyToken(ctx, rawKey)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.Hex(), resp.UserID)
+	assert.Equal(t, []string{"user.read"}, resp.Permissions)
+	assert.Empty(t, resp.Roles)
+	assert.Equal(t, key.ID.Hex(), resp.Metadata["api_key_id"])
+
+	// A permission the user loses is no longer granted by the key
+	membership, err := repos.userTenants.FindByUserAndTenant(ctx, user.ID.Hex(), testTenantID)
+	require.NoError(t, err)
+	membership.Roles = []string{"guest"}
+	repos.roles.On("GetPermissionsForRoles", mock.Anything, []string{"guest"}, testTenantID).Return([]string{"profile.read"}, nil)
+
+	resp, err = authService.VerifyToken(ctx, rawKey)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Permissions)
+}
+
+func TestMultiTenantAuthService_VerifyToken_APIKeyExpiry(t *testing.T) {
+	ctx := context.Background()
+	repos, _ := apiKeyRepos(t)
+	authService := newTestAuthService(repos)
+
+	_, key, rawKey := createAPIKey(t, authService, repos, &domain.CreateAPIKeyRequest{
+		Name:          "ci",
+		Permissions:   []string{"user.read"},
+		ExpiresInDays: 30,
+	})
+	require.NotNil(t, key.ExpiresAt)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, 30), *key.ExpiresAt, time.Minute)
+
+	_, err := authService.VerifyToken(ctx, rawKey)
+	require.NoError(t, err)
+
+	expired := time.Now().Add(-time.Second)
+	key.ExpiresAt = &expired
+	_, err = authService.VerifyToken(ctx, rawKey)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "API key expired")
+}
+
+func TestMultiTenantAuthService_RevokeAPIKey(t *testing.T) {
+	ctx := context.Background()
+	repos, user := apiKeyRepos(t)
+	authService := newTestAuthService(repos)
+
+	login, key, rawKey := createAPIKey(t, authService, repos, &domain.CreateAPIKeyRequest{
+		Name:        "ci",
+		Permissions: []string{"user.read"},
+	})
+	repos.apiKeys.On("Revoke", mock.Anything, user.ID.Hex(), key.ID.Hex()).Run(func(mock.Arguments) {
+		now := time.Now()
+		key.RevokedAt = &now
+	}).Return(true, nil).Once()
+
+	// A key cannot revoke itself, or any other key
+	err := authService.RevokeAPIKey(ctx, rawKey, key.ID.Hex())
+	require.Error(t, err)
+	_, err = authService.VerifyToken(ctx, rawKey)
+	require.NoError(t, err)
+
+	require.NoError(t, authService.RevokeAPIKey(ctx, login.AccessToken, key.ID.Hex()))
+	_, err = authService.VerifyToken(ctx, rawKey)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid API key")
+	repos.apiKeys.AssertExpectations(t)
+}
+
+func TestMultiTenantAuthService_APIKeyCannotManageAccount(t *testing.T) {
+	ctx := context.Background()
+	repos, _ := apiKeyRepos(t)
+	authService := newTestAuthService(repos)
+
+	_, _, rawKey := createAPIKey(t, authService, repos, &domain.CreateAPIKeyRequest{
+		Name:        "ci",
+		Permissions: []string{"user.read"},
+	})
+
+	err := authService.ChangePassword(ctx, rawKey, testPassword, "Battery-Staple-2", false)
+	assert.Error(t, err)
+	_, err = authService.EnrollTOTP(ctx, rawKey, "")
+	assert.Error(t, err)
+	_, err = authService.StartOAuthLink(ctx, rawKey, "google")
+	assert.Error(t, err)
+	err = authService.UnlinkOAuthAccount(ctx, rawKey, "google")
+	assert.Error(t, err)
+	_, err = authService.ListSessions(ctx, rawKey)
+	assert.Error(t, err)
+	_, _, err = authService.CreateAPIKey(ctx, rawKey, &domain.CreateAPIKeyRequest{
+		Name:        "copy",
+		Permissions: []string{"user.read"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "API keys cannot manage the account")
+
+	repos.users.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	repos.apiKeys.AssertNumberOfCalls(t, "Create", 1)
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/oidc"
+	"go.uber.org/zap"
+)
+
+// tokenTypeHintRefreshToken makes introspection and revocation look the token up as a refresh token first (RFC 7009 2.1)
+const tokenTypeHintRefreshToken = "refresh_token"
+
+// IntrospectToken describes an access or refresh token to a resource server (RFC 7662).
+// Only confidential clients may introspect, and tokens of other tenants are reported as inactive.
+// Errors are returned as *oidc.Error.
+func (s *MultiTenantAuthService) IntrospectToken(ctx context.Context, req *domain.TokenIntrospectionRequest) (*domain.TokenIntrospectionResponse, error) {
+	client, err := s.authenticateOIDCClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if client.Public {
+		return nil, oidc.NewError(oidc.ErrInvalidClient, "Public clients cannot introspect tokens")
+	}
+
+	lookups := []func(context.Context, string) *domain.TokenIntrospectionResponse{s.introspectAccessToken, s.introspectRefreshToken}
+	if req.TokenTypeHint == tokenTypeHintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		response := lookup(ctx, req.Token)
+		if response == nil {
+			continue
+		}
+		if !response.Active || response.Tenant != client.TenantID {
+			break
+		}
+		return response, nil
+	}
+	return &domain.TokenIntrospectionResponse{Active: false}, nil
+}
+
+// RevokeOAuthToken revokes an access or refresh token (RFC 7009). Revoking a refresh token ends its
+// whole login; revoking an access token only deletes that token. Public clients may revoke tokens
+// issued to them, confidential clients any token of their tenant. Unknown tokens are not an error.
+// Errors are returned as *oidc.Error.
+func (s *MultiTenantAuthService) RevokeOAuthToken(ctx context.Context, req *domain.TokenRevocationRequest) error {
+	client, err := s.authenticateOIDCClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	revokeAccess := func() (bool, error) {
+		if s.store == nil {
+			return false, nil
+		}
+		tokenHash := s.tokenHasher.Hash(req.Token)
+		var session domain.Session
+		if err := s.store.Get(ctx, sessionKey(tokenHash), &session); err != nil {
+			return false, nil
+		}
+		if !clientMayRevoke(client, session.TenantID, session.ClientID) {
+			return true, oidc.NewError(oidc.ErrUnauthorizedClient, "Token was not issued to this client")
+		}
+		s.deleteAccessToken(ctx, tokenHash, &session)
+		return true, nil
+	}
+	revokeRefresh := func() (bool, error) {
+		token, err := s.refreshTokenRepo.FindByTokenHash(ctx, s.tokenHasher.Hash(req.Token))
+		if err != nil {
+			s.logger.Error("Failed to find refresh token", zap.Error(err))
+			return false, oidc.NewError(oidc.ErrServerError, "")
+		}
+		if token == nil {
+			return false, nil
+		}
+		// Refresh tokens are only issued by the login API, never to a client
+		if !clientMayRevoke(client, token.TenantID, "") {
+			return true, oidc.NewError(oidc.ErrUnauthorizedClient, "Token was not issued to this client")
+		}
+		if err := s.endLogin(ctx, token); err != nil {
+			s.logger.Error("Failed to revoke refresh token family", zap.Error(err))
+			return true, oidc.NewError(oidc.ErrServerError, "")
+		}
+		return true, nil
+	}
+
+	revokes := []func() (bool, error){revokeAccess, revokeRefresh}
+	if req.TokenTypeHint == tokenTypeHintRefreshToken {
+		revokes[0], revokes[1] = revokes[1], revokes[0]
+	}
+	for _, revoke := range revokes {
+		found, err := revoke()
+		if err != nil {
+			return err
+		}
+		if found {
+			s.logger.Info("Token revoked", zap.String("client_id", client.ClientID))
+			return nil
+		}
+	}
+	return nil
+}
+
+// introspectAccessToken describes an access token, or returns nil when it is unknown.
+// As with VerifyToken, introspecting counts as using the token for the idle timeout.
+func (s *MultiTenantAuthService) introspectAccessToken(ctx context.Context, token string) *domain.TokenIntrospectionResponse {
+	if s.store == nil {
+		return nil
+	}
+	session, err := s.tokenSession(ctx, token)
+	if err != nil {
+		return nil
+	}
+
+	return &domain.TokenIntrospectionResponse{
+		Active:    true,
+		Scope:     session.Scope,
+		ClientID:  session.ClientID,
+		Username:  session.Email,
+		TokenType: "Bearer",
+		Exp:       session.ExpiresAt.Unix(),
+		Iat:       session.CreatedAt.Unix(),
+		Sub:       session.UserID,
+		Tenant:    session.TenantID,
+	}
+}
+
+// introspectRefreshToken describes a refresh token, or returns nil when it is unknown
+func (s *MultiTenantAuthService) introspectRefreshToken(ctx context.Context, token string) *domain.TokenIntrospectionResponse {
+	refreshToken, err := s.refreshTokenRepo.FindByTokenHash(ctx, s.tokenHasher.Hash(token))
+	if err != nil || refreshToken == nil {
+		return nil
+	}
+
+	response := &domain.TokenIntrospectionResponse{Tenant: refreshToken.TenantID}
+	if refreshToken.RotatedAt != nil || refreshToken.RevokedAt != nil || time.Now().After(refreshToken.ExpiresAt) {
+		return response
+	}
+	policy := s.sessionPolicy(ctx, refreshToken.TenantID)
+	if policy.IdleTimeout > 0 && time.Since(s.lastActivity(ctx, refreshToken)) > policy.IdleTimeout {
+		return response
+	}
+
+	response.Active = true
+	response.Exp = refreshToken.ExpiresAt.Unix()
+	response.Iat = refreshToken.CreatedAt.Unix()
+	response.Sub = refreshToken.UserID
+	return response
+}
+
+// clientMayRevoke reports whether a client may revoke a token of a tenant, issued to issuedTo (empty for the login API)
+func clientMayRevoke(client *domain.OIDCClient, tenantID, issuedTo string) bool {
+	if issuedTo != "" && issuedTo == client.ClientID {
+		return true
+	}
+	return !client.Public && tenantID == client.TenantID
+}
@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/oidc"
+)
+
+// introspectionRequest introspects a token as the confidential "app" client of oidcRepos
+func introspectionRequest(token string) *domain.TokenIntrospectionRequest {
+	return &domain.TokenIntrospectionRequest{Token: token, ClientID: "app", ClientSecret: testClientSecret}
+}
+
+func TestMultiTenantAuthService_IntrospectToken(t *testing.T) {
+	ctx := context.Background()
+	repos, user, _ := oidcRepos(t)
+	repos.refreshTokens.On("FindByTokenHash", mock.Anything, mock.Anything).Return(nil, nil)
+	authService := newTestAuthService(repos)
+
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+
+	resp, err := authService.IntrospectToken(ctx, introspectionRequest(login.AccessToken))
+	require.NoError(t, err)
+	assert.True(t, resp.Active)
+	assert.Equal(t, user.ID.Hex(), resp.Sub)
+	assert.Equal(t, testTenantID, resp.Tenant)
+	assert.Equal(t, testEmail, resp.Username)
+
+	resp, err = authService.IntrospectToken(ctx, introspectionRequest("unknown-token"))
+	require.NoError(t, err)
+	assert.False(t, resp.Active)
+
+	t.Run("wrong secret", func(t *testing.T) {
+		req := introspectionRequest(login.AccessToken)
+		req.ClientSecret = "wrong-secret"
+		_, err := authService.IntrospectToken(ctx, req)
+		assertOIDCError(t, err, oidc.ErrInvalidClient)
+	})
+
+	t.Run("public client", func(t *testing.T) {
+		_, err := authService.IntrospectToken(ctx, &domain.TokenIntrospectionRequest{Token: login.AccessToken, ClientID: "spa"})
+		assertOIDCError(t, err, oidc.ErrInvalidClient)
+	})
+
+	t.Run("client of another tenant", func(t *testing.T) {
+		req := introspectionRequest(login.AccessToken)
+		req.ClientID = "partner"
+		resp, err := authService.IntrospectToken(ctx, req)
+		require.NoError(t, err)
+		assert.False(t, resp.Active, "tokens of other tenants are reported as inactive")
+		assert.Empty(t, resp.Sub)
+	})
+}
+
+func TestMultiTenantAuthService_IntrospectToken_RefreshToken(t *testing.T) {
+	ctx := context.Background()
+	repos, user, issued := oidcRepos(t)
+	authService := newTestAuthService(repos)
+
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	refreshToken := (*issued)[0]
+	repos.refreshTokens.On("FindByTokenHash", mock.Anything, refreshToken.TokenHash).Return(refreshToken, nil)
+
+	req := introspectionRequest(login.RefreshToken)
+	req.TokenTypeHint = "refresh_token"
+	resp, err := authService.IntrospectToken(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, resp.Active)
+	assert.Equal(t, user.ID.Hex(), resp.Sub)
+
+	req.ClientID = "partner"
+	resp, err = authService.IntrospectToken(ctx, req)
+	require.NoError(t, err)
+	assert.False(t, resp.Active)
+}
+
+func TestMultiTenantAuthService_RevokeOAuthToken(t *testing.T) {
+	ctx := context.Background()
+	repos, _, _ := oidcRepos(t)
+	repos.refreshTokens.On("FindByTokenHash", mock.Anything, mock.Anything).Return(nil, nil)
+	authService := newTestAuthService(repos)
+
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+
+	// Public clients and clients of other tenants cannot revoke the user's own sign-in
+	err = authService.RevokeOAuthToken(ctx, &domain.TokenRevocationRequest{Token: login.AccessToken, ClientID: "spa"})
+	assertOIDCError(t, err, oidc.ErrUnauthorizedClient)
+	err = authService.RevokeOAuthToken(ctx, &domain.TokenRevocationRequest{
+		Token:        login.AccessToken,
+		ClientID:     "partner",
+		ClientSecret: testClientSecret,
+	})
+	assertOIDCError(t, err, oidc.ErrUnauthorizedClient)
+	err = authService.RevokeOAuthToken(ctx, &domain.TokenRevocationRequest{
+		Token:        login.AccessToken,
+		ClientID:     "app",
+		ClientSecret: "wrong-secret",
+	})
+	assertOIDCError(t, err, oidc.ErrInvalidClient)
+	_, err = authService.VerifyToken(ctx, login.AccessToken)
+	require.NoError(t, err)
+
+	require.NoError(t, authService.RevokeOAuthToken(ctx, &domain.TokenRevocationRequest{
+		Token:        login.AccessToken,
+		ClientID:     "app",
+		ClientSecret: testClientSecret,
+	}))
+	_, err = authService.VerifyToken(ctx, login.AccessToken)
+	assert.Error(t, err)
+
+	// A public client can revoke the tokens issued to it
+	code := authorizeCode(t, authService, "spa")
+	exchange := tokenRequest(code)
+	exchange.ClientID, exchange.ClientSecret = "spa", ""
+	tokens, err := authService.ExchangeOIDCCode(ctx, exchange)
+	require.NoError(t, err)
+	require.NoError(t, authService.RevokeOAuthToken(ctx, &domain.TokenRevocationRequest{Token: tokens.AccessToken, ClientID: "spa"}))
+	_, err = authService.VerifyToken(ctx, tokens.AccessToken)
+	assert.Error(t, err)
+
+	// Unknown tokens are not an error
+	assert.NoError(t, authService.RevokeOAuthToken(ctx, &domain.TokenRevocationRequest{Token: "unknown-token", ClientID: "spa"}))
+}
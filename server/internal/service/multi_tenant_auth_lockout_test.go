@@ -0,0 +1,125 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+)
+
+// failedLoginRepos returns mocks for a login with a wrong password that counts the given
+// failures for the identifier and the IP address
+func failedLoginRepos(t *testing.T, identifierFailures, ipFailures int64) (testRepos, *domain.User) {
+	user := testUser(t)
+	repos := testRepos{
+		users:        &MockUserRepository{},
+		userTenants:  &MockUserTenantRepository{},
+		loginConfigs: &MockTenantLoginConfigRepository{},
+		attempts:     &MockLoginAttemptRepository{},
+		lockouts:     &MockUserLockoutRepository{},
+	}
+	repos.loginConfigs.On("FindByTenant", mock.Anything, testTenantID).Return(testLoginConfig(), nil)
+	repos.lockouts.On("FindActive", mock.Anything, testTenantID, testEmail, testIP).Return(nil, nil)
+	repos.users.On("FindByIdentifier", mock.Anything, testEmail).Return(user, nil)
+	repos.userTenants.On("FindByUserAndTenant", mock.Anything, user.ID.Hex(), testTenantID).Return(testMembership(user, "member"), nil)
+	repos.attempts.On("Create", mock.Anything, mock.MatchedBy(func(attempt *domain.LoginAttempt) bool {
+		return !attempt.Success && attempt.Identifier == testEmail && attempt.IPAddress == testIP
+	})).Return(nil).Once()
+	repos.lockouts.On("FindLatest", mock.Anything, testTenantID, testEmail, "").Return(nil, nil)
+	repos.lockouts.On("FindLatest", mock.Anything, testTenantID, "", testIP).Return(nil, nil)
+	repos.attempts.On("CountFailuresByIdentifier", mock.Anything, testTenantID, testEmail, mock.Anything).Return(identifierFailures, nil)
+	repos.attempts.On("CountFailuresByIP", mock.Anything, testTenantID, testIP, mock.Anything).Return(ipFailures, nil)
+	return repos, user
+}
+
+func TestMultiTenantAuthService_Login_LockoutThresholds(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Failures below the limit do not lock", func(t *testing.T) {
+		repos, _ := failedLoginRepos(t, 2, 2)
+		authService := newTestAuthService(repos)
+
+		_, err := authService.Login(ctx, testEmail, "wrong", testTenantID, testIP)
+
+		assert.Error(t, err)
+		repos.lockouts.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		repos.attempts.AssertExpectations(t)
+	})
+
+	t.Run("Reaching the limit locks the identifier", func(t *testing.T) {
+		repos, user := failedLoginRepos(t, 3, 1)
+		var lockout *domain.UserLockout
+		repos.lockouts.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			lockout = args.Get(1).(*domain.UserLockout)
+		}).Return(nil).Once()
+		authService := newTestAuthService(repos)
+
+		_, err := authService.Login(ctx, testEmail, "wrong", testTenantID, testIP)
+
+		assert.Error(t, err)
+		require.NotNil(t, lockout)
+		assert.Equal(t, testEmail, lockout.Identifier)
+		assert.Empty(t, lockout.IPAddress)
+		assert.Equal(t, user.ID.Hex(), lockout.UserID)
+		assert.WithinDuration(t, time.Now().Add(15*time.Minute), lockout.UnlockAt, time.Minute)
+		repos.lockouts.AssertExpectations(t)
+	})
+
+	t.Run("Reaching the limit from an IP address locks the address", func(t *testing.T) {
+		repos, _ := failedLoginRepos(t, 1, 3)
+		var lockout *domain.UserLockout
+		repos.lockouts.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			lockout = args.Get(1).(*domain.UserLockout)
+		}).Return(nil).Once()
+		authService := newTestAuthService(repos)
+
+		_, err := authService.Login(ctx, testEmail, "wrong", testTenantID, testIP)
+
+		assert.Error(t, err)
+		require.NotNil(t, lockout)
+		assert.Empty(t, lockout.Identifier)
+		assert.Equal(t, testIP, lockout.IPAddress)
+		repos.lockouts.AssertExpectations(t)
+	})
+
+	t.Run("Failures before the previous lockout ended are not counted", func(t *testing.T) {
+		repos, _ := failedLoginRepos(t, 0, 0)
+		ended := time.Now().Add(-time.Minute)
+		repos.lockouts.ExpectedCalls = nil
+		repos.lockouts.On("FindActive", mock.Anything, testTenantID, testEmail, testIP).Return(nil, nil)
+		repos.lockouts.On("FindLatest", mock.Anything, testTenantID, testEmail, "").
+			Return(&domain.UserLockout{Identifier: testEmail, UnlockAt: ended}, nil)
+		repos.lockouts.On("FindLatest", mock.Anything, testTenantID, "", testIP).Return(nil, nil)
+		authService := newTestAuthService(repos)
+
+		_, err := authService.Login(ctx, testEmail, "wrong", testTenantID, testIP)
+
+		assert.Error(t, err)
+		repos.attempts.AssertCalled(t, "CountFailuresByIdentifier", mock.Anything, testTenantID, testEmail, ended)
+		repos.lockouts.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestMultiTenantAuthService_Login_LockedOut(t *testing.T) {
+	ctx := context.Background()
+	repos := testRepos{
+		users:        &MockUserRepository{},
+		loginConfigs: &MockTenantLoginConfigRepository{},
+		lockouts:     &MockUserLockoutRepository{},
+	}
+	repos.loginConfigs.On("FindByTenant", mock.Anything, testTenantID).Return(testLoginConfig(), nil)
+	repos.lockouts.On("FindActive", mock.Anything, testTenantID, testEmail, testIP).
+		Return(&domain.UserLockout{Identifier: testEmail, UnlockAt: time.Now().Add(10 * time.Minute)}, nil)
+	authService := newTestAuthService(repos)
+
+	// The correct password is rejected too, without checking it
+	_, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Too many failed login attempts")
+	repos.users.AssertNotCalled(t, "FindByIdentifier", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+	"github.com/vhvplatform/go-shared/errors"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// MFAPendingTokenType is the token type returned by Login when a second factor is required
+	MFAPendingTokenType = "mfa_pending"
+
+	mfaChallengeTTL   = 5 * time.Minute
+	maxMFAAttempts    = 5
+	recoveryCodeCount = 10
+	totpSkew          = 1 // Accept codes from one step before and after the current one
+)
+
+// createMFAChallenge stores a short-lived challenge and returns its token instead of a session
+func (s *MultiTenantAuthService) createMFAChallenge(ctx context.Context, user *domain.User, tenantID, identifier, ipAddress string, enrollmentRequired bool) (*domain.LoginResponse, error) {
+	if s.store == nil {
+		return nil, errors.Internal("Session store not available")
+	}
+
+	token, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, errors.Internal("Failed to generate MFA token")
+	}
+
+	now := time.Now()
+	challenge := domain.MFAChallenge{
+		UserID:     user.ID.Hex(),
+		TenantID:   tenantID,
+		Identifier: identifier,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(mfaChallengeTTL),
+	}
+	if err := s.store.Set(ctx, mfaChallengeKey(s.tokenHasher.Hash(token)), challenge, mfaChallengeTTL); err != nil {
+		s.logger.Error("Failed to store MFA challenge in Redis", zap.Error(err))
+		return nil, errors.Internal("Failed to create MFA challenge")
+	}
+
+	s.logger.Info("Second factor required",
+		zap.String("user_id", challenge.UserID),
+		zap.String("tenant_id", tenantID),
+		zap.Bool("enrollment_required", enrollmentRequired))
+
+	return &domain.LoginResponse{
+		TokenType:             MFAPendingTokenType,
+		ExpiresIn:             int64(mfaChallengeTTL.Seconds()),
+		MFARequired:           true,
+		MFAEnrollmentRequired: enrollmentRequired,
+		MFAToken:              token,
+		User: domain.UserInfo{
+			ID:       challenge.UserID,
+			Email:    user.Email,
+			TenantID: tenantID,
+		},
+	}, nil
+}
+
+// EnrollTOTP starts TOTP enrollment and returns the secret and otpauth URI.
+// When a code is given it confirms the pending enrollment and returns the recovery codes instead.
+// The token is either an access token or the mfa_pending token returned by Login.
+func (s *MultiTenantAuthService) EnrollTOTP(ctx context.Context, token, code string) (*domain.TOTPEnrollment, error) {
+	user, tenantID, err := s.resolveMFAUser(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	userID := user.ID.Hex()
+
+	mfa, err := s.userMFARepo.FindByUser(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user mfa", zap.Error(err))
+		return nil, errors.Internal("Failed to enroll TOTP")
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, errors.Conflict("Two-factor authentication is already enabled")
+	}
+
+	// Confirm a pending enrollment
+	if code != "" {
+		if mfa == nil || mfa.TOTPSecret == "" {
+			return nil, errors.BadRequest("No pending TOTP enrollment")
+		}
+		step, ok := authutils.ValidateTOTPCode(mfa.TOTPSecret, code, time.Now(), totpSkew)
+		if !ok {
+			return nil, errors.Unauthorized("Invalid verification code")
+		}
+		recoveryCodes, err := s.enableTOTP(ctx, userID, step)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.TOTPEnrollment{
+			RecoveryCodes: recoveryCodes,
+			Enabled:       true,
+		}, nil
+	}
+
+	// Start a new enrollment
+	secret, err := authutils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, errors.Internal("Failed to generate TOTP secret")
+	}
+	if err := s.userMFARepo.SetPendingSecret(ctx, userID, secret); err != nil {
+		s.logger.Error("Failed to store TOTP secret", zap.Error(err))
+		return nil, errors.Internal("Failed to enroll TOTP")
+	}
+
+	return &domain.TOTPEnrollment{
+		Secret:     secret,
+		OTPAuthURI: authutils.TOTPProvisioningURI(tenantID, totpAccountName(user), secret),
+		Enabled:    false,
+	}, nil
+}
+
+// VerifyMFA checks a TOTP or recovery code for a pending login and issues the session.
+// A valid TOTP code for a pending enrollment also enables the factor and returns recovery codes.
+// Wrong codes count as failed logins of the identifier the challenge was started with.
+func (s *MultiTenantAuthService) VerifyMFA(ctx context.Context, mfaToken, code, recoveryCode string) (*domain.LoginResponse, error) {
+	challenge, err := s.getMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	loginConfig, err := s.tenantLoginConfigRepo.FindByTenant(ctx, challenge.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Identifier != "" {
+		if err := s.checkLockout(ctx, challenge.TenantID, challenge.Identifier, challenge.IPAddress); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.claimMFAAttempt(ctx, mfaToken, challenge); err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.userMFARepo.FindByUser(ctx, challenge.UserID)
+	if err != nil {
+		s.logger.Error("Failed to get user mfa", zap.Error(err))
+		return nil, errors.Internal("Failed to verify second factor")
+	}
+	if mfa == nil || mfa.TOTPSecret == "" {
+		return nil, errors.BadRequest("Two-factor authentication is not set up, enroll TOTP first")
+	}
+
+	verified, recoveryCodes, err := s.verifySecondFactor(ctx, mfa, code, recoveryCode)
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		if challenge.Identifier != "" {
+			s.recordLoginAttempt(ctx, loginConfig, challenge.TenantID, challenge.Identifier, challenge.IPAddress, challenge.UserID, false)
+		}
+		return nil, errors.Unauthorized("Invalid verification code")
+	}
+	mfaTokenHash := s.tokenHasher.Hash(mfaToken)
+	_ = s.store.Delete(ctx, mfaChallengeKey(mfaTokenHash), mfaAttemptsKey(mfaTokenHash))
+
+	// Re-check the account, it may have changed while the challenge was pending
+	user, err := s.userRepo.FindByID(ctx, challenge.UserID)
+	if err != nil || user == nil {
+		return nil, errors.Unauthorized("User not found")
+	}
+	if !user.IsActive {
+		return nil, errors.Forbidden("User account is deactivated")
+	}
+	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, challenge.UserID, challenge.TenantID)
+	if err != nil || userTenant == nil || !userTenant.IsActive {
+		return nil, errors.Forbidden("User does not have access to this tenant")
+	}
+
+	response, err := s.completeLogin(ctx, user, userTenant, loginConfig, challenge.Identifier, challenge.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	response.RecoveryCodes = recoveryCodes
+
+	s.logger.Info("User logged in with second factor",
+		zap.String("user_id", challenge.UserID),
+		zap.String("tenant_id", challenge.TenantID),
+		zap.Bool("recovery_code", recoveryCode != "" && code == ""))
+
+	return response, nil
+}
+
+// verifySecondFactor validates a TOTP code or consumes a recovery code
+func (s *MultiTenantAuthService) verifySecondFactor(ctx context.Context, mfa *domain.UserMFA, code, recoveryCode string) (bool, []string, error) {
+	switch {
+	case code != "":
+		step, ok := authutils.ValidateTOTPCode(mfa.TOTPSecret, code, time.Now(), totpSkew)
+		if !ok {
+			return false, nil, nil
+		}
+		if !mfa.Enabled {
+			// The first valid code confirms a pending enrollment
+			recoveryCodes, err := s.enableTOTP(ctx, mfa.UserID, step)
+			if err != nil {
+				return false, nil, err
+			}
+			return true, recoveryCodes, nil
+		}
+		used, err := s.userMFARepo.UseStep(ctx, mfa.UserID, step)
+		if err != nil {
+			s.logger.Error("Failed to record TOTP step", zap.Error(err))
+			return false, nil, errors.Internal("Failed to verify second factor")
+		}
+		return used, nil, nil
+
+	case recoveryCode != "" && mfa.Enabled:
+		consumed, err := s.userMFARepo.ConsumeRecoveryCode(ctx, mfa.UserID, s.tokenHasher.Hash(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			s.logger.Error("Failed to consume recovery code", zap.Error(err))
+			return false, nil, errors.Internal("Failed to verify second factor")
+		}
+		return consumed, nil, nil
+	}
+
+	return false, nil, nil
+}
+
+// enableTOTP confirms the pending secret and returns freshly generated recovery codes
+func (s *MultiTenantAuthService) enableTOTP(ctx context.Context, userID string, step int64) ([]string, error) {
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, errors.Internal("Failed to generate recovery codes")
+	}
+	if err := s.userMFARepo.Enable(ctx, userID, hashes, step); err != nil {
+		s.logger.Error("Failed to enable TOTP", zap.Error(err))
+		return nil, errors.Internal("Failed to enable two-factor authentication")
+	}
+
+	s.logger.Info("TOTP enabled", zap.String("user_id", userID))
+	return codes, nil
+}
+
+// resolveMFAUser finds the user behind an mfa_pending token or an access token
+func (s *MultiTenantAuthService) resolveMFAUser(ctx context.Context, token string) (*domain.User, string, error) {
+	var userID, tenantID string
+	if challenge, err := s.getMFAChallenge(ctx, token); err == nil {
+		userID, tenantID = challenge.UserID, challenge.TenantID
+	} else {
+		session, err := s.sessionForToken(ctx, token)
+		if err != nil {
+			return nil, "", err
+		}
+		userID, tenantID = session.UserID, session.TenantID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, "", errors.Unauthorized("User not found")
+	}
+	return user, tenantID, nil
+}
+
+// getMFAChallenge loads a pending challenge by its token
+func (s *MultiTenantAuthService) getMFAChallenge(ctx context.Context, token string) (*domain.MFAChallenge, error) {
+	if s.store == nil {
+		return nil, errors.Internal("Session store not available")
+	}
+
+	tokenHash := s.tokenHasher.Hash(token)
+	var challenge domain.MFAChallenge
+	if err := s.store.Get(ctx, mfaChallengeKey(tokenHash), &challenge); err != nil {
+		return nil, errors.Unauthorized("Invalid or expired MFA token")
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		_ = s.store.Delete(ctx, mfaChallengeKey(tokenHash))
+		return nil, errors.Unauthorized("MFA token expired")
+	}
+	return &challenge, nil
+}
+
+// claimMFAAttempt counts an attempt at a challenge before its code is checked, so concurrent
+// requests cannot try more codes than allowed. The challenge is dropped once they are used up.
+func (s *MultiTenantAuthService) claimMFAAttempt(ctx context.Context, token string, challenge *domain.MFAChallenge) error {
+	tokenHash := s.tokenHasher.Hash(token)
+	attempts, err := s.store.Increment(ctx, mfaAttemptsKey(tokenHash), time.Until(challenge.ExpiresAt))
+	if err != nil {
+		s.logger.Error("Failed to count MFA attempt", zap.Error(err))
+		return errors.Internal("Failed to verify second factor")
+	}
+	if attempts > maxMFAAttempts {
+		_ = s.store.Delete(ctx, mfaChallengeKey(tokenHash), mfaAttemptsKey(tokenHash))
+		s.logger.Warn("MFA challenge dropped after too many attempts",
+			zap.String("user_id", challenge.UserID),
+			zap.String("tenant_id", challenge.TenantID))
+		return errors.Unauthorized("Too many attempts, sign in again")
+	}
+	return nil
+}
+
+func mfaChallengeKey(tokenHash string) string {
+	return fmt.Sprintf("mfa_pending:%s", tokenHash)
+}
+
+func mfaAttemptsKey(tokenHash string) string {
+	return fmt.Sprintf("mfa_attempts:%s", tokenHash)
+}
+
+// totpAccountName picks the label shown in authenticator apps
+func totpAccountName(user *domain.User) string {
+	switch {
+	case user.Email != "":
+		return user.Email
+	case user.Username != "":
+		return user.Username
+	case user.Phone != "":
+		return user.Phone
+	default:
+		return user.ID.Hex()
+	}
+}
+
+// generateRecoveryCodes returns one-time recovery codes and their hashes
+func (s *MultiTenantAuthService) generateRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, s.tokenHasher.Hash(raw))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts codes with or without the dash and in any case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/store"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+)
+
+func TestMultiTenantAuthService_VerifyMFA_WrongCodes(t *testing.T) {
+	ctx := context.Background()
+	repos, user := failedLoginRepos(t, 1, 1)
+	repos.mfa = &MockUserMFARepository{}
+	repos.store = store.NewMemoryStore()
+	secret, err := authutils.GenerateTOTPSecret()
+	require.NoError(t, err)
+	repos.mfa.On("FindByUser", mock.Anything, user.ID.Hex()).
+		Return(&domain.UserMFA{UserID: user.ID.Hex(), TOTPSecret: secret, Enabled: true}, nil)
+	authService := newTestAuthService(repos)
+
+	// The password alone is not a successful login yet
+	response, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	require.True(t, response.MFARequired)
+	repos.attempts.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	var pending domain.MFAChallenge
+	assert.ErrorIs(t, repos.store.Get(ctx, "mfa_pending:"+response.MFAToken, &pending), store.ErrNotFound,
+		"the challenge is stored under the hash of its token")
+
+	// Every wrong code is a failed login of the identifier and the IP address
+	repos.attempts.ExpectedCalls = nil
+	repos.attempts.On("CountFailuresByIdentifier", mock.Anything, testTenantID, testEmail, mock.Anything).Return(int64(1), nil)
+	repos.attempts.On("CountFailuresByIP", mock.Anything, testTenantID, testIP, mock.Anything).Return(int64(1), nil)
+	repos.attempts.On("Create", mock.Anything, mock.MatchedBy(func(attempt *domain.LoginAttempt) bool {
+		return !attempt.Success && attempt.Identifier == testEmail && attempt.IPAddress == testIP
+	})).Return(nil).Times(5)
+	for i := 0; i < 5; i++ {
+		_, err = authService.VerifyMFA(ctx, response.MFAToken, "000000", "")
+		require.Error(t, err)
+	}
+	repos.attempts.AssertExpectations(t)
+	repos.attempts.AssertNumberOfCalls(t, "CountFailuresByIdentifier", 5)
+
+	// The challenge is dropped once its attempts are used up
+	_, err = authService.VerifyMFA(ctx, response.MFAToken, "000000", "")
+	require.Error(t, err)
+	_, err = authService.VerifyMFA(ctx, response.MFAToken, "000000", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid or expired MFA token")
+	repos.attempts.AssertNumberOfCalls(t, "Create", 5)
+}
+
+func TestMultiTenantAuthService_VerifyMFA_LockedOut(t *testing.T) {
+	ctx := context.Background()
+	repos, user := failedLoginRepos(t, 0, 0)
+	repos.mfa = &MockUserMFARepository{}
+	repos.store = store.NewMemoryStore()
+	repos.mfa.On("FindByUser", mock.Anything, user.ID.Hex()).
+		Return(&domain.UserMFA{UserID: user.ID.Hex(), TOTPSecret: "secret", Enabled: true}, nil)
+	authService := newTestAuthService(repos)
+
+	response, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+
+	// Codes are not checked once the identifier is locked out
+	repos.lockouts.ExpectedCalls = nil
+	repos.lockouts.On("FindActive", mock.Anything, testTenantID, testEmail, testIP).
+		Return(&domain.UserLockout{Identifier: testEmail}, nil)
+	_, err = authService.VerifyMFA(ctx, response.MFAToken, "000000", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Too many failed login attempts")
+	repos.mfa.AssertNumberOfCalls(t, "FindByUser", 1)
+}
+
+func TestMultiTenantAuthService_VerifyMFA_RecoveryCode(t *testing.T) {
+	ctx := context.Background()
+	repos, user, _ := sessionRepos(t, testLoginConfig())
+	repos.mfa.ExpectedCalls = nil
+	repos.mfa.On("FindByUser", mock.Anything, user.ID.Hex()).
+		Return(&domain.UserMFA{UserID: user.ID.Hex(), TOTPSecret: "secret", Enabled: true}, nil)
+	// Recovery codes are looked up by their keyed hash, normalized to lower case without the dash
+	codeHash := authutils.NewTokenHasher(testHashKey).Hash("abcde12345")
+	repos.mfa.On("ConsumeRecoveryCode", mock.Anything, user.ID.Hex(), codeHash).Return(true, nil).Once()
+	repos.mfa.On("ConsumeRecoveryCode", mock.Anything, user.ID.Hex(), mock.Anything).Return(false, nil)
+	authService := newTestAuthService(repos)
+
+	response, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	require.True(t, response.MFARequired)
+
+	login, err := authService.VerifyMFA(ctx, response.MFAToken, "", "ABCDE-12345")
+	require.NoError(t, err)
+	assert.NotEmpty(t, login.AccessToken)
+	repos.mfa.AssertExpectations(t)
+}
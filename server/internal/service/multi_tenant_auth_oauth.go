@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/oauth"
+	"github.com/vhvplatform/go-shared/errors"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.uber.org/zap"
+)
+
+// oauthStateTTL is how long a started social login may take before its state expires
+const oauthStateTTL = 10 * time.Minute
+
+// StartOAuthLogin starts a social login for a tenant and returns the provider authorization URL
+func (s *MultiTenantAuthService) StartOAuthLogin(ctx context.Context, provider, tenantID string) (*domain.OAuthAuthorization, error) {
+	if _, err := s.tenantLoginConfigRepo.FindByTenant(ctx, tenantID); err != nil {
+		return nil, err
+	}
+	return s.startOAuth(ctx, provider, tenantID, "")
+}
+
+// StartOAuthLink starts linking a provider account to the user behind an access token
+func (s *MultiTenantAuthService) StartOAuthLink(ctx context.Context, accessToken, provider string) (*domain.OAuthAuthorization, error) {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.startOAuth(ctx, provider, session.TenantID, session.UserID)
+}
+
+// HandleOAuthCallback completes a social login. The provider account is matched to a linked user,
+// then to a user with the same verified email, and a new user is created when the tenant allows registration.
+func (s *MultiTenantAuthService) HandleOAuthCallback(ctx context.Context, provider, code, state string) (*domain.LoginResponse, error) {
+	oauthState, info, err := s.completeOAuth(ctx, provider, code, state)
+	if err != nil {
+		return nil, err
+	}
+	if oauthState.LinkUserID != "" {
+		return nil, errors.BadRequest("State was issued for account linking")
+	}
+	tenantID := oauthState.TenantID
+
+	loginConfig, err := s.tenantLoginConfigRepo.FindByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, created, err := s.findOrCreateOAuthUser(ctx, oauthState.Provider, info, loginConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, errors.Forbidden("User account is deactivated")
+	}
+
+	userID := user.ID.Hex()
+	if created {
+		userTenant := &domain.UserTenant{
+			UserID:   userID,
+			TenantID: tenantID,
+			Roles:    []string{"user"},
+			IsActive: true,
+		}
+		if err := s.userTenantRepo.Create(ctx, userTenant); err != nil {
+			s.logger.Error("Failed to create user-tenant relationship", zap.Error(err))
+			return nil, errors.Internal("Failed to create user")
+		}
+	}
+
+	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, userID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if userTenant == nil {
+		return nil, errors.Forbidden("User does not have access to this tenant")
+	}
+	if !userTenant.IsActive {
+		return nil, errors.Forbidden("User access to this tenant is deactivated")
+	}
+
+	response, err := s.startSession(ctx, user, userTenant, loginConfig, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("User logged in with social provider",
+		zap.String("user_id", userID),
+		zap.String("tenant_id", tenantID),
+		zap.String("provider", string(oauthState.Provider)),
+		zap.Bool("created", created))
+
+	return response, nil
+}
+
+// LinkOAuthAccount completes linking a provider account to the signed-in user
+func (s *MultiTenantAuthService) LinkOAuthAccount(ctx context.Context, accessToken, provider, code, state string) (*domain.OAuthAccount, error) {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	oauthState, info, err := s.completeOAuth(ctx, provider, code, state)
+	if err != nil {
+		return nil, err
+	}
+	if oauthState.LinkUserID == "" || oauthState.LinkUserID != session.UserID {
+		return nil, errors.BadRequest("State was not issued for linking this account")
+	}
+
+	existing, err := s.oauthAccountRepo.FindByProviderID(ctx, oauthState.Provider, info.Subject)
+	if err != nil {
+		s.logger.Error("Failed to find oauth account", zap.Error(err))
+		return nil, errors.Internal("Failed to link account")
+	}
+	if existing != nil {
+		if existing.UserID != session.UserID {
+			return nil, errors.Conflict("This provider account is linked to another user")
+		}
+		return existing, nil
+	}
+
+	account, err := s.linkOAuthAccount(ctx, session.UserID, oauthState.Provider, info)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Social account linked",
+		zap.String("user_id", session.UserID),
+		zap.String("provider", string(oauthState.Provider)))
+
+	return account, nil
+}
+
+// UnlinkOAuthAccount removes a provider account from the signed-in user.
+// The last sign-in method of a user without a password cannot be removed.
+func (s *MultiTenantAuthService) UnlinkOAuthAccount(ctx context.Context, accessToken, provider string) error {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, session.UserID)
+	if err != nil || user == nil {
+		return errors.Unauthorized("User not found")
+	}
+
+	accounts, err := s.oauthAccountRepo.FindByUser(ctx, session.UserID)
+	if err != nil {
+		s.logger.Error("Failed to find oauth accounts", zap.Error(err))
+		return errors.Internal("Failed to unlink account")
+	}
+	if user.PasswordHash == "" && len(accounts) <= 1 {
+		return errors.BadRequest("Set a password before removing your only sign-in method")
+	}
+
+	deleted, err := s.oauthAccountRepo.Delete(ctx, session.UserID, domain.OAuthProvider(provider))
+	if err != nil {
+		s.logger.Error("Failed to delete oauth account", zap.Error(err))
+		return errors.Internal("Failed to unlink account")
+	}
+	if !deleted {
+		return errors.NotFound(fmt.Sprintf("No %s account is linked", provider))
+	}
+
+	s.logger.Info("Social account unlinked",
+		zap.String("user_id", session.UserID),
+		zap.String("provider", provider))
+
+	return nil
+}
+
+// startOAuth stores a new state with its nonce and PKCE verifier and builds the authorization URL
+func (s *MultiTenantAuthService) startOAuth(ctx context.Context, provider, tenantID, linkUserID string) (*domain.OAuthAuthorization, error) {
+	client := s.oauthProviders.Get(provider)
+	if client == nil {
+		return nil, errors.BadRequest(fmt.Sprintf("Provider %s is not configured", provider))
+	}
+	if s.store == nil {
+		return nil, errors.Internal("Session store not available")
+	}
+
+	state, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, errors.Internal("Failed to generate state")
+	}
+	nonce, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, errors.Internal("Failed to generate nonce")
+	}
+	verifier, challenge, err := oauth.GeneratePKCE()
+	if err != nil {
+		return nil, errors.Internal("Failed to generate code verifier")
+	}
+
+	now := time.Now()
+	oauthState := domain.OAuthState{
+		Provider:     domain.OAuthProvider(provider),
+		TenantID:     tenantID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oauthStateTTL),
+	}
+	if err := s.store.Set(ctx, oauthStateKey(state), oauthState, oauthStateTTL); err != nil {
+		s.logger.Error("Failed to store oauth state in Redis", zap.Error(err))
+		return nil, errors.Internal("Failed to start social login")
+	}
+
+	return &domain.OAuthAuthorization{
+		AuthorizationURL: client.AuthCodeURL(state, nonce, challenge),
+		State:            state,
+	}, nil
+}
+
+// completeOAuth consumes the state, exchanges the code and reads the provider profile
+func (s *MultiTenantAuthService) completeOAuth(ctx context.Context, provider, code, state string) (*domain.OAuthState, *oauth.UserInfo, error) {
+	client := s.oauthProviders.Get(provider)
+	if client == nil {
+		return nil, nil, errors.BadRequest(fmt.Sprintf("Provider %s is not configured", provider))
+	}
+	if s.store == nil {
+		return nil, nil, errors.Internal("Session store not available")
+	}
+
+	// The state is single-use, so concurrent callbacks with one state get it only once
+	var oauthState domain.OAuthState
+	if err := s.store.Take(ctx, oauthStateKey(state), &oauthState); err != nil {
+		return nil, nil, errors.BadRequest("Invalid or expired state")
+	}
+
+	if time.Now().After(oauthState.ExpiresAt) {
+		return nil, nil, errors.BadRequest("Invalid or expired state")
+	}
+	if string(oauthState.Provider) != provider {
+		return nil, nil, errors.BadRequest("State was issued for another provider")
+	}
+
+	token, err := client.Exchange(ctx, code, oauthState.CodeVerifier)
+	if err != nil {
+		s.logger.Warn("OAuth code exchange failed", zap.String("provider", provider), zap.Error(err))
+		return nil, nil, errors.Unauthorized("Failed to verify authorization code")
+	}
+	if err := client.VerifyIDToken(token.IDToken, oauthState.Nonce); err != nil {
+		s.logger.Warn("OAuth id token rejected", zap.String("provider", provider), zap.Error(err))
+		return nil, nil, errors.Unauthorized("Invalid ID token")
+	}
+
+	info, err := client.FetchUserInfo(ctx, token)
+	if err != nil {
+		s.logger.Warn("OAuth user info failed", zap.String("provider", provider), zap.Error(err))
+		return nil, nil, errors.Unauthorized("Failed to read provider profile")
+	}
+
+	return &oauthState, info, nil
+}
+
+// findOrCreateOAuthUser resolves the local user of a provider account and reports whether it was created
+func (s *MultiTenantAuthService) findOrCreateOAuthUser(ctx context.Context, provider domain.OAuthProvider, info *oauth.UserInfo, loginConfig *domain.TenantLoginConfig) (*domain.User, bool, error) {
+	// 1. Already linked
+	account, err := s.oauthAccountRepo.FindByProviderID(ctx, provider, info.Subject)
+	if err != nil {
+		s.logger.Error("Failed to find oauth account", zap.Error(err))
+		return nil, false, errors.Internal("Failed to complete social login")
+	}
+	if account != nil {
+		user, err := s.userRepo.FindByID(ctx, account.UserID)
+		if err != nil || user == nil {
+			return nil, false, errors.Unauthorized("User not found")
+		}
+		return user, false, nil
+	}
+
+	// 2. Link to the user with the same email, only when both the provider and the user verified it
+	if info.Email != "" && info.EmailVerified {
+		user, err := s.userRepo.FindByIdentifier(ctx, info.Email)
+		if err != nil {
+			return nil, false, errors.Internal("Failed to complete social login")
+		}
+		if user != nil && user.Email == info.Email {
+			// Whoever registered an unverified email may not own it, so the owner has to prove it
+			if !user.EmailVerified {
+				return nil, false, errors.Conflict("An account with this email exists, sign in and link the provider from your account")
+			}
+			if _, err := s.linkOAuthAccount(ctx, user.ID.Hex(), provider, info); err != nil {
+				return nil, false, err
+			}
+			return user, false, nil
+		}
+	}
+
+	// 3. Register a new user
+	if !loginConfig.AllowRegistration {
+		return nil, false, errors.Forbidden("No account is linked to this provider and registration is not allowed")
+	}
+
+	user := &domain.User{
+		IsActive:  true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	// An unverified email is not stored, it could belong to someone else
+	if info.Email != "" && info.EmailVerified {
+		user.Email = info.Email
+		user.EmailVerified = true
+		user.IsVerified = true
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		s.logger.Error("Failed to create user for social login", zap.Error(err))
+		return nil, false, errors.Internal("Failed to create user")
+	}
+	if _, err := s.linkOAuthAccount(ctx, user.ID.Hex(), provider, info); err != nil {
+		return nil, false, err
+	}
+
+	return user, true, nil
+}
+
+func (s *MultiTenantAuthService) linkOAuthAccount(ctx context.Context, userID string, provider domain.OAuthProvider, info *oauth.UserInfo) (*domain.OAuthAccount, error) {
+	account := &domain.OAuthAccount{
+		UserID:     userID,
+		Provider:   provider,
+		ProviderID: info.Subject,
+		Email:      info.Email,
+	}
+	if err := s.oauthAccountRepo.Create(ctx, account); err != nil {
+		s.logger.Error("Failed to link oauth account", zap.Error(err))
+		return nil, errors.Conflict("A provider account of this type is already linked")
+	}
+	return account, nil
+}
+
+func oauthStateKey(state string) string {
+	return fmt.Sprintf("oauth_state:%s", state)
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/oauth"
+	"github.com/vhvplatform/go-auth-service/internal/oidc"
+	"github.com/vhvplatform/go-shared/errors"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.uber.org/zap"
+)
+
+// oidcCodeTTL is how long an authorization code can be redeemed
+const oidcCodeTTL = time.Minute
+
+// defaultOIDCScopes are granted to clients registered without explicit scopes
+var defaultOIDCScopes = []string{oidc.ScopeOpenID, oidc.ScopeProfile, oidc.ScopeEmail}
+
+// RegisterOIDCClient registers an application of a tenant with the OpenID Connect provider.
+// The client secret is returned once; only its hash is stored. Public clients get no secret.
+func (s *MultiTenantAuthService) RegisterOIDCClient(ctx context.Context, tenantID string, req *domain.RegisterOIDCClientRequest) (*domain.OIDCClient, string, error) {
+	if err := validateRedirectURIs(req.RedirectURIs); err != nil {
+		return nil, "", err
+	}
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultOIDCScopes
+	}
+	for _, scope := range scopes {
+		if !oidc.HasScope(oidc.SupportedScopes, scope) {
+			return nil, "", errors.BadRequest(fmt.Sprintf("Unsupported scope %s", scope))
+		}
+	}
+	if !oidc.HasScope(scopes, oidc.ScopeOpenID) {
+		scopes = append([]string{oidc.ScopeOpenID}, scopes...)
+	}
+
+	clientID, err := utils.GenerateRandomString(24)
+	if err != nil {
+		return nil, "", errors.Internal("Failed to generate client ID")
+	}
+
+	client := &domain.OIDCClient{
+		TenantID:     tenantID,
+		ClientID:     clientID,
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       scopes,
+		Public:       req.Public,
+		IsActive:     true,
+	}
+
+	var secret string
+	if !req.Public {
+		secret, err = utils.GenerateRandomString(32)
+		if err != nil {
+			return nil, "", errors.Internal("Failed to generate client secret")
+		}
+		client.ClientSecretHash = s.tokenHasher.Hash(secret)
+	}
+
+	if err := s.oidcClientRepo.Create(ctx, client); err != nil {
+		s.logger.Error("Failed to register oidc client", zap.Error(err))
+		return nil, "", errors.Internal("Failed to register client")
+	}
+
+	s.logger.Info("OIDC client registered",
+		zap.String("tenant_id", tenantID),
+		zap.String("client_id", clientID),
+		zap.Bool("public", req.Public))
+
+	return client, secret, nil
+}
+
+// ListOIDCClients lists the applications registered by a tenant
+func (s *MultiTenantAuthService) ListOIDCClients(ctx context.Context, tenantID string) ([]*domain.OIDCClient, error) {
+	clients, err := s.oidcClientRepo.FindByTenant(ctx, tenantID)
+	if err != nil {
+		s.logger.Error("Failed to list oidc clients", zap.Error(err))
+		return nil, errors.Internal("Failed to list clients")
+	}
+	return clients, nil
+}
+
+// DeleteOIDCClient removes an application of a tenant. Tokens already issued to it stay valid until they expire.
+func (s *MultiTenantAuthService) DeleteOIDCClient(ctx context.Context, tenantID, clientID string) error {
+	deleted, err := s.oidcClientRepo.Delete(ctx, tenantID, clientID)
+	if err != nil {
+		s.logger.Error("Failed to delete oidc client", zap.Error(err))
+		return errors.Internal("Failed to delete client")
+	}
+	if !deleted {
+		return errors.NotFound("Client not found")
+	}
+
+	s.logger.Info("OIDC client deleted", zap.String("tenant_id", tenantID), zap.String("client_id", clientID))
+	return nil
+}
+
+// AuthorizeOIDC handles an authorization request for the user behind accessToken and returns the URL to redirect to.
+// Errors about the client or redirect URI, and a missing sign-in, are returned as *oidc.Error;
+// every other error is reported to the client through the redirect URL.
+func (s *MultiTenantAuthService) AuthorizeOIDC(ctx context.Context, accessToken string, req *domain.OIDCAuthorizeRequest) (string, error) {
+	if s.oidcProvider == nil {
+		return "", oidc.NewError(oidc.ErrServerError, "OpenID Connect is not configured")
+	}
+
+	client, err := s.oidcClientRepo.FindByClientID(ctx, req.ClientID)
+	if err != nil {
+		s.logger.Error("Failed to find oidc client", zap.Error(err))
+		return "", oidc.NewError(oidc.ErrServerError, "")
+	}
+	if client == nil {
+		return "", oidc.NewError(oidc.ErrInvalidClient, "Unknown client")
+	}
+	if !containsString(client.RedirectURIs, req.RedirectURI) {
+		return "", oidc.NewError(oidc.ErrInvalidRequest, "redirect_uri is not registered for this client")
+	}
+
+	// The redirect URI is trusted from here on, so errors go back to the client
+	fail := func(code, description string) (string, error) {
+		return oidc.RedirectError(req.RedirectURI, req.State, oidc.NewError(code, description)), nil
+	}
+
+	if req.ResponseType != "code" {
+		return fail(oidc.ErrUnsupportedResponseType, "Only the code response type is supported")
+	}
+	scopes := oidc.ParseScope(req.Scope)
+	if !oidc.HasScope(scopes, oidc.ScopeOpenID) {
+		return fail(oidc.ErrInvalidScope, "The openid scope is required")
+	}
+	for _, scope := range scopes {
+		if !oidc.HasScope(client.Scopes, scope) {
+			return fail(oidc.ErrInvalidScope, fmt.Sprintf("Scope %s is not allowed for this client", scope))
+		}
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return fail(oidc.ErrInvalidRequest, "A code_challenge with the S256 method is required")
+	}
+
+	// The user must be signed in to the client's tenant. Tokens issued to other clients do not count.
+	session := s.oidcUserSession(ctx, accessToken)
+	if session == nil || session.ClientID != "" || session.TenantID != client.TenantID {
+		if req.Prompt == "none" {
+			return fail(oidc.ErrLoginRequired, "")
+		}
+		return "", oidc.NewError(oidc.ErrLoginRequired, "User is not signed in to this tenant")
+	}
+
+	code, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return fail(oidc.ErrServerError, "")
+	}
+	authCode := domain.OIDCAuthorizationCode{
+		ClientID:      client.ClientID,
+		TenantID:      client.TenantID,
+		UserID:        session.UserID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      session.CreatedAt,
+		ExpiresAt:     time.Now().Add(oidcCodeTTL),
+	}
+	if err := s.store.Set(ctx, oidcCodeKey(code), authCode, oidcCodeTTL); err != nil {
+		s.logger.Error("Failed to store authorization code in Redis", zap.Error(err))
+		return fail(oidc.ErrServerError, "")
+	}
+
+	s.logger.Info("OIDC authorization code issued",
+		zap.String("user_id", session.UserID),
+		zap.String("client_id", client.ClientID))
+
+	return oidc.RedirectCode(req.RedirectURI, code, req.State), nil
+}
+
+// ExchangeOIDCCode redeems an authorization code for an access token and an ID token.
+// Errors are returned as *oidc.Error.
+func (s *MultiTenantAuthService) ExchangeOIDCCode(ctx context.Context, req *domain.OIDCTokenRequest) (*domain.OIDCTokenResponse, error) {
+	if s.oidcProvider == nil || s.store == nil {
+		return nil, oidc.NewError(oidc.ErrServerError, "OpenID Connect is not configured")
+	}
+	if req.GrantType != "authorization_code" {
+		return nil, oidc.NewError(oidc.ErrUnsupportedGrantType, "")
+	}
+	if req.Code == "" || req.RedirectURI == "" || req.CodeVerifier == "" {
+		return nil, oidc.NewError(oidc.ErrInvalidRequest, "code, redirect_uri and code_verifier are required")
+	}
+
+	client, err := s.authenticateOIDCClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	// Codes are single-use, so concurrent exchanges of one code get it only once
+	var authCode domain.OIDCAuthorizationCode
+	if err := s.store.Take(ctx, oidcCodeKey(req.Code), &authCode); err != nil {
+		return nil, oidc.NewError(oidc.ErrInvalidGrant, "Invalid or expired authorization code")
+	}
+
+	if time.Now().After(authCode.ExpiresAt) {
+		return nil, oidc.NewError(oidc.ErrInvalidGrant, "Invalid or expired authorization code")
+	}
+	if authCode.ClientID != client.ClientID {
+		return nil, oidc.NewError(oidc.ErrInvalidGrant, "Authorization code was issued to another client")
+	}
+	if authCode.RedirectURI != req.RedirectURI {
+		return nil, oidc.NewError(oidc.ErrInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if subtle.ConstantTimeCompare([]byte(oauth.CodeChallengeS256(req.CodeVerifier)), []byte(authCode.CodeChallenge)) != 1 {
+		return nil, oidc.NewError(oidc.ErrInvalidGrant, "PKCE verification failed")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, authCode.UserID)
+	if err != nil || user == nil || !user.IsActive {
+		return nil, oidc.NewError(oidc.ErrInvalidGrant, "User is not active")
+	}
+	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, authCode.UserID, authCode.TenantID)
+	if err != nil || userTenant == nil || !userTenant.IsActive {
+		return nil, oidc.NewError(oidc.ErrInvalidGrant, "User does not have access to this tenant")
+	}
+
+	// The client gets the scopes it asked for, not the user's roles
+	session := &domain.Session{
+		UserID:   authCode.UserID,
+		TenantID: authCode.TenantID,
+		Email:    user.Email,
+		Roles:    []string{},
+		ClientID: client.ClientID,
+		Scope:    authCode.Scope,
+	}
+	accessToken, err := s.createSession(ctx, session, s.sessionPolicy(ctx, authCode.TenantID))
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrServerError, "")
+	}
+
+	now := time.Now()
+	claims := oidcProfileClaims(user, oidc.ParseScope(authCode.Scope))
+	claims["iss"] = s.oidcProvider.Issuer
+	claims["sub"] = authCode.UserID
+	claims["aud"] = client.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(s.oidcProvider.IDTokenTTL).Unix()
+	claims["auth_time"] = authCode.AuthTime.Unix()
+	claims["tenant_id"] = authCode.TenantID
+	if authCode.Nonce != "" {
+		claims["nonce"] = authCode.Nonce
+	}
+
+	idToken, err := s.oidcProvider.Keys.Sign(claims)
+	if err != nil {
+		s.logger.Error("Failed to sign id token", zap.Error(err))
+		return nil, oidc.NewError(oidc.ErrServerError, "")
+	}
+
+	s.logger.Info("OIDC tokens issued",
+		zap.String("user_id", authCode.UserID),
+		zap.String("client_id", client.ClientID))
+
+	return &domain.OIDCTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(session.ExpiresAt.Sub(session.CreatedAt).Seconds()),
+		IDToken:     idToken,
+		Scope:       authCode.Scope,
+	}, nil
+}
+
+// OIDCUserInfo returns the claims of the user behind an access token issued by the token endpoint.
+// Errors are returned as *oidc.Error.
+func (s *MultiTenantAuthService) OIDCUserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	session := s.oidcUserSession(ctx, accessToken)
+	if session == nil {
+		return nil, oidc.NewError(oidc.ErrInvalidToken, "Invalid or expired access token")
+	}
+	scopes := oidc.ParseScope(session.Scope)
+	if session.ClientID == "" || !oidc.HasScope(scopes, oidc.ScopeOpenID) {
+		return nil, oidc.NewError(oidc.ErrInvalidToken, "Access token was not issued for OpenID Connect")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, session.UserID)
+	if err != nil || user == nil {
+		return nil, oidc.NewError(oidc.ErrInvalidToken, "User not found")
+	}
+
+	claims := oidcProfileClaims(user, scopes)
+	claims["sub"] = session.UserID
+	claims["tenant_id"] = session.TenantID
+	return claims, nil
+}
+
+// authenticateOIDCClient checks the client credentials. Public clients authenticate with PKCE alone.
+func (s *MultiTenantAuthService) authenticateOIDCClient(ctx context.Context, clientID, clientSecret string) (*domain.OIDCClient, error) {
+	if clientID == "" {
+		return nil, oidc.NewError(oidc.ErrInvalidClient, "Client authentication failed")
+	}
+
+	client, err := s.oidcClientRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		s.logger.Error("Failed to find oidc client", zap.Error(err))
+		return nil, oidc.NewError(oidc.ErrServerError, "")
+	}
+	if client == nil {
+		return nil, oidc.NewError(oidc.ErrInvalidClient, "Client authentication failed")
+	}
+	if client.Public {
+		return client, nil
+	}
+
+	if clientSecret == "" || subtle.ConstantTimeCompare([]byte(s.tokenHasher.Hash(clientSecret)), []byte(client.ClientSecretHash)) != 1 {
+		return nil, oidc.NewError(oidc.ErrInvalidClient, "Client authentication failed")
+	}
+	return client, nil
+}
+
+// oidcUserSession returns the valid session behind an access token, or nil
+func (s *MultiTenantAuthService) oidcUserSession(ctx context.Context, accessToken string) *domain.Session {
+	if accessToken == "" {
+		return nil
+	}
+	session, err := s.tokenSession(ctx, accessToken)
+	if err != nil {
+		return nil
+	}
+	return session
+}
+
+// oidcProfileClaims returns the standard claims the granted scopes allow
+func oidcProfileClaims(user *domain.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{}
+	if oidc.HasScope(scopes, oidc.ScopeProfile) && user.Username != "" {
+		claims["preferred_username"] = user.Username
+	}
+	if oidc.HasScope(scopes, oidc.ScopeEmail) && user.Email != "" {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	if oidc.HasScope(scopes, oidc.ScopePhone) && user.Phone != "" {
+		claims["phone_number"] = user.Phone
+		claims["phone_number_verified"] = user.PhoneVerified
+	}
+	return claims
+}
+
+// validateRedirectURIs accepts absolute URIs without fragments. Plain http is only allowed for
+// loopback addresses; custom schemes are allowed for native apps.
+func validateRedirectURIs(redirectURIs []string) error {
+	if len(redirectURIs) == 0 {
+		return errors.BadRequest("At least one redirect URI is required")
+	}
+	for _, raw := range redirectURIs {
+		uri, err := url.Parse(raw)
+		if err != nil || uri.Scheme == "" || uri.Fragment != "" {
+			return errors.BadRequest(fmt.Sprintf("Invalid redirect URI %s", raw))
+		}
+		switch uri.Scheme {
+		case "https":
+			if uri.Host == "" {
+				return errors.BadRequest(fmt.Sprintf("Invalid redirect URI %s", raw))
+			}
+		case "http":
+			if !isLoopbackHost(uri.Hostname()) {
+				return errors.BadRequest(fmt.Sprintf("Redirect URI %s must use https", raw))
+			}
+		}
+	}
+	return nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func oidcCodeKey(code string) string {
+	return fmt.Sprintf("oidc_code:%s", code)
+}
@@ -0,0 +1,252 @@
+package service_test
+
+import (
+	"context"
+	stderrors "errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/keys"
+	"github.com/vhvplatform/go-auth-service/internal/oauth"
+	"github.com/vhvplatform/go-auth-service/internal/oidc"
+	"github.com/vhvplatform/go-auth-service/internal/service"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+)
+
+const (
+	testRedirectURI  = "https://app.example.com/callback"
+	testClientSecret = "app-secret"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// oidcRepos returns mocks for password logins, like sessionRepos, with an OpenID Connect provider and
+// three clients: a confidential "app" and a public "spa" of the tenant, and a confidential "partner"
+// of another tenant
+func oidcRepos(t *testing.T) (testRepos, *domain.User, *[]*domain.RefreshToken) {
+	repos, user, issued := sessionRepos(t, testLoginConfig())
+
+	keySet := keys.NewKeySet(keys.NewMemoryStore(), keys.DefaultConfig())
+	require.NoError(t, keySet.Load(context.Background()))
+	repos.oidcProvider = oidc.NewProvider("https://auth.example.com", "https://auth.example.com/login", keySet)
+
+	secretHash := authutils.NewTokenHasher(testHashKey).Hash(testClientSecret)
+	clients := []*domain.OIDCClient{
+		{TenantID: testTenantID, ClientID: "app", ClientSecretHash: secretHash},
+		{TenantID: testTenantID, ClientID: "spa", Public: true},
+		{TenantID: "tenant456", ClientID: "partner", ClientSecretHash: secretHash},
+	}
+	repos.oidcClients = &MockOIDCClientRepository{}
+	for _, client := range clients {
+		client.RedirectURIs = []string{testRedirectURI}
+		client.Scopes = []string{oidc.ScopeOpenID, oidc.ScopeProfile, oidc.ScopeEmail}
+		client.IsActive = true
+		repos.oidcClients.On("FindByClientID", mock.Anything, client.ClientID).Return(client, nil)
+	}
+	repos.oidcClients.On("FindByClientID", mock.Anything, mock.Anything).Return(nil, nil)
+	return repos, user, issued
+}
+
+// authorizeRequest asks for a code for a client with the PKCE challenge of testCodeVerifier
+func authorizeRequest(clientID string) *domain.OIDCAuthorizeRequest {
+	return &domain.OIDCAuthorizeRequest{
+		ClientID:            clientID,
+		RedirectURI:         testRedirectURI,
+		ResponseType:        "code",
+		Scope:               "openid email",
+		State:               "xyz",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       oauth.CodeChallengeS256(testCodeVerifier),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+// tokenRequest redeems a code for the confidential "app" client
+func tokenRequest(code string) *domain.OIDCTokenRequest {
+	return &domain.OIDCTokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+		ClientID:     "app",
+		ClientSecret: testClientSecret,
+	}
+}
+
+// authorizeCode signs the user in and returns an authorization code for a client
+func authorizeCode(t *testing.T, authService *service.MultiTenantAuthService, clientID string) string {
+	ctx := context.Background()
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+
+	redirect, err := authService.AuthorizeOIDC(ctx, login.AccessToken, authorizeRequest(clientID))
+	require.NoError(t, err)
+	query := redirectQuery(t, redirect)
+	require.Empty(t, query.Get("error"))
+	assert.Equal(t, "xyz", query.Get("state"))
+	require.NotEmpty(t, query.Get("code"))
+	return query.Get("code")
+}
+
+func redirectQuery(t *testing.T, redirect string) url.Values {
+	uri, err := url.Parse(redirect)
+	require.NoError(t, err)
+	return uri.Query()
+}
+
+// assertOIDCError checks that err is a protocol error with the given code
+func assertOIDCError(t *testing.T, err error, code string) {
+	t.Helper()
+	var oidcErr *oidc.Error
+	if assert.True(t, stderrors.As(err, &oidcErr), "expected an *oidc.Error, got %v", err) {
+		assert.Equal(t, code, oidcErr.Code)
+	}
+}
+
+func TestMultiTenantAuthService_OIDCAuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	repos, user, _ := oidcRepos(t)
+	authService := newTestAuthService(repos)
+
+	code := authorizeCode(t, authService, "app")
+	tokens, err := authService.ExchangeOIDCCode(ctx, tokenRequest(code))
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, "openid email", tokens.Scope)
+
+	var claims map[string]interface{}
+	require.NoError(t, repos.oidcProvider.Keys.Verify(tokens.IDToken, &claims))
+	assert.Equal(t, "https://auth.example.com", claims["iss"])
+	assert.Equal(t, user.ID.Hex(), claims["sub"])
+	assert.Equal(t, "app", claims["aud"])
+	assert.Equal(t, "n-0S6_WzA2Mj", claims["nonce"])
+	assert.Equal(t, testEmail, claims["email"])
+	assert.Equal(t, testTenantID, claims["tenant_id"])
+
+	// The access token carries the client and its scopes, not the user's roles
+	resp, err := authService.VerifyToken(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Roles)
+	assert.Empty(t, resp.Permissions)
+	assert.Equal(t, "app", resp.Metadata["client_id"])
+
+	userInfo, err := authService.OIDCUserInfo(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.Hex(), userInfo["sub"])
+	assert.Equal(t, testEmail, userInfo["email"])
+
+	// Codes are single-use
+	_, err = authService.ExchangeOIDCCode(ctx, tokenRequest(code))
+	assertOIDCError(t, err, oidc.ErrInvalidGrant)
+}
+
+func TestMultiTenantAuthService_OIDCRequiresPKCE(t *testing.T) {
+	ctx := context.Background()
+	repos, _, _ := oidcRepos(t)
+	authService := newTestAuthService(repos)
+
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	for name, method := range map[string]string{"missing": "", "plain": "plain"} {
+		t.Run(name, func(t *testing.T) {
+			req := authorizeRequest("app")
+			req.CodeChallengeMethod = method
+			redirect, err := authService.AuthorizeOIDC(ctx, login.AccessToken, req)
+			require.NoError(t, err, "the error is sent to the registered redirect URI")
+			assert.Equal(t, oidc.ErrInvalidRequest, redirectQuery(t, redirect).Get("error"))
+			assert.Empty(t, redirectQuery(t, redirect).Get("code"))
+		})
+	}
+
+	// A wrong verifier fails, and uses up the code
+	code := authorizeCode(t, authService, "app")
+	req := tokenRequest(code)
+	req.CodeVerifier = "wrong-verifier-wrong-verifier-wrong-verifier"
+	_, err = authService.ExchangeOIDCCode(ctx, req)
+	assertOIDCError(t, err, oidc.ErrInvalidGrant)
+
+	_, err = authService.ExchangeOIDCCode(ctx, tokenRequest(code))
+	assertOIDCError(t, err, oidc.ErrInvalidGrant)
+}
+
+func TestMultiTenantAuthService_OIDCRedirectURI(t *testing.T) {
+	ctx := context.Background()
+	repos, _, _ := oidcRepos(t)
+	authService := newTestAuthService(repos)
+
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	req := authorizeRequest("app")
+	req.RedirectURI = "https://attacker.example.com/callback"
+	redirect, err := authService.AuthorizeOIDC(ctx, login.AccessToken, req)
+	assertOIDCError(t, err, oidc.ErrInvalidRequest)
+	assert.Empty(t, redirect, "an unregistered redirect URI is never redirected to")
+
+	code := authorizeCode(t, authService, "app")
+	exchange := tokenRequest(code)
+	exchange.RedirectURI = "https://app.example.com/other"
+	_, err = authService.ExchangeOIDCCode(ctx, exchange)
+	assertOIDCError(t, err, oidc.ErrInvalidGrant)
+}
+
+func TestMultiTenantAuthService_OIDCClientAuthentication(t *testing.T) {
+	ctx := context.Background()
+	repos, _, _ := oidcRepos(t)
+	authService := newTestAuthService(repos)
+
+	t.Run("wrong secret", func(t *testing.T) {
+		req := tokenRequest(authorizeCode(t, authService, "app"))
+		req.ClientSecret = "wrong-secret"
+		_, err := authService.ExchangeOIDCCode(ctx, req)
+		assertOIDCError(t, err, oidc.ErrInvalidClient)
+	})
+
+	t.Run("code of another client", func(t *testing.T) {
+		req := tokenRequest(authorizeCode(t, authService, "app"))
+		req.ClientID = "partner"
+		_, err := authService.ExchangeOIDCCode(ctx, req)
+		assertOIDCError(t, err, oidc.ErrInvalidGrant)
+	})
+
+	t.Run("client of another tenant", func(t *testing.T) {
+		login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+		require.NoError(t, err)
+		_, err = authService.AuthorizeOIDC(ctx, login.AccessToken, authorizeRequest("partner"))
+		assertOIDCError(t, err, oidc.ErrLoginRequired)
+	})
+
+	t.Run("public client", func(t *testing.T) {
+		req := tokenRequest(authorizeCode(t, authService, "spa"))
+		req.ClientID = "spa"
+		req.ClientSecret = ""
+		_, err := authService.ExchangeOIDCCode(ctx, req)
+		require.NoError(t, err, "public clients authenticate with PKCE alone")
+	})
+}
+
+func TestMultiTenantAuthService_OIDCClientTokenCannotManageAccount(t *testing.T) {
+	ctx := context.Background()
+	repos, _, _ := oidcRepos(t)
+	authService := newTestAuthService(repos)
+
+	tokens, err := authService.ExchangeOIDCCode(ctx, tokenRequest(authorizeCode(t, authService, "app")))
+	require.NoError(t, err)
+
+	_, err = authService.ListSessions(ctx, tokens.AccessToken)
+	assert.Error(t, err)
+	_, _, err = authService.CreateAPIKey(ctx, tokens.AccessToken, &domain.CreateAPIKeyRequest{
+		Name:        "ci",
+		Permissions: []string{"user.read"},
+	})
+	assert.Error(t, err)
+	err = authService.ChangePassword(ctx, tokens.AccessToken, testPassword, "Battery-Staple-2", false)
+	assert.Error(t, err)
+	repos.users.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+
+	// Nor does it sign the user in to other clients
+	_, err = authService.AuthorizeOIDC(ctx, tokens.AccessToken, authorizeRequest("spa"))
+	assertOIDCError(t, err, oidc.ErrLoginRequired)
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/notification"
+	"github.com/vhvplatform/go-shared/errors"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.uber.org/zap"
+)
+
+// passwordResetTTL is how long a password reset token stays valid
+const passwordResetTTL = time.Hour
+
+// RequestPasswordReset sends a single-use reset token to the user's email.
+// It returns nil whether or not the account exists, so callers cannot probe for accounts;
+// failures after the account lookup are only logged.
+func (s *MultiTenantAuthService) RequestPasswordReset(ctx context.Context, email, tenantID, ipAddress string) error {
+	user, err := s.userRepo.FindByIdentifier(ctx, email)
+	if err != nil {
+		s.logger.Error("Failed to find user for password reset", zap.Error(err))
+		return nil
+	}
+	if user == nil || user.Email != email || !user.IsActive {
+		s.logger.Info("Password reset requested for unknown account",
+			zap.String("tenant_id", tenantID),
+			zap.String("ip_address", ipAddress))
+		return nil
+	}
+
+	userID := user.ID.Hex()
+	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, userID, tenantID)
+	if err != nil || userTenant == nil || !userTenant.IsActive {
+		s.logger.Info("Password reset requested outside the user's tenants",
+			zap.String("user_id", userID),
+			zap.String("tenant_id", tenantID))
+		return nil
+	}
+
+	token, err := utils.GenerateRandomString(32)
+	if err != nil {
+		s.logger.Error("Failed to generate password reset token", zap.Error(err))
+		return nil
+	}
+
+	// Only the newest token is usable
+	if err := s.passwordResetRepo.InvalidateForUser(ctx, userID); err != nil {
+		s.logger.Error("Failed to invalidate previous reset tokens", zap.Error(err))
+		return nil
+	}
+
+	resetToken := &domain.PasswordResetToken{
+		UserID:    userID,
+		TenantID:  tenantID,
+		TokenHash: s.tokenHasher.Hash(token),
+		IPAddress: ipAddress,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+	if err := s.passwordResetRepo.Create(ctx, resetToken); err != nil {
+		s.logger.Error("Failed to store password reset token", zap.Error(err))
+		return nil
+	}
+
+	err = s.notifier.Send(ctx, &notification.Message{
+		Channel:  notification.ChannelEmail,
+		To:       user.Email,
+		Template: notification.TemplatePasswordReset,
+		Data: map[string]string{
+			"token":      token,
+			"tenant_id":  tenantID,
+			"expires_at": resetToken.ExpiresAt.Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		s.logger.Error("Failed to send password reset email", zap.String("user_id", userID), zap.Error(err))
+		return nil
+	}
+
+	s.logger.Info("Password reset requested",
+		zap.String("user_id", userID),
+		zap.String("tenant_id", tenantID),
+		zap.String("ip_address", ipAddress))
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and revokes every session of the user.
+// The token stays usable if the new password is rejected, and is consumed once the password is accepted.
+func (s *MultiTenantAuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	tokenHash := s.tokenHasher.Hash(token)
+	resetToken, err := s.passwordResetRepo.FindValid(ctx, tokenHash)
+	if err != nil {
+		s.logger.Error("Failed to find password reset token", zap.Error(err))
+		return errors.Internal("Failed to reset password")
+	}
+	if resetToken == nil {
+		return errors.BadRequest("Invalid or expired reset token")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, resetToken.UserID)
+	if err != nil || user == nil {
+		return errors.BadRequest("Invalid or expired reset token")
+	}
+
+	// Validate against the policy of the tenant the reset was requested for
+	loginConfig, err := s.tenantLoginConfigRepo.FindByTenant(ctx, resetToken.TenantID)
+	if err != nil {
+		return err
+	}
+	if err := s.validatePassword(newPassword, loginConfig); err != nil {
+		return err
+	}
+	if utils.CheckPassword(newPassword, user.PasswordHash) {
+		return errors.BadRequest("New password must be different from the current password")
+	}
+
+	// Consume atomically so that concurrent requests cannot use the same token twice
+	consumed, err := s.passwordResetRepo.Consume(ctx, tokenHash)
+	if err != nil {
+		s.logger.Error("Failed to consume password reset token", zap.Error(err))
+		return errors.Internal("Failed to reset password")
+	}
+	if consumed == nil {
+		return errors.BadRequest("Invalid or expired reset token")
+	}
+
+	passwordHash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return errors.Internal("Failed to hash password")
+	}
+	if err := s.userRepo.UpdatePassword(ctx, resetToken.UserID, passwordHash); err != nil {
+		s.logger.Error("Failed to update password", zap.Error(err))
+		return errors.Internal("Failed to reset password")
+	}
+
+	// Sign out everywhere, the old password may have been compromised
+	if err := s.revokeAllUserSessions(ctx, resetToken.UserID); err != nil {
+		s.logger.Error("Failed to revoke sessions after password reset",
+			zap.String("user_id", resetToken.UserID),
+			zap.Error(err))
+		return errors.Internal("Password was reset but existing sessions could not be revoked")
+	}
+	if err := s.passwordResetRepo.InvalidateForUser(ctx, resetToken.UserID); err != nil {
+		s.logger.Warn("Failed to invalidate remaining reset tokens", zap.Error(err))
+	}
+
+	s.notifyPasswordChanged(ctx, user)
+
+	s.logger.Info("Password reset completed",
+		zap.String("user_id", resetToken.UserID),
+		zap.String("tenant_id", resetToken.TenantID))
+
+	return nil
+}
+
+// ChangePassword changes the password of the user behind an access token.
+// When revokeOtherSessions is set, every other session of the user is ended and only the current one is kept.
+func (s *MultiTenantAuthService) ChangePassword(ctx context.Context, accessToken, oldPassword, newPassword string, revokeOtherSessions bool) error {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, session.UserID)
+	if err != nil || user == nil {
+		return errors.Unauthorized("User not found")
+	}
+
+	if !utils.CheckPassword(oldPassword, user.PasswordHash) {
+		s.logger.Warn("Change password with incorrect current password",
+			zap.String("user_id", session.UserID),
+			zap.String("tenant_id", session.TenantID))
+		return errors.Unauthorized("Current password is incorrect")
+	}
+
+	loginConfig, err := s.tenantLoginConfigRepo.FindByTenant(ctx, session.TenantID)
+	if err != nil {
+		return err
+	}
+	if err := s.validatePassword(newPassword, loginConfig); err != nil {
+		return err
+	}
+	if oldPassword == newPassword {
+		return errors.BadRequest("New password must be different from the current password")
+	}
+
+	passwordHash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return errors.Internal("Failed to hash password")
+	}
+	if err := s.userRepo.UpdatePassword(ctx, session.UserID, passwordHash); err != nil {
+		s.logger.Error("Failed to update password", zap.Error(err))
+		return errors.Internal("Failed to change password")
+	}
+
+	// Reset links sent before the change must not be usable afterwards
+	if err := s.passwordResetRepo.InvalidateForUser(ctx, session.UserID); err != nil {
+		s.logger.Warn("Failed to invalidate reset tokens", zap.Error(err))
+	}
+
+	if revokeOtherSessions {
+		if err := s.revokeOtherUserSessions(ctx, session.UserID, accessToken); err != nil {
+			s.logger.Error("Failed to revoke other sessions after password change",
+				zap.String("user_id", session.UserID),
+				zap.Error(err))
+			return errors.Internal("Password was changed but other sessions could not be revoked")
+		}
+	}
+
+	s.notifyPasswordChanged(ctx, user)
+
+	s.logger.Info("Password changed",
+		zap.String("user_id", session.UserID),
+		zap.String("tenant_id", session.TenantID),
+		zap.Bool("revoke_other_sessions", revokeOtherSessions))
+
+	return nil
+}
+
+// notifyPasswordChanged tells the user their password was changed
+func (s *MultiTenantAuthService) notifyPasswordChanged(ctx context.Context, user *domain.User) {
+	if user.Email == "" {
+		return
+	}
+
+	err := s.notifier.Send(ctx, &notification.Message{
+		Channel:  notification.ChannelEmail,
+		To:       user.Email,
+		Template: notification.TemplatePasswordChanged,
+		Data: map[string]string{
+			"changed_at": time.Now().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		s.logger.Warn("Failed to send password changed notification", zap.Error(err))
+	}
+}
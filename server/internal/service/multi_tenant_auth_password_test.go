@@ -0,0 +1,110 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+)
+
+const newTestPassword = "Battery-Staple-2"
+
+func TestMultiTenantAuthService_ChangePassword_RevokesOtherSessions(t *testing.T) {
+	ctx := context.Background()
+	repos, user, issued := sessionRepos(t, testLoginConfig())
+	authService := newTestAuthService(repos)
+
+	current, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	other, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	require.Len(t, *issued, 2)
+
+	repos.users.On("UpdatePassword", mock.Anything, user.ID.Hex(), mock.Anything).Return(nil).Once()
+	repos.passwordResets.On("InvalidateForUser", mock.Anything, user.ID.Hex()).Return(nil).Once()
+	repos.refreshTokens.On("RevokeAllForUserExceptFamily", mock.Anything, user.ID.Hex(), (*issued)[0].FamilyID).Return(nil).Once()
+
+	err = authService.ChangePassword(ctx, current.AccessToken, testPassword, newTestPassword, true)
+	require.NoError(t, err)
+
+	_, err = authService.VerifyToken(ctx, current.AccessToken)
+	assert.NoError(t, err)
+	_, err = authService.VerifyToken(ctx, other.AccessToken)
+	assert.Error(t, err)
+
+	sessions, err := authService.ListSessions(ctx, current.AccessToken)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Current)
+	repos.users.AssertExpectations(t)
+	repos.passwordResets.AssertExpectations(t)
+	repos.refreshTokens.AssertExpectations(t)
+}
+
+func TestMultiTenantAuthService_ChangePassword_KeepsSessions(t *testing.T) {
+	ctx := context.Background()
+	repos, user, _ := sessionRepos(t, testLoginConfig())
+	authService := newTestAuthService(repos)
+
+	current, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	other, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+
+	repos.users.On("UpdatePassword", mock.Anything, user.ID.Hex(), mock.Anything).Return(nil).Once()
+	repos.passwordResets.On("InvalidateForUser", mock.Anything, user.ID.Hex()).Return(nil).Once()
+
+	err = authService.ChangePassword(ctx, current.AccessToken, testPassword, newTestPassword, false)
+	require.NoError(t, err)
+
+	_, err = authService.VerifyToken(ctx, other.AccessToken)
+	assert.NoError(t, err)
+	repos.refreshTokens.AssertNotCalled(t, "RevokeAllForUserExceptFamily", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMultiTenantAuthService_ChangePassword_WrongPassword(t *testing.T) {
+	ctx := context.Background()
+	repos, _, _ := sessionRepos(t, testLoginConfig())
+	authService := newTestAuthService(repos)
+
+	current, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+
+	err = authService.ChangePassword(ctx, current.AccessToken, "wrong", newTestPassword, true)
+	require.Error(t, err)
+
+	_, err = authService.VerifyToken(ctx, current.AccessToken)
+	assert.NoError(t, err)
+	repos.users.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMultiTenantAuthService_ResetPassword_RevokesAllSessions(t *testing.T) {
+	ctx := context.Background()
+	repos, user, _ := sessionRepos(t, testLoginConfig())
+	authService := newTestAuthService(repos)
+
+	first, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	second, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+
+	resetToken := &domain.PasswordResetToken{UserID: user.ID.Hex(), TenantID: testTenantID}
+	tokenHash := authutils.NewTokenHasher(testHashKey).Hash("reset-token")
+	repos.passwordResets.On("FindValid", mock.Anything, tokenHash).Return(resetToken, nil).Once()
+	repos.passwordResets.On("Consume", mock.Anything, tokenHash).Return(resetToken, nil).Once()
+	repos.passwordResets.On("InvalidateForUser", mock.Anything, user.ID.Hex()).Return(nil).Once()
+	repos.users.On("UpdatePassword", mock.Anything, user.ID.Hex(), mock.Anything).Return(nil).Once()
+	repos.refreshTokens.On("RevokeAllForUser", mock.Anything, user.ID.Hex()).Return(nil).Once()
+
+	require.NoError(t, authService.ResetPassword(ctx, "reset-token", newTestPassword))
+
+	_, err = authService.VerifyToken(ctx, first.AccessToken)
+	assert.Error(t, err)
+	_, err = authService.VerifyToken(ctx, second.AccessToken)
+	assert.Error(t, err)
+	repos.refreshTokens.AssertExpectations(t)
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/notification"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+	"github.com/vhvplatform/go-shared/errors"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	passwordlessTTL             = 10 * time.Minute
+	passwordlessCodeDigits      = 6
+	maxPasswordlessAttempts     = 5
+	maxPasswordlessPerHour      = 5
+	passwordlessRateLimitWindow = time.Hour
+)
+
+// StartPasswordlessLogin sends a one-time code and login link to an email address or phone number.
+// Like SendVerification it returns nil whether or not the identifier belongs to an account of the
+// tenant, and sends nothing once the identifier has had too many codes in the last hour.
+func (s *MultiTenantAuthService) StartPasswordlessLogin(ctx context.Context, identifier, tenantID, ipAddress string) error {
+	loginConfig, err := s.tenantLoginConfigRepo.FindByTenant(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if !loginConfig.PasswordlessLogin {
+		return errors.Forbidden("Passwordless login is not enabled for this tenant")
+	}
+	if err := s.checkLockout(ctx, tenantID, identifier, ipAddress); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByIdentifier(ctx, identifier)
+	if err != nil {
+		s.logger.Error("Failed to find user for passwordless login", zap.Error(err))
+		return nil
+	}
+	if user == nil || !user.IsActive {
+		return nil
+	}
+
+	// Codes can only be sent to an email or phone the tenant accepts as a login identifier
+	identifierType := domain.DetectIdentifierType(identifier, user)
+	if identifierValue(user, identifierType) == "" || !identifierAllowed(loginConfig, identifierType) {
+		return nil
+	}
+
+	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, user.ID.Hex(), tenantID)
+	if err != nil || userTenant == nil || !userTenant.IsActive {
+		return nil
+	}
+
+	if err := s.sendPasswordlessLogin(ctx, user, tenantID, identifierType, identifier); err != nil {
+		s.logger.Warn("Failed to send passwordless login",
+			zap.String("user_id", user.ID.Hex()),
+			zap.String("identifier_type", string(identifierType)),
+			zap.Error(err))
+	}
+	return nil
+}
+
+// CompletePasswordlessLogin signs in with either the code sent to an identifier or the token from
+// the login link. The code or link only replaces the password: TOTP is still asked for when the
+// user enrolled it or the tenant requires it. Receiving the code also verifies the identifier.
+func (s *MultiTenantAuthService) CompletePasswordlessLogin(ctx context.Context, identifier, code, linkToken, tenantID, ipAddress string) (*domain.LoginResponse, error) {
+	loginConfig, err := s.tenantLoginConfigRepo.FindByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !loginConfig.PasswordlessLogin {
+		return nil, errors.Forbidden("Passwordless login is not enabled for this tenant")
+	}
+
+	var login *domain.PasswordlessLogin
+	switch {
+	case linkToken != "":
+		login, err = s.passwordlessLoginRepo.FindPendingByLinkToken(ctx, s.tokenHasher.Hash(linkToken))
+		if err != nil {
+			s.logger.Error("Failed to find passwordless login", zap.Error(err))
+			return nil, errors.Internal("Failed to complete login")
+		}
+		if login == nil || login.TenantID != tenantID {
+			return nil, errors.Unauthorized("Invalid or expired login link")
+		}
+		if err := s.checkLockout(ctx, tenantID, login.Identifier, ipAddress); err != nil {
+			return nil, err
+		}
+
+	case identifier != "" && code != "":
+		if err := s.checkLockout(ctx, tenantID, identifier, ipAddress); err != nil {
+			return nil, err
+		}
+		login, err = s.passwordlessLoginRepo.FindPending(ctx, identifier, tenantID)
+		if err != nil {
+			s.logger.Error("Failed to find passwordless login", zap.Error(err))
+			return nil, errors.Internal("Failed to complete login")
+		}
+		if login == nil {
+			s.recordLoginAttempt(ctx, loginConfig, tenantID, identifier, ipAddress, "", false)
+			return nil, errors.Unauthorized("Invalid or expired login code")
+		}
+		claimed, err := s.passwordlessLoginRepo.ClaimAttempt(ctx, login.ID, maxPasswordlessAttempts)
+		if err != nil {
+			s.logger.Error("Failed to count passwordless login attempt", zap.Error(err))
+			return nil, errors.Internal("Failed to complete login")
+		}
+		if !claimed || s.tokenHasher.Hash(code) != login.CodeHash {
+			s.recordLoginAttempt(ctx, loginConfig, tenantID, identifier, ipAddress, login.UserID, false)
+			return nil, errors.Unauthorized("Invalid or expired login code")
+		}
+
+	default:
+		return nil, errors.BadRequest("Login code or link token is required")
+	}
+
+	used, err := s.passwordlessLoginRepo.MarkUsed(ctx, login.ID)
+	if err != nil {
+		s.logger.Error("Failed to mark passwordless login used", zap.Error(err))
+		return nil, errors.Internal("Failed to complete login")
+	}
+	if !used {
+		return nil, errors.Unauthorized("Login code was already used")
+	}
+
+	// The account may have changed since the code was sent
+	user, err := s.userRepo.FindByID(ctx, login.UserID)
+	if err != nil || user == nil || identifierValue(user, login.IdentifierType) != login.Identifier {
+		return nil, errors.Unauthorized("Invalid or expired login code")
+	}
+	if !user.IsActive {
+		return nil, errors.Forbidden("User account is deactivated")
+	}
+	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, login.UserID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if userTenant == nil {
+		return nil, errors.Forbidden("User does not have access to this tenant")
+	}
+	if !userTenant.IsActive {
+		return nil, errors.Forbidden("User access to this tenant is deactivated")
+	}
+
+	if !identifierVerified(user, login.IdentifierType) {
+		if _, err := s.userRepo.MarkIdentifierVerified(ctx, login.UserID, login.IdentifierType, login.Identifier); err != nil {
+			s.logger.Warn("Failed to mark identifier verified", zap.Error(err))
+		}
+	}
+
+	response, err := s.startSession(ctx, user, userTenant, loginConfig, login.Identifier, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("User logged in successfully",
+		zap.String("user_id", login.UserID),
+		zap.String("tenant_id", tenantID),
+		zap.String("identifier_type", string(login.IdentifierType)),
+		zap.Bool("passwordless", true),
+		zap.Bool("mfa_required", response.MFARequired))
+
+	return response, nil
+}
+
+// sendPasswordlessLogin creates a passwordless login for an email or phone and sends its code and link token.
+// Earlier codes for the identifier in the tenant stop working.
+func (s *MultiTenantAuthService) sendPasswordlessLogin(ctx context.Context, user *domain.User, tenantID string, identifierType domain.IdentifierType, identifier string) error {
+	sent, err := s.passwordlessLoginRepo.CountSince(ctx, identifier, time.Now().Add(-passwordlessRateLimitWindow))
+	if err != nil {
+		return err
+	}
+	if sent >= maxPasswordlessPerHour {
+		return errors.Forbidden("Too many login codes requested, try again later")
+	}
+
+	code, err := authutils.GenerateNumericCode(passwordlessCodeDigits)
+	if err != nil {
+		return err
+	}
+	linkToken, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+
+	if err := s.passwordlessLoginRepo.InvalidateForIdentifier(ctx, identifier, tenantID); err != nil {
+		return err
+	}
+
+	login := &domain.PasswordlessLogin{
+		UserID:         user.ID.Hex(),
+		TenantID:       tenantID,
+		IdentifierType: identifierType,
+		Identifier:     identifier,
+		CodeHash:       s.tokenHasher.Hash(code),
+		LinkTokenHash:  s.tokenHasher.Hash(linkToken),
+		ExpiresAt:      time.Now().Add(passwordlessTTL),
+	}
+	if err := s.passwordlessLoginRepo.Create(ctx, login); err != nil {
+		return err
+	}
+
+	channel := notification.ChannelEmail
+	if identifierType == domain.IdentifierTypePhone {
+		channel = notification.ChannelSMS
+	}
+
+	return s.notifier.Send(ctx, &notification.Message{
+		Channel:  channel,
+		To:       identifier,
+		Template: notification.TemplatePasswordlessLogin,
+		Data: map[string]string{
+			"code":       code,
+			"token":      linkToken,
+			"tenant_id":  tenantID,
+			"expires_at": login.ExpiresAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// identifierAllowed reports whether a tenant accepts an identifier type for login
+func identifierAllowed(config *domain.TenantLoginConfig, identifierType domain.IdentifierType) bool {
+	for _, allowed := range config.AllowedIdentifiers {
+		if allowed == string(identifierType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	testLoginCode = "123456"
+	testLinkToken = "link-token"
+)
+
+// passwordlessRepos returns mocks for passwordless logins of an active user of a tenant that
+// enabled them, and a pending login of the user with testLoginCode and testLinkToken
+func passwordlessRepos(t *testing.T) (testRepos, *domain.User, *domain.PasswordlessLogin) {
+	config := testLoginConfig()
+	config.PasswordlessLogin = true
+	repos, user, _ := sessionRepos(t, config)
+	repos.passwordless = &MockPasswordlessLoginRepository{}
+
+	hasher := authutils.NewTokenHasher(testHashKey)
+	login := &domain.PasswordlessLogin{
+		ID:             primitive.NewObjectID(),
+		UserID:         user.ID.Hex(),
+		TenantID:       testTenantID,
+		IdentifierType: domain.IdentifierTypeEmail,
+		Identifier:     testEmail,
+		CodeHash:       hasher.Hash(testLoginCode),
+		LinkTokenHash:  hasher.Hash(testLinkToken),
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+	}
+	return repos, user, login
+}
+
+func TestMultiTenantAuthService_StartPasswordlessLogin_RateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Sends a code below the limit", func(t *testing.T) {
+		repos, user, _ := passwordlessRepos(t)
+		repos.passwordless.On("CountSince", mock.Anything, testEmail, mock.Anything).Return(int64(4), nil)
+		repos.passwordless.On("InvalidateForIdentifier", mock.Anything, testEmail, testTenantID).Return(nil).Once()
+		repos.passwordless.On("Create", mock.Anything, mock.MatchedBy(func(login *domain.PasswordlessLogin) bool {
+			return login.UserID == user.ID.Hex() && login.TenantID == testTenantID && login.Identifier == testEmail
+		})).Return(nil).Once()
+		authService := newTestAuthService(repos)
+
+		err := authService.StartPasswordlessLogin(ctx, testEmail, testTenantID, testIP)
+
+		require.NoError(t, err)
+		repos.passwordless.AssertExpectations(t)
+	})
+
+	t.Run("Sends nothing at the limit", func(t *testing.T) {
+		repos, _, _ := passwordlessRepos(t)
+		repos.passwordless.On("CountSince", mock.Anything, testEmail, mock.Anything).Return(int64(5), nil)
+		authService := newTestAuthService(repos)
+
+		// The caller cannot tell that nothing was sent
+		err := authService.StartPasswordlessLogin(ctx, testEmail, testTenantID, testIP)
+
+		require.NoError(t, err)
+		repos.passwordless.AssertNotCalled(t, "InvalidateForIdentifier", mock.Anything, mock.Anything, mock.Anything)
+		repos.passwordless.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestMultiTenantAuthService_CompletePasswordlessLogin_LinkToken(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Valid link signs in", func(t *testing.T) {
+		repos, user, login := passwordlessRepos(t)
+		repos.passwordless.On("FindPendingByLinkToken", mock.Anything, login.LinkTokenHash).Return(login, nil)
+		repos.passwordless.On("MarkUsed", mock.Anything, login.ID).Return(true, nil).Once()
+		authService := newTestAuthService(repos)
+
+		resp, err := authService.CompletePasswordlessLogin(ctx, "", "", testLinkToken, testTenantID, testIP)
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+		assert.Equal(t, user.ID.Hex(), resp.User.ID)
+		repos.passwordless.AssertExpectations(t)
+	})
+
+	t.Run("Used link is rejected", func(t *testing.T) {
+		repos, _, login := passwordlessRepos(t)
+		repos.passwordless.On("FindPendingByLinkToken", mock.Anything, login.LinkTokenHash).Return(login, nil)
+		repos.passwordless.On("MarkUsed", mock.Anything, login.ID).Return(false, nil)
+		authService := newTestAuthService(repos)
+
+		_, err := authService.CompletePasswordlessLogin(ctx, "", "", testLinkToken, testTenantID, testIP)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already used")
+		repos.refreshTokens.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Link of another tenant is rejected", func(t *testing.T) {
+		repos, _, login := passwordlessRepos(t)
+		login.TenantID = "other-tenant"
+		repos.passwordless.On("FindPendingByLinkToken", mock.Anything, login.LinkTokenHash).Return(login, nil)
+		authService := newTestAuthService(repos)
+
+		_, err := authService.CompletePasswordlessLogin(ctx, "", "", testLinkToken, testTenantID, testIP)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid or expired login link")
+		repos.passwordless.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything)
+	})
+}
+
+func TestMultiTenantAuthService_CompletePasswordlessLogin_Code(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Valid code signs in", func(t *testing.T) {
+		repos, _, login := passwordlessRepos(t)
+		repos.passwordless.On("FindPending", mock.Anything, testEmail, testTenantID).Return(login, nil)
+		repos.passwordless.On("ClaimAttempt", mock.Anything, login.ID, 5).Return(true, nil).Once()
+		repos.passwordless.On("MarkUsed", mock.Anything, login.ID).Return(true, nil).Once()
+		authService := newTestAuthService(repos)
+
+		resp, err := authService.CompletePasswordlessLogin(ctx, testEmail, testLoginCode, "", testTenantID, testIP)
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+		repos.passwordless.AssertExpectations(t)
+	})
+
+	t.Run("Correct code is rejected once the attempts are used up", func(t *testing.T) {
+		repos, _, login := passwordlessRepos(t)
+		repos.passwordless.On("FindPending", mock.Anything, testEmail, testTenantID).Return(login, nil)
+		repos.passwordless.On("ClaimAttempt", mock.Anything, login.ID, 5).Return(false, nil)
+		repos.lockouts.On("FindLatest", mock.Anything, testTenantID, mock.Anything, mock.Anything).Return(nil, nil)
+		repos.attempts.On("CountFailuresByIdentifier", mock.Anything, testTenantID, testEmail, mock.Anything).Return(int64(1), nil)
+		repos.attempts.On("CountFailuresByIP", mock.Anything, testTenantID, testIP, mock.Anything).Return(int64(1), nil)
+		authService := newTestAuthService(repos)
+
+		_, err := authService.CompletePasswordlessLogin(ctx, testEmail, testLoginCode, "", testTenantID, testIP)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid or expired login code")
+		repos.passwordless.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything)
+		repos.attempts.AssertCalled(t, "Create", mock.Anything, mock.MatchedBy(func(attempt *domain.LoginAttempt) bool {
+			return !attempt.Success && attempt.Identifier == testEmail
+		}))
+	})
+
+	t.Run("Locked out identifier is rejected before the code is checked", func(t *testing.T) {
+		repos, _, _ := passwordlessRepos(t)
+		repos.lockouts.ExpectedCalls = nil
+		repos.lockouts.On("FindActive", mock.Anything, testTenantID, testEmail, testIP).
+			Return(&domain.UserLockout{Identifier: testEmail, UnlockAt: time.Now().Add(10 * time.Minute)}, nil)
+		authService := newTestAuthService(repos)
+
+		_, err := authService.CompletePasswordlessLogin(ctx, testEmail, testLoginCode, "", testTenantID, testIP)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Too many failed login attempts")
+		repos.passwordless.AssertNotCalled(t, "FindPending", mock.Anything, mock.Anything, mock.Anything)
+		repos.passwordless.AssertNotCalled(t, "ClaimAttempt", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -2,59 +2,107 @@ package service
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"time"
 
 	"github.com/vhvplatform/go-auth-service/internal/domain"
-	"github.com/vhvplatform/go-auth-service/internal/repository"
+	"github.com/vhvplatform/go-auth-service/internal/events"
+	"github.com/vhvplatform/go-auth-service/internal/notification"
+	"github.com/vhvplatform/go-auth-service/internal/oauth"
+	"github.com/vhvplatform/go-auth-service/internal/oidc"
+	"github.com/vhvplatform/go-auth-service/internal/store"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
 	"github.com/vhvplatform/go-shared/errors"
 	"github.com/vhvplatform/go-shared/jwt"
 	"github.com/vhvplatform/go-shared/logger"
-	"github.com/vhvplatform/go-shared/redis"
 	"github.com/vhvplatform/go-shared/utils"
 	"go.uber.org/zap"
 )
 
 // MultiTenantAuthService handles multi-tenant authentication business logic
 type MultiTenantAuthService struct {
-	userRepo              *repository.UserRepository
-	userTenantRepo        *repository.UserTenantRepository
-	tenantLoginConfigRepo *repository.TenantLoginConfigRepository
-	refreshTokenRepo      *repository.RefreshTokenRepository
-	roleRepo              *repository.RoleRepository
-	jwtManager            *jwt.Manager
-	redisCache            *redis.Cache
-	logger                *logger.Logger
+	userRepo               UserRepository
+	userTenantRepo         UserTenantRepository
+	tenantLoginConfigRepo  TenantLoginConfigRepository
+	refreshTokenRepo       RefreshTokenRepository
+	roleRepo               RoleRepository
+	loginAttemptRepo       LoginAttemptRepository
+	userLockoutRepo        UserLockoutRepository
+	userMFARepo            UserMFARepository
+	passwordResetRepo      PasswordResetTokenRepository
+	verificationRepo       IdentifierVerificationRepository
+	oauthAccountRepo       OAuthAccountRepository
+	oidcClientRepo         OIDCClientRepository
+	serviceAccountRepo     ServiceAccountRepository
+	apiKeyRepo             APIKeyRepository
+	webauthnCredentialRepo WebAuthnCredentialRepository
+	passwordlessLoginRepo  PasswordlessLoginRepository
+	notifier               notification.Notifier
+	publisher              events.Publisher
+	permissionService      *PermissionService
+	oauthProviders         *oauth.Providers
+	oidcProvider           *oidc.Provider
+	jwtManager             *jwt.Manager
+	tokenHasher            *authutils.TokenHasher
+	store                  store.Store
+	logger                 *logger.Logger
 }
 
 // NewMultiTenantAuthService creates a new multi-tenant auth service
 func NewMultiTenantAuthService(
-	userRepo *repository.UserRepository,
-	userTenantRepo *repository.UserTenantRepository,
-	tenantLoginConfigRepo *repository.TenantLoginConfigRepository,
-	refreshTokenRepo *repository.RefreshTokenRepository,
-	roleRepo *repository.RoleRepository,
+	userRepo UserRepository,
+	userTenantRepo UserTenantRepository,
+	tenantLoginConfigRepo TenantLoginConfigRepository,
+	refreshTokenRepo RefreshTokenRepository,
+	roleRepo RoleRepository,
+	loginAttemptRepo LoginAttemptRepository,
+	userLockoutRepo UserLockoutRepository,
+	userMFARepo UserMFARepository,
+	passwordResetRepo PasswordResetTokenRepository,
+	verificationRepo IdentifierVerificationRepository,
+	oauthAccountRepo OAuthAccountRepository,
+	oidcClientRepo OIDCClientRepository,
+	serviceAccountRepo ServiceAccountRepository,
+	apiKeyRepo APIKeyRepository,
+	webauthnCredentialRepo WebAuthnCredentialRepository,
+	passwordlessLoginRepo PasswordlessLoginRepository,
+	notifier notification.Notifier,
+	publisher events.Publisher,
+	permissionService *PermissionService,
+	oauthProviders *oauth.Providers,
+	oidcProvider *oidc.Provider,
 	jwtManager *jwt.Manager,
-	redisClient *redis.Client,
+	tokenHasher *authutils.TokenHasher,
+	sessionStore store.Store,
 	log *logger.Logger,
 ) *MultiTenantAuthService {
-	var redisCache *redis.Cache
-	if redisClient != nil {
-		redisCache = redis.NewCache(redisClient, redis.CacheConfig{
-			DefaultTTL: 24 * time.Hour,
-			KeyPrefix:  "auth",
-		})
-	}
-
 	return &MultiTenantAuthService{
-		userRepo:              userRepo,
-		userTenantRepo:        userTenantRepo,
-		tenantLoginConfigRepo: tenantLoginConfigRepo,
-		refreshTokenRepo:      refreshTokenRepo,
-		roleRepo:              roleRepo,
-		jwtManager:            jwtManager,
-		redisCache:            redisCache,
-		logger:                log,
+		userRepo:               userRepo,
+		userTenantRepo:         userTenantRepo,
+		tenantLoginConfigRepo:  tenantLoginConfigRepo,
+		refreshTokenRepo:       refreshTokenRepo,
+		roleRepo:               roleRepo,
+		loginAttemptRepo:       loginAttemptRepo,
+		userLockoutRepo:        userLockoutRepo,
+		userMFARepo:            userMFARepo,
+		passwordResetRepo:      passwordResetRepo,
+		verificationRepo:       verificationRepo,
+		oauthAccountRepo:       oauthAccountRepo,
+		oidcClientRepo:         oidcClientRepo,
+		serviceAccountRepo:     serviceAccountRepo,
+		apiKeyRepo:             apiKeyRepo,
+		webauthnCredentialRepo: webauthnCredentialRepo,
+		passwordlessLoginRepo:  passwordlessLoginRepo,
+		notifier:               notifier,
+		publisher:              publisher,
+		permissionService:      permissionService,
+		oauthProviders:         oauthProviders,
+		oidcProvider:           oidcProvider,
+		jwtManager:             jwtManager,
+		tokenHasher:            tokenHasher,
+		store:                  sessionStore,
+		logger:                 log,
 	}
 }
 
@@ -140,6 +188,9 @@ func (s *MultiTenantAuthService) Register(ctx context.Context, email, username,
 		// User created but tenant relationship failed - log for manual intervention
 	}
 
+	// 7. Send verification codes for the email and phone
+	s.sendRegistrationVerifications(ctx, user, tenantID)
+
 	s.logger.Info("User registered successfully",
 		zap.String("user_id", user.ID.Hex()),
 		zap.String("tenant_id", tenantID),
@@ -149,25 +200,32 @@ func (s *MultiTenantAuthService) Register(ctx context.Context, email, username,
 }
 
 // Login authenticates a user with multi-tenant support
-func (s *MultiTenantAuthService) Login(ctx context.Context, identifier, password, tenantID string) (*domain.LoginResponse, error) {
+func (s *MultiTenantAuthService) Login(ctx context.Context, identifier, password, tenantID, ipAddress string) (*domain.LoginResponse, error) {
 	// 1. Get tenant login configuration
 	loginConfig, err := s.tenantLoginConfigRepo.FindByTenant(ctx, tenantID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Reject early while the identifier or IP address is locked out
+	if err := s.checkLockout(ctx, tenantID, identifier, ipAddress); err != nil {
+		return nil, err
+	}
+
 	// 2. Find user by identifier
 	user, err := s.userRepo.FindByIdentifier(ctx, identifier)
 	if err != nil {
 		return nil, errors.Unauthorized("Invalid credentials")
 	}
 	if user == nil {
+		s.recordLoginAttempt(ctx, loginConfig, tenantID, identifier, ipAddress, "", false)
 		return nil, errors.Unauthorized("Invalid credentials")
 	}
 
 	// 3. Detect and validate login method
 	identifierType := domain.DetectIdentifierType(identifier, user)
 	if identifierType == "" {
+		s.recordLoginAttempt(ctx, loginConfig, tenantID, identifier, ipAddress, user.ID.Hex(), false)
 		return nil, errors.Unauthorized("Invalid credentials")
 	}
 
@@ -202,57 +260,115 @@ func (s *MultiTenantAuthService) Login(ctx context.Context, identifier, password
 
 	// 6. Verify password
 	if !utils.CheckPassword(password, user.PasswordHash) {
-		// TODO: Track failed login attempts
+		s.recordLoginAttempt(ctx, loginConfig, tenantID, identifier, ipAddress, user.ID.Hex(), false)
 		return nil, errors.Unauthorized("Invalid credentials")
 	}
 
-	// 7. Get user roles and permissions for this tenant
+	// Block login until the identifier used is verified, when the tenant requires it
+	if loginConfig.RequireVerifiedIdentifier && !identifierVerified(user, identifierType) {
+		return nil, errors.Forbidden(fmt.Sprintf("Your %s must be verified before you can sign in", identifierType))
+	}
+
+	// 7. Issue the session, or a second factor challenge
+	response, err := s.startSession(ctx, user, userTenant, loginConfig, identifier, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("User logged in successfully",
+		zap.String("user_id", user.ID.Hex()),
+		zap.String("tenant_id", tenantID),
+		zap.String("identifier_type", string(identifierType)),
+		zap.Bool("mfa_required", response.MFARequired))
+
+	return response, nil
+}
+
+// startSession runs the checks shared by every first factor: it returns an MFA challenge when the
+// user enrolled TOTP or the tenant enforces 2FA, and issues the session otherwise. The identifier
+// and IP address the first factor was presented with are empty for social logins and passkeys.
+func (s *MultiTenantAuthService) startSession(ctx context.Context, user *domain.User, userTenant *domain.UserTenant, loginConfig *domain.TenantLoginConfig, identifier, ipAddress string) (*domain.LoginResponse, error) {
+	mfa, err := s.userMFARepo.FindByUser(ctx, user.ID.Hex())
+	if err != nil {
+		s.logger.Error("Failed to get user mfa", zap.Error(err))
+		return nil, errors.Internal("Failed to check two-factor authentication")
+	}
+	enrolled := mfa != nil && mfa.Enabled
+	if enrolled || loginConfig.Require2FA {
+		return s.createMFAChallenge(ctx, user, userTenant.TenantID, identifier, ipAddress, !enrolled)
+	}
+
+	return s.completeLogin(ctx, user, userTenant, loginConfig, identifier, ipAddress)
+}
+
+// completeLogin issues tokens for an authenticated user once every login check has passed.
+// Only then is the login recorded as successful, which resets the failures of the identifier.
+func (s *MultiTenantAuthService) completeLogin(ctx context.Context, user *domain.User, userTenant *domain.UserTenant, loginConfig *domain.TenantLoginConfig, identifier, ipAddress string) (*domain.LoginResponse, error) {
+	// Get user roles and permissions for this tenant
 	roles := userTenant.Roles
-	permissions, err := s.roleRepo.GetPermissionsForRoles(ctx, roles, tenantID)
+	permissions, err := s.roleRepo.GetPermissionsForRoles(ctx, roles, userTenant.TenantID)
 	if err != nil {
 		s.logger.Error("Failed to get permissions", zap.Error(err))
 		permissions = []string{} // Continue with empty permissions
 	}
 
-	// 8. Generate tokens
-	response, err := s.generateTokens(ctx, user, tenantID, roles, permissions)
+	// Generate tokens
+	response, err := s.generateTokens(ctx, user, userTenant.TenantID, roles, permissions)
 	if err != nil {
 		return nil, err
 	}
+	if identifier != "" {
+		s.recordLoginAttempt(ctx, loginConfig, userTenant.TenantID, identifier, ipAddress, user.ID.Hex(), true)
+	}
 
-	// 9. Update last login time
+	// Update last login time
 	_ = s.userRepo.UpdateLastLogin(ctx, user.ID.Hex())
 
-	s.logger.Info("User logged in successfully",
-		zap.String("user_id", user.ID.Hex()),
-		zap.String("tenant_id", tenantID),
-		zap.String("identifier_type", string(identifierType)))
-
 	return response, nil
 }
 
-// VerifyToken verifies an opaque token and returns user information
+// VerifyToken verifies an opaque token or a personal API key and returns user information
 func (s *MultiTenantAuthService) VerifyToken(ctx context.Context, token string) (*domain.ValidateTokenResponse, error) {
-	if s.redisCache == nil {
+	if IsAPIKey(token) {
+		return s.verifyAPIKey(ctx, token)
+	}
+	if s.store == nil {
 		return nil, errors.Internal("Session store not available")
 	}
 
 	// Try to get session from Redis
+	tokenHash := s.tokenHasher.Hash(token)
 	var session domain.Session
-	err := s.redisCache.Get(ctx, fmt.Sprintf("session:%s", token), &session)
-	if err != nil {
+	err := s.store.Get(ctx, sessionKey(tokenHash), &session)
+	if stderrors.Is(err, store.ErrNotFound) {
 		return nil, errors.Unauthorized("Invalid or expired token")
 	}
+	if err != nil {
+		s.logger.Error("Failed to get session", zap.Error(err))
+		return nil, errors.Internal("Failed to verify token")
+	}
 
 	// Check if session is expired
 	if time.Now().After(session.ExpiresAt) {
-		_ = s.redisCache.Delete(ctx, fmt.Sprintf("session:%s", token))
+		_ = s.store.Delete(ctx, sessionKey(tokenHash))
 		return nil, errors.Unauthorized("Token expired")
 	}
+	if session.IdleTimeout > 0 && time.Since(session.LastSeenAt) > session.IdleTimeout {
+		_ = s.store.Delete(ctx, sessionKey(tokenHash))
+		return nil, errors.Unauthorized("Session expired due to inactivity")
+	}
+
+	if session.ServiceAccount {
+		return s.verifyServiceAccountToken(ctx, tokenHash, &session)
+	}
 
 	// Get full user information to ensure user still exists and is active
 	user, err := s.userRepo.FindByID(ctx, session.UserID)
-	if err != nil || user == nil {
+	if err != nil {
+		s.logger.Error("Failed to get user", zap.Error(err))
+		return nil, errors.Internal("Failed to verify token")
+	}
+	if user == nil {
 		return nil, errors.Unauthorized("User not found")
 	}
 
@@ -262,30 +378,54 @@ func (s *MultiTenantAuthService) VerifyToken(ctx context.Context, token string)
 
 	// Verify user still has access to tenant
 	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, session.UserID, session.TenantID)
-	if err != nil || userTenant == nil || !userTenant.IsActive {
+	if err != nil {
+		s.logger.Error("Failed to get user tenant", zap.Error(err))
+		return nil, errors.Internal("Failed to verify token")
+	}
+	if userTenant == nil || !userTenant.IsActive {
 		return nil, errors.Forbidden("User does not have access to this tenant")
 	}
 
-	// Get permissions
-	permissions, err := s.roleRepo.GetPermissionsForRoles(ctx, session.Roles, session.TenantID)
-	if err != nil {
-		permissions = []string{}
+	// Get the current roles and permissions, so role changes apply to existing sessions.
+	// Tokens issued to OIDC clients are limited to their scopes and grant none.
+	roles, permissions := []string{}, []string{}
+	if session.ClientID == "" {
+		roles = userTenant.Roles
+		if rolePermissions, err := s.roleRepo.GetPermissionsForRoles(ctx, roles, session.TenantID); err == nil {
+			permissions = rolePermissions
+		}
 	}
 
+	s.touchSession(ctx, tokenHash, &session)
+
 	return &domain.ValidateTokenResponse{
 		Valid:       true,
 		UserID:      session.UserID,
 		TenantID:    session.TenantID,
 		Email:       session.Email,
-		Roles:       session.Roles,
+		Roles:       roles,
 		Permissions: permissions,
-		Metadata: map[string]string{
-			"user_id":   session.UserID,
-			"tenant_id": session.TenantID,
-		},
+		Metadata:    sessionMetadata(&session),
 	}, nil
 }
 
+// sessionMetadata returns the metadata passed along with a verified token
+func sessionMetadata(session *domain.Session) map[string]string {
+	metadata := map[string]string{
+		"user_id":    session.UserID,
+		"tenant_id":  session.TenantID,
+		"session_id": session.ID,
+	}
+	if session.ClientID != "" {
+		metadata["client_id"] = session.ClientID
+		metadata["scope"] = session.Scope
+	}
+	if session.ServiceAccount {
+		metadata["service_account"] = "true"
+	}
+	return metadata
+}
+
 // GetTenantLoginConfig returns the login configuration for a tenant
 func (s *MultiTenantAuthService) GetTenantLoginConfig(ctx context.Context, tenantID string) (*domain.TenantLoginConfig, error) {
 	config, err := s.tenantLoginConfigRepo.FindByTenant(ctx, tenantID)
@@ -314,7 +454,12 @@ func (s *MultiTenantAuthService) AddUserToTenant(ctx context.Context, userID, te
 
 	if existing != nil {
 		// Already exists, update roles
-		return s.userTenantRepo.UpdateRoles(ctx, userID, tenantID, roles)
+		if err := s.userTenantRepo.UpdateRoles(ctx, userID, tenantID, roles); err != nil {
+			return err
+		}
+		s.invalidatePermissions(ctx, userID, tenantID)
+		s.publishEvent(ctx, &events.Event{Type: events.RolesChanged, UserID: userID, TenantID: tenantID})
+		return nil
 	}
 
 	// Create new relationship
@@ -325,22 +470,55 @@ func (s *MultiTenantAuthService) AddUserToTenant(ctx context.Context, userID, te
 		IsActive: true,
 	}
 
-	return s.userTenantRepo.Create(ctx, userTenant)
+	if err := s.userTenantRepo.Create(ctx, userTenant); err != nil {
+		return err
+	}
+	s.invalidatePermissions(ctx, userID, tenantID)
+	return nil
 }
 
 // RemoveUserFromTenant removes a user from a tenant
 func (s *MultiTenantAuthService) RemoveUserFromTenant(ctx context.Context, userID, tenantID string) error {
-	return s.userTenantRepo.Deactivate(ctx, userID, tenantID)
+	if err := s.userTenantRepo.Deactivate(ctx, userID, tenantID); err != nil {
+		return err
+	}
+	s.invalidatePermissions(ctx, userID, tenantID)
+	s.publishEvent(ctx, &events.Event{Type: events.UserRevoked, UserID: userID, TenantID: tenantID})
+	return nil
+}
+
+// invalidatePermissions drops the cached roles and permissions of a user in a tenant after their
+// membership changed
+func (s *MultiTenantAuthService) invalidatePermissions(ctx context.Context, userID, tenantID string) {
+	if s.permissionService == nil {
+		return
+	}
+	if err := s.permissionService.InvalidateUserPermissionCache(ctx, userID, tenantID); err != nil {
+		s.logger.Warn("Failed to invalidate permission cache",
+			zap.String("user_id", userID),
+			zap.String("tenant_id", tenantID),
+			zap.Error(err))
+	}
 }
 
 // RefreshToken refreshes an access token using a refresh token
 func (s *MultiTenantAuthService) RefreshToken(ctx context.Context, refreshTokenStr string) (*domain.LoginResponse, error) {
 	// Validate refresh token exists in DB
-	refreshToken, err := s.refreshTokenRepo.FindByToken(ctx, refreshTokenStr)
+	refreshToken, err := s.refreshTokenRepo.FindByTokenHash(ctx, s.tokenHasher.Hash(refreshTokenStr))
 	if err != nil {
 		return nil, errors.Internal("Failed to refresh token")
 	}
-	if refreshToken == nil || refreshToken.RevokedAt != nil {
+	if refreshToken == nil {
+		return nil, errors.Unauthorized("Invalid refresh token")
+	}
+
+	// A token that was already exchanged is being replayed, so it may have been stolen.
+	// Revoke the whole family, which also logs out whoever holds the latest token.
+	if refreshToken.RotatedAt != nil {
+		s.revokeTokenFamily(ctx, refreshToken)
+		return nil, errors.Unauthorized("Invalid refresh token")
+	}
+	if refreshToken.RevokedAt != nil {
 		return nil, errors.Unauthorized("Invalid refresh token")
 	}
 
@@ -349,6 +527,28 @@ func (s *MultiTenantAuthService) RefreshToken(ctx context.Context, refreshTokenS
 		return nil, errors.Unauthorized("Refresh token expired")
 	}
 
+	// A login left unused for the tenant's idle timeout cannot be continued either
+	policy := s.sessionPolicy(ctx, refreshToken.TenantID)
+	if policy.IdleTimeout > 0 && time.Since(s.lastActivity(ctx, refreshToken)) > policy.IdleTimeout {
+		s.logger.Info("Session expired due to inactivity",
+			zap.String("user_id", refreshToken.UserID),
+			zap.String("family_id", refreshToken.FamilyID))
+		if err := s.endLogin(ctx, refreshToken); err != nil {
+			s.logger.Error("Failed to revoke refresh token family", zap.Error(err))
+		}
+		return nil, errors.Unauthorized("Session expired due to inactivity")
+	}
+
+	// Single use: only one of two concurrent refreshes with the same token wins
+	rotated, err := s.refreshTokenRepo.MarkRotated(ctx, refreshToken.ID)
+	if err != nil {
+		return nil, errors.Internal("Failed to refresh token")
+	}
+	if !rotated {
+		s.revokeTokenFamily(ctx, refreshToken)
+		return nil, errors.Unauthorized("Invalid refresh token")
+	}
+
 	// Get user
 	user, err := s.userRepo.FindByID(ctx, refreshToken.UserID)
 	if err != nil || user == nil {
@@ -367,44 +567,65 @@ func (s *MultiTenantAuthService) RefreshToken(ctx context.Context, refreshTokenS
 		permissions = []string{}
 	}
 
-	// Generate new tokens
-	return s.generateTokens(ctx, user, refreshToken.TenantID, userTenant.Roles, permissions)
+	// Generate new tokens in the same family
+	return s.issueTokens(ctx, user, refreshToken.TenantID, userTenant.Roles, permissions, refreshToken, policy)
 }
 
-// Logout invalidates a token
+// Logout ends the session of an access token, including the refresh token issued with it
 func (s *MultiTenantAuthService) Logout(ctx context.Context, token string) error {
-	if s.redisCache != nil {
-		_ = s.redisCache.Delete(ctx, fmt.Sprintf("session:%s", token))
+	if s.store == nil {
+		return nil
 	}
-	return nil
+
+	tokenHash := s.tokenHasher.Hash(token)
+	var session domain.Session
+	if err := s.store.Get(ctx, sessionKey(tokenHash), &session); err != nil {
+		return nil
+	}
+	_ = s.store.Delete(ctx, sessionKey(tokenHash))
+
+	s.logger.Info("User logged out",
+		zap.String("user_id", session.UserID),
+		zap.String("tenant_id", session.TenantID),
+		zap.String("session_id", session.ID))
+
+	if entry, ok := s.userSessions(ctx, session.UserID)[session.ID]; ok {
+		return s.endSession(ctx, session.UserID, entry)
+	}
+	s.publishEvent(ctx, &events.Event{Type: events.SessionRevoked, UserID: session.UserID, TenantID: session.TenantID, SessionID: session.ID})
+	return s.refreshTokenRepo.RevokeFamily(ctx, session.RefreshFamily)
 }
 
-// generateTokens generates opaque access token and JWT refresh token
+// generateTokens generates opaque access token and JWT refresh token for a new login,
+// within the tenant's concurrent session limit
 func (s *MultiTenantAuthService) generateTokens(ctx context.Context, user *domain.User, tenantID string, roles, permissions []string) (*domain.LoginResponse, error) {
-	userID := user.ID.Hex()
+	policy := s.sessionPolicy(ctx, tenantID)
 
-	// Generate Opaque Access Token (random string)
-	accessToken, err := utils.GenerateRandomString(32)
+	// Concurrent logins of the user must not both take the last free session
+	unlock, err := s.lockUserSessions(ctx, user.ID.Hex(), policy)
 	if err != nil {
-		return nil, errors.Internal("Failed to generate access token")
+		return nil, err
 	}
+	defer unlock()
 
-	// Create session
-	session := domain.Session{
-		UserID:    userID,
-		TenantID:  tenantID,
-		Email:     user.Email,
-		Roles:     roles,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(24 * time.Hour),
+	if err := s.enforceSessionLimit(ctx, user.ID.Hex(), tenantID, policy); err != nil {
+		return nil, err
 	}
+	return s.issueTokens(ctx, user, tenantID, roles, permissions, nil, policy)
+}
 
-	// Store session in Redis
-	if s.redisCache != nil {
-		if err := s.redisCache.Set(ctx, fmt.Sprintf("session:%s", accessToken), session, 24*time.Hour); err != nil {
-			s.logger.Error("Failed to store session in Redis", zap.Error(err))
-			return nil, errors.Internal("Failed to create session")
-		}
+// issueTokens generates a token pair with the tenant's lifetimes. With a parent the refresh token
+// joins the parent's family and keeps its maximum age, otherwise it starts a new one.
+func (s *MultiTenantAuthService) issueTokens(ctx context.Context, user *domain.User, tenantID string, roles, permissions []string, parent *domain.RefreshToken, policy sessionPolicy) (*domain.LoginResponse, error) {
+	userID := user.ID.Hex()
+	now := time.Now()
+
+	var absoluteExpiresAt time.Time
+	if parent != nil {
+		absoluteExpiresAt = parent.AbsoluteExpiresAt
+	}
+	if absoluteExpiresAt.IsZero() && policy.MaxAge > 0 {
+		absoluteExpiresAt = now.Add(policy.MaxAge)
 	}
 
 	// Generate JWT Refresh Token
@@ -416,20 +637,48 @@ func (s *MultiTenantAuthService) generateTokens(ctx context.Context, user *domai
 	// Store refresh token in DB
 	refreshToken := &domain.RefreshToken{
 		UserID:    userID,
-		Token:     refreshTokenStr,
+		TokenHash: s.tokenHasher.Hash(refreshTokenStr),
 		TenantID:  tenantID,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+		ExpiresAt: capAt(now.Add(policy.RefreshTTL), absoluteExpiresAt),
+
+		AbsoluteExpiresAt: absoluteExpiresAt,
+	}
+	if parent != nil {
+		refreshToken.FamilyID = parent.FamilyID
+		refreshToken.ParentID = parent.ID.Hex()
 	}
+	stored := true
 	if err := s.refreshTokenRepo.Create(ctx, refreshToken); err != nil {
 		s.logger.Error("Failed to store refresh token", zap.Error(err))
 		// Continue anyway, user can re-login
+		stored = false
+	}
+
+	// Create session, linked to its refresh token so the pair can be revoked together
+	session := &domain.Session{
+		UserID:            userID,
+		TenantID:          tenantID,
+		Email:             user.Email,
+		Roles:             roles,
+		AbsoluteExpiresAt: absoluteExpiresAt,
+	}
+	if stored {
+		// The family outlives the access tokens it issues, so it identifies the login
+		session.ID = refreshToken.FamilyID
+		session.RefreshTokenID = refreshToken.ID.Hex()
+		session.RefreshFamily = refreshToken.FamilyID
+	}
+
+	accessToken, err := s.createSession(ctx, session, policy)
+	if err != nil {
+		return nil, err
 	}
 
 	return &domain.LoginResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshTokenStr,
 		TokenType:    "Bearer",
-		ExpiresIn:    86400, // 24 hours
+		ExpiresIn:    int64(session.ExpiresAt.Sub(session.CreatedAt).Seconds()),
 		User: domain.UserInfo{
 			ID:       userID,
 			Email:    user.Email,
@@ -439,6 +688,44 @@ func (s *MultiTenantAuthService) generateTokens(ctx context.Context, user *domai
 	}, nil
 }
 
+// createSession generates an opaque access token and stores its session in Redis.
+// A session without an ID starts a new login.
+func (s *MultiTenantAuthService) createSession(ctx context.Context, session *domain.Session, policy sessionPolicy) (string, error) {
+	// Generate Opaque Access Token (random string)
+	accessToken, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", errors.Internal("Failed to generate access token")
+	}
+	if session.ID == "" {
+		if session.ID, err = utils.GenerateRandomString(16); err != nil {
+			return "", errors.Internal("Failed to generate session ID")
+		}
+	}
+
+	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+	session.ExpiresAt = capAt(session.CreatedAt.Add(policy.AccessTTL), session.AbsoluteExpiresAt)
+	session.IdleTimeout = policy.IdleTimeout
+
+	// The login stays listed while its refresh token can be used
+	indexExpiresAt := session.ExpiresAt
+	if session.RefreshTokenID != "" {
+		indexExpiresAt = capAt(session.CreatedAt.Add(policy.RefreshTTL), session.AbsoluteExpiresAt)
+	}
+
+	// Store session in Redis, keyed by the token's hash
+	if s.store != nil {
+		tokenHash := s.tokenHasher.Hash(accessToken)
+		if err := s.store.Set(ctx, sessionKey(tokenHash), session, time.Until(sessionExpiry(session))); err != nil {
+			s.logger.Error("Failed to store session in Redis", zap.Error(err))
+			return "", errors.Internal("Failed to create session")
+		}
+		s.trackSession(ctx, session, tokenHash, indexExpiresAt)
+	}
+
+	return accessToken, nil
+}
+
 // validatePassword validates password against tenant requirements
 func (s *MultiTenantAuthService) validatePassword(password string, config *domain.TenantLoginConfig) error {
 	if len(password) < config.PasswordMinLength {
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/oidc"
+	"github.com/vhvplatform/go-shared/errors"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.uber.org/zap"
+)
+
+// grantTypeClientCredentials is the token endpoint grant of service accounts (RFC 6749 4.4)
+const grantTypeClientCredentials = "client_credentials"
+
+// CreateServiceAccount creates a service account in a tenant for a caller.
+// The roles must exist in the tenant and the caller must have each of them, so a caller cannot
+// grant more than they have. The client secret is returned once; only its hash is stored.
+func (s *MultiTenantAuthService) CreateServiceAccount(ctx context.Context, tenantID string, caller *domain.ValidateTokenResponse, req *domain.CreateServiceAccountRequest) (*domain.ServiceAccount, string, error) {
+	roles := req.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	if err := s.checkGrantableRoles(ctx, tenantID, caller, roles); err != nil {
+		return nil, "", err
+	}
+
+	clientID, err := utils.GenerateRandomString(24)
+	if err != nil {
+		return nil, "", errors.Internal("Failed to generate client ID")
+	}
+	secret, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, "", errors.Internal("Failed to generate client secret")
+	}
+
+	account := &domain.ServiceAccount{
+		TenantID:         tenantID,
+		Name:             req.Name,
+		Description:      req.Description,
+		ClientID:         clientID,
+		ClientSecretHash: s.tokenHasher.Hash(secret),
+		Roles:            roles,
+		IsActive:         true,
+	}
+	if err := s.serviceAccountRepo.Create(ctx, account); err != nil {
+		s.logger.Error("Failed to create service account", zap.Error(err))
+		return nil, "", errors.Internal("Failed to create service account")
+	}
+
+	s.logger.Info("Service account created",
+		zap.String("tenant_id", tenantID),
+		zap.String("client_id", clientID),
+		zap.Strings("roles", roles))
+
+	return account, secret, nil
+}
+
+// checkGrantableRoles checks that roles exist in a tenant and that the caller has each of them
+func (s *MultiTenantAuthService) checkGrantableRoles(ctx context.Context, tenantID string, caller *domain.ValidateTokenResponse, roles []string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+
+	found, err := s.roleRepo.FindByNames(ctx, roles, tenantID)
+	if err != nil {
+		s.logger.Error("Failed to find roles", zap.Error(err))
+		return errors.Internal("Failed to create service account")
+	}
+	existing := make(map[string]bool, len(found))
+	for _, role := range found {
+		existing[role.Name] = true
+	}
+
+	for _, role := range roles {
+		if !existing[role] {
+			return errors.BadRequest(fmt.Sprintf("Role %s does not exist", role))
+		}
+		if !utils.Contains(caller.Roles, role) {
+			return errors.Forbidden(fmt.Sprintf("Cannot grant role %s without having it", role))
+		}
+	}
+	return nil
+}
+
+// ListServiceAccounts lists the service accounts of a tenant
+func (s *MultiTenantAuthService) ListServiceAccounts(ctx context.Context, tenantID string) ([]*domain.ServiceAccount, error) {
+	accounts, err := s.serviceAccountRepo.FindByTenant(ctx, tenantID)
+	if err != nil {
+		s.logger.Error("Failed to list service accounts", zap.Error(err))
+		return nil, errors.Internal("Failed to list service accounts")
+	}
+	return accounts, nil
+}
+
+// DeleteServiceAccount removes a service account of a tenant and ends its sessions
+func (s *MultiTenantAuthService) DeleteServiceAccount(ctx context.Context, tenantID, accountID string) error {
+	deleted, err := s.serviceAccountRepo.Delete(ctx, tenantID, accountID)
+	if err != nil {
+		s.logger.Error("Failed to delete service account", zap.Error(err))
+		return errors.Internal("Failed to delete service account")
+	}
+	if !deleted {
+		return errors.NotFound("Service account not found")
+	}
+
+	if err := s.revokeAllUserSessions(ctx, accountID); err != nil {
+		s.logger.Warn("Failed to revoke service account sessions", zap.Error(err))
+	}
+	s.invalidatePermissions(ctx, accountID, tenantID)
+
+	s.logger.Info("Service account deleted", zap.String("tenant_id", tenantID), zap.String("service_account_id", accountID))
+	return nil
+}
+
+// ClientCredentialsToken issues an access token to a service account (client_credentials grant).
+// There is no refresh token; the account requests a new token when it expires.
+// Errors are returned as *oidc.Error.
+func (s *MultiTenantAuthService) ClientCredentialsToken(ctx context.Context, req *domain.OIDCTokenRequest) (*domain.OIDCTokenResponse, error) {
+	if s.store == nil {
+		return nil, oidc.NewError(oidc.ErrServerError, "Session store not available")
+	}
+	if req.GrantType != grantTypeClientCredentials {
+		return nil, oidc.NewError(oidc.ErrUnsupportedGrantType, "")
+	}
+
+	account, err := s.authenticateServiceAccount(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &domain.Session{
+		UserID:         account.ID.Hex(),
+		TenantID:       account.TenantID,
+		Roles:          account.Roles,
+		ClientID:       account.ClientID,
+		ServiceAccount: true,
+	}
+	accessToken, err := s.createSession(ctx, session, s.sessionPolicy(ctx, account.TenantID))
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrServerError, "")
+	}
+	_ = s.serviceAccountRepo.UpdateLastUsed(ctx, account.ID)
+
+	s.logger.Info("Service account token issued",
+		zap.String("tenant_id", account.TenantID),
+		zap.String("client_id", account.ClientID))
+
+	return &domain.OIDCTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(session.ExpiresAt.Sub(session.CreatedAt).Seconds()),
+	}, nil
+}
+
+// authenticateServiceAccount checks the client credentials of a service account
+func (s *MultiTenantAuthService) authenticateServiceAccount(ctx context.Context, clientID, clientSecret string) (*domain.ServiceAccount, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, oidc.NewError(oidc.ErrInvalidClient, "Client authentication failed")
+	}
+
+	account, err := s.serviceAccountRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		s.logger.Error("Failed to find service account", zap.Error(err))
+		return nil, oidc.NewError(oidc.ErrServerError, "")
+	}
+	if account == nil || subtle.ConstantTimeCompare([]byte(s.tokenHasher.Hash(clientSecret)), []byte(account.ClientSecretHash)) != 1 {
+		return nil, oidc.NewError(oidc.ErrInvalidClient, "Client authentication failed")
+	}
+	return account, nil
+}
+
+// verifyServiceAccountToken completes VerifyToken for a service account session.
+// Roles are read from the account, so role changes apply to tokens already issued.
+func (s *MultiTenantAuthService) verifyServiceAccountToken(ctx context.Context, tokenHash string, session *domain.Session) (*domain.ValidateTokenResponse, error) {
+	account, err := s.serviceAccountRepo.FindByID(ctx, session.UserID)
+	if err != nil {
+		s.logger.Error("Failed to get service account", zap.Error(err))
+		return nil, errors.Internal("Failed to verify token")
+	}
+	if account == nil || !account.IsActive || account.TenantID != session.TenantID {
+		_ = s.store.Delete(ctx, sessionKey(tokenHash))
+		return nil, errors.Unauthorized("Service account not found")
+	}
+
+	permissions, err := s.roleRepo.GetPermissionsForRoles(ctx, account.Roles, account.TenantID)
+	if err != nil {
+		permissions = []string{}
+	}
+
+	s.touchSession(ctx, tokenHash, session)
+
+	return &domain.ValidateTokenResponse{
+		Valid:       true,
+		UserID:      session.UserID,
+		TenantID:    session.TenantID,
+		Roles:       account.Roles,
+		Permissions: permissions,
+		Metadata:    sessionMetadata(session),
+	}, nil
+}
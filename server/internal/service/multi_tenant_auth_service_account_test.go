@@ -0,0 +1,175 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/service"
+	"github.com/vhvplatform/go-auth-service/internal/store"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+	"github.com/vhvplatform/go-shared/logger"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMultiTenantAuthService_CreateServiceAccount_Roles(t *testing.T) {
+	ctx := context.Background()
+	caller := &domain.ValidateTokenResponse{UserID: "admin", TenantID: testTenantID, Roles: []string{"admin", "reporter"}}
+
+	newRepos := func(found ...string) testRepos {
+		repos := testRepos{roles: &MockRoleRepository{}, serviceAccts: &MockServiceAccountRepository{}}
+		roles := []*domain.Role{}
+		for _, name := range found {
+			roles = append(roles, &domain.Role{Name: name, TenantID: testTenantID})
+		}
+		repos.roles.On("FindByNames", mock.Anything, mock.Anything, testTenantID).Return(roles, nil)
+		return repos
+	}
+
+	t.Run("Roles the caller has are granted", func(t *testing.T) {
+		repos := newRepos("reporter")
+		repos.serviceAccts.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+		authService := newTestAuthService(repos)
+
+		account, secret, err := authService.CreateServiceAccount(ctx, testTenantID, caller, &domain.CreateServiceAccountRequest{
+			Name:  "reports",
+			Roles: []string{"reporter"},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"reporter"}, account.Roles)
+		assert.NotEmpty(t, secret)
+		repos.serviceAccts.AssertExpectations(t)
+	})
+
+	t.Run("Unknown roles are rejected", func(t *testing.T) {
+		repos := newRepos()
+		authService := newTestAuthService(repos)
+
+		_, _, err := authService.CreateServiceAccount(ctx, testTenantID, caller, &domain.CreateServiceAccountRequest{
+			Name:  "reports",
+			Roles: []string{"reporter"},
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not exist")
+		repos.serviceAccts.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Roles the caller does not have are rejected", func(t *testing.T) {
+		repos := newRepos("owner")
+		authService := newTestAuthService(repos)
+
+		_, _, err := authService.CreateServiceAccount(ctx, testTenantID, caller, &domain.CreateServiceAccountRequest{
+			Name:  "backdoor",
+			Roles: []string{"owner"},
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Cannot grant role owner")
+		repos.serviceAccts.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestMultiTenantAuthService_ClientCredentialsToken(t *testing.T) {
+	ctx := context.Background()
+	caller := &domain.ValidateTokenResponse{UserID: "admin", TenantID: testTenantID, Roles: []string{"reporter"}}
+	repos := testRepos{
+		loginConfigs: &MockTenantLoginConfigRepository{},
+		roles:        &MockRoleRepository{},
+		serviceAccts: &MockServiceAccountRepository{},
+		store:        store.NewMemoryStore(),
+	}
+	repos.loginConfigs.On("FindByTenant", mock.Anything, testTenantID).Return(testLoginConfig(), nil)
+	repos.roles.On("FindByNames", mock.Anything, []string{"reporter"}, testTenantID).
+		Return([]*domain.Role{{Name: "reporter", TenantID: testTenantID}}, nil)
+	var account *domain.ServiceAccount
+	repos.serviceAccts.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		account = args.Get(1).(*domain.ServiceAccount)
+		account.ID = primitive.NewObjectID()
+	}).Return(nil).Once()
+	authService := newTestAuthService(repos)
+
+	_, secret, err := authService.CreateServiceAccount(ctx, testTenantID, caller, &domain.CreateServiceAccountRequest{
+		Name:  "reports",
+		Roles: []string{"reporter"},
+	})
+	require.NoError(t, err)
+	// A copy of the database is not enough to check guessed secrets
+	assert.NotEqual(t, authutils.HashToken(secret), account.ClientSecretHash)
+	repos.serviceAccts.On("FindByClientID", mock.Anything, account.ClientID).Return(account, nil)
+	repos.serviceAccts.On("UpdateLastUsed", mock.Anything, account.ID).Return(nil)
+
+	t.Run("The secret issues a token", func(t *testing.T) {
+		resp, err := authService.ClientCredentialsToken(ctx, &domain.OIDCTokenRequest{
+			GrantType:    "client_credentials",
+			ClientID:     account.ClientID,
+			ClientSecret: secret,
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+	})
+
+	t.Run("A wrong secret is rejected", func(t *testing.T) {
+		_, err := authService.ClientCredentialsToken(ctx, &domain.OIDCTokenRequest{
+			GrantType:    "client_credentials",
+			ClientID:     account.ClientID,
+			ClientSecret: secret + "x",
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid_client")
+	})
+}
+
+func TestMultiTenantAuthService_DeleteServiceAccount(t *testing.T) {
+	ctx := context.Background()
+	account := &domain.ServiceAccount{
+		ID:               primitive.NewObjectID(),
+		TenantID:         testTenantID,
+		ClientID:         "reports-client",
+		ClientSecretHash: authutils.NewTokenHasher(testHashKey).Hash("reports-secret"),
+		Roles:            []string{"reporter"},
+		IsActive:         true,
+	}
+	permissionCache := NewMockCache()
+	repos := testRepos{
+		loginConfigs:  &MockTenantLoginConfigRepository{},
+		refreshTokens: &MockRefreshTokenRepository{},
+		roles:         &MockRoleRepository{},
+		serviceAccts:  &MockServiceAccountRepository{},
+		permissions:   service.NewPermissionService(nil, nil, nil, nil, permissionCache, logger.NewLogger()),
+		store:         store.NewMemoryStore(),
+	}
+	repos.loginConfigs.On("FindByTenant", mock.Anything, testTenantID).Return(testLoginConfig(), nil)
+	repos.roles.On("GetPermissionsForRoles", mock.Anything, []string{"reporter"}, testTenantID).Return([]string{"report.read"}, nil)
+	repos.serviceAccts.On("FindByClientID", mock.Anything, account.ClientID).Return(account, nil)
+	repos.serviceAccts.On("FindByID", mock.Anything, account.ID.Hex()).Return(account, nil)
+	repos.serviceAccts.On("UpdateLastUsed", mock.Anything, account.ID).Return(nil)
+	authService := newTestAuthService(repos)
+
+	token, err := authService.ClientCredentialsToken(ctx, &domain.OIDCTokenRequest{
+		GrantType:    "client_credentials",
+		ClientID:     account.ClientID,
+		ClientSecret: "reports-secret",
+	})
+	require.NoError(t, err)
+	_, err = authService.VerifyToken(ctx, token.AccessToken)
+	require.NoError(t, err)
+	_, err = authService.ListSessions(ctx, token.AccessToken)
+	assert.Error(t, err, "service account tokens cannot manage an account")
+
+	repos.serviceAccts.On("Delete", mock.Anything, testTenantID, account.ID.Hex()).Return(true, nil).Once()
+	repos.refreshTokens.On("RevokeAllForUser", mock.Anything, account.ID.Hex()).Return(nil).Once()
+	permissionCache.On("Delete", mock.Anything, "permissions:"+account.ID.Hex()+":"+testTenantID).Return(nil).Once()
+	permissionCache.On("Delete", mock.Anything, "roles:"+account.ID.Hex()+":"+testTenantID).Return(nil).Once()
+
+	require.NoError(t, authService.DeleteServiceAccount(ctx, testTenantID, account.ID.Hex()))
+
+	// Neither its tokens nor the cached permissions outlive the account
+	_, err = authService.VerifyToken(ctx, token.AccessToken)
+	assert.Error(t, err)
+	permissionCache.AssertExpectations(t)
+}
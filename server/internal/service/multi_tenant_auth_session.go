@@ -0,0 +1,648 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/events"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+	"github.com/vhvplatform/go-shared/errors"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// Lifetimes used when a tenant has no login configuration
+	defaultAccessTokenTTL  = 24 * time.Hour
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+	// lastSeenInterval limits how often using a session updates its last-seen time
+	lastSeenInterval = time.Minute
+
+	// A login holds the user's session lock while it checks the session limit and adds its session.
+	// The lock expires on its own if the replica holding it dies.
+	sessionLockTTL   = 10 * time.Second
+	sessionLockWait  = 3 * time.Second
+	sessionLockRetry = 20 * time.Millisecond
+)
+
+// sessionPolicy holds a tenant's session lifetimes
+type sessionPolicy struct {
+	AccessTTL   time.Duration
+	RefreshTTL  time.Duration
+	IdleTimeout time.Duration // 0 disables the idle timeout
+	MaxAge      time.Duration // 0 lets refreshing continue a login indefinitely
+	MaxSessions int           // Concurrent sessions per user; 0 is unlimited
+	LimitPolicy domain.SessionLimitPolicy
+}
+
+// sessionPolicy reads the session lifetimes from the tenant's login configuration
+func (s *MultiTenantAuthService) sessionPolicy(ctx context.Context, tenantID string) sessionPolicy {
+	policy := sessionPolicy{
+		AccessTTL:  defaultAccessTokenTTL,
+		RefreshTTL: defaultRefreshTokenTTL,
+	}
+
+	config, err := s.tenantLoginConfigRepo.FindByTenant(ctx, tenantID)
+	if err != nil || config == nil {
+		return policy
+	}
+	if config.SessionTimeout > 0 {
+		policy.AccessTTL = time.Duration(config.SessionTimeout) * time.Minute
+	}
+	if config.RefreshTokenLifetime > 0 {
+		policy.RefreshTTL = time.Duration(config.RefreshTokenLifetime) * time.Minute
+	}
+	if config.IdleTimeout > 0 {
+		policy.IdleTimeout = time.Duration(config.IdleTimeout) * time.Minute
+	}
+	if config.MaxSessionAge > 0 {
+		policy.MaxAge = time.Duration(config.MaxSessionAge) * time.Minute
+	}
+	policy.MaxSessions = config.MaxConcurrentSessions
+	policy.LimitPolicy = config.SessionLimitPolicy
+	return policy
+}
+
+// lockUserSessions serializes the logins of a user whose tenant limits concurrent sessions, across
+// all replicas. It returns the function that releases the lock.
+func (s *MultiTenantAuthService) lockUserSessions(ctx context.Context, userID string, policy sessionPolicy) (func(), error) {
+	if policy.MaxSessions <= 0 || s.store == nil {
+		return func() {}, nil
+	}
+
+	owner, err := utils.GenerateRandomString(16)
+	if err != nil {
+		return nil, errors.Internal("Failed to create session")
+	}
+	key := userSessionsLockKey(userID)
+	deadline := time.Now().Add(sessionLockWait)
+	for {
+		locked, err := s.store.SetNX(ctx, key, owner, sessionLockTTL)
+		if err != nil {
+			s.logger.Error("Failed to lock user sessions", zap.String("user_id", userID), zap.Error(err))
+			return nil, errors.Internal("Failed to create session")
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Conflict("Another login of this account is in progress, try again")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Internal("Failed to create session")
+		case <-time.After(sessionLockRetry):
+		}
+	}
+
+	return func() {
+		// After expiring, the lock may belong to another login
+		if _, err := s.store.DeleteIfEqual(ctx, key, owner); err != nil {
+			s.logger.Warn("Failed to unlock user sessions", zap.String("user_id", userID), zap.Error(err))
+		}
+	}, nil
+}
+
+// enforceSessionLimit makes room for a new login within the tenant's concurrent session limit.
+// Depending on the tenant's policy it rejects the login or ends the user's oldest sessions.
+// The caller holds the user's session lock.
+func (s *MultiTenantAuthService) enforceSessionLimit(ctx context.Context, userID, tenantID string, policy sessionPolicy) error {
+	if policy.MaxSessions <= 0 || s.store == nil {
+		return nil
+	}
+
+	now := time.Now()
+	var active []*userSession
+	for _, entry := range s.userSessions(ctx, userID) {
+		if entry.TenantID != tenantID {
+			continue
+		}
+		// Sessions past the idle timeout cannot be continued, so they do not count
+		if policy.IdleTimeout > 0 && now.Sub(entry.LastSeenAt) > policy.IdleTimeout {
+			continue
+		}
+		active = append(active, entry)
+	}
+
+	excess := len(active) - policy.MaxSessions + 1
+	if excess <= 0 {
+		return nil
+	}
+	if policy.LimitPolicy != domain.SessionLimitEvictOldest {
+		return errors.Forbidden("Maximum number of concurrent sessions reached")
+	}
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].CreatedAt.Before(active[j].CreatedAt)
+	})
+	for _, entry := range active[:excess] {
+		if err := s.endSession(ctx, userID, entry); err != nil {
+			s.logger.Error("Failed to evict session", zap.Error(err))
+			return errors.Internal("Failed to create session")
+		}
+		s.logger.Info("Session evicted by concurrent session limit",
+			zap.String("user_id", userID),
+			zap.String("tenant_id", tenantID),
+			zap.String("session_id", entry.ID))
+	}
+	return nil
+}
+
+// sessionExpiry returns when a session ends: at its expiry, or earlier when it stays unused for its idle timeout
+func sessionExpiry(session *domain.Session) time.Time {
+	if session.IdleTimeout > 0 {
+		if idle := session.LastSeenAt.Add(session.IdleTimeout); idle.Before(session.ExpiresAt) {
+			return idle
+		}
+	}
+	return session.ExpiresAt
+}
+
+// capAt returns t, or limit when limit is set and earlier
+func capAt(t, limit time.Time) time.Time {
+	if !limit.IsZero() && limit.Before(t) {
+		return limit
+	}
+	return t
+}
+
+// userSession is an entry of a user's session index. It covers one login and every access token
+// issued by refreshing it, so the login can be listed and revoked as a whole.
+//
+// The index is a Redis hash per user. An entry is spread over fields that are written on their own,
+// so concurrent logins, refreshes and requests of a user do not overwrite each other's changes:
+//   - "<session ID>" holds the entry
+//   - "<session ID>:seen" holds when the login was last used
+//   - "<session ID>:token:<hash>" holds the expiry of one of its access tokens
+type userSession struct {
+	domain.SessionInfo
+	RefreshFamily string               `json:"refresh_family,omitempty"`
+	TokenHashes   map[string]time.Time `json:"-"` // Access token hash -> expiry
+}
+
+const (
+	seenFieldSuffix = ":seen"
+	tokenFieldInfix = ":token:"
+)
+
+// fields returns the index fields of an entry
+func (e *userSession) fields() []string {
+	fields := []string{e.ID, e.ID + seenFieldSuffix}
+	for tokenHash := range e.TokenHashes {
+		fields = append(fields, tokenField(e.ID, tokenHash))
+	}
+	return fields
+}
+
+// tokenField returns the index field of an access token of a session
+func tokenField(sessionID, tokenHash string) string {
+	return sessionID + tokenFieldInfix + tokenHash
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo attaches the caller's device to a context. Sessions created with the context record it.
+func WithClientInfo(ctx context.Context, info domain.ClientInfo) context.Context {
+	if info.Device == "" {
+		info.Device = authutils.DescribeDevice(info.UserAgent)
+	}
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+func clientInfoFrom(ctx context.Context) domain.ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(domain.ClientInfo)
+	return info
+}
+
+// ListSessions lists the active sessions of the user behind an access token, marking the current one
+func (s *MultiTenantAuthService) ListSessions(ctx context.Context, accessToken string) ([]*domain.SessionInfo, error) {
+	current, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.listSessions(ctx, current.UserID, "", current.ID), nil
+}
+
+// RevokeSession signs the user behind an access token out of one of their sessions
+func (s *MultiTenantAuthService) RevokeSession(ctx context.Context, accessToken, sessionID string) error {
+	current, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+	return s.revokeSession(ctx, current.UserID, "", sessionID)
+}
+
+// RevokeAllSessions signs the user behind an access token out of every session.
+// With keepCurrent the session of the access token stays signed in.
+func (s *MultiTenantAuthService) RevokeAllSessions(ctx context.Context, accessToken string, keepCurrent bool) error {
+	current, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+	if keepCurrent {
+		return s.revokeOtherUserSessions(ctx, current.UserID, accessToken)
+	}
+	return s.revokeAllUserSessions(ctx, current.UserID)
+}
+
+// ListUserSessions lists a user's active sessions in a tenant, for tenant administrators
+func (s *MultiTenantAuthService) ListUserSessions(ctx context.Context, tenantID, userID string) ([]*domain.SessionInfo, error) {
+	if s.store == nil {
+		return nil, errors.Internal("Session store not available")
+	}
+	return s.listSessions(ctx, userID, tenantID, ""), nil
+}
+
+// RevokeUserSession ends one of a user's sessions in a tenant, for tenant administrators
+func (s *MultiTenantAuthService) RevokeUserSession(ctx context.Context, tenantID, userID, sessionID string) error {
+	if s.store == nil {
+		return errors.Internal("Session store not available")
+	}
+	return s.revokeSession(ctx, userID, tenantID, sessionID)
+}
+
+// RevokeAllUserSessions ends every session of a user in a tenant, for tenant administrators
+func (s *MultiTenantAuthService) RevokeAllUserSessions(ctx context.Context, tenantID, userID string) error {
+	if s.store == nil {
+		return errors.Internal("Session store not available")
+	}
+
+	for _, entry := range s.userSessions(ctx, userID) {
+		if entry.TenantID != tenantID {
+			continue
+		}
+		if err := s.endSession(ctx, userID, entry); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Info("All tenant sessions revoked",
+		zap.String("user_id", userID),
+		zap.String("tenant_id", tenantID))
+	return nil
+}
+
+// sessionForToken verifies an access token and returns its session. Only interactive sessions a user
+// signed in for can manage the account; API keys, service accounts and tokens issued to OIDC clients
+// are limited to their permissions or scopes.
+func (s *MultiTenantAuthService) sessionForToken(ctx context.Context, accessToken string) (*domain.Session, error) {
+	if IsAPIKey(accessToken) {
+		return nil, errors.Forbidden("API keys cannot manage the account")
+	}
+	session, err := s.tokenSession(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if session.ClientID != "" {
+		return nil, errors.Forbidden("Access token was issued to a client application")
+	}
+	if session.ServiceAccount {
+		return nil, errors.Forbidden("Service account tokens cannot manage the account")
+	}
+	return session, nil
+}
+
+// tokenSession verifies an access token and returns its session, whoever it was issued to
+func (s *MultiTenantAuthService) tokenSession(ctx context.Context, accessToken string) (*domain.Session, error) {
+	if _, err := s.VerifyToken(ctx, accessToken); err != nil {
+		return nil, err
+	}
+
+	var session domain.Session
+	if err := s.store.Get(ctx, sessionKey(s.tokenHasher.Hash(accessToken)), &session); err != nil {
+		return nil, errors.Unauthorized("Invalid or expired token")
+	}
+	return &session, nil
+}
+
+// listSessions returns a user's sessions, newest first, optionally limited to one tenant
+func (s *MultiTenantAuthService) listSessions(ctx context.Context, userID, tenantID, currentID string) []*domain.SessionInfo {
+	result := []*domain.SessionInfo{}
+	for _, entry := range s.userSessions(ctx, userID) {
+		if tenantID != "" && entry.TenantID != tenantID {
+			continue
+		}
+		info := entry.SessionInfo
+		info.Current = info.ID == currentID
+		result = append(result, &info)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// revokeSession ends one session of a user, optionally only if it belongs to a tenant
+func (s *MultiTenantAuthService) revokeSession(ctx context.Context, userID, tenantID, sessionID string) error {
+	entry, ok := s.userSessions(ctx, userID)[sessionID]
+	if !ok || (tenantID != "" && entry.TenantID != tenantID) {
+		return errors.NotFound("Session not found")
+	}
+
+	if err := s.endSession(ctx, userID, entry); err != nil {
+		return err
+	}
+
+	s.logger.Info("Session revoked",
+		zap.String("user_id", userID),
+		zap.String("session_id", sessionID))
+	return nil
+}
+
+// endSession deletes the access tokens of an index entry and revokes its refresh token family
+func (s *MultiTenantAuthService) endSession(ctx context.Context, userID string, entry *userSession) error {
+	s.dropSession(ctx, userID, entry)
+	return s.refreshTokenRepo.RevokeFamily(ctx, entry.RefreshFamily)
+}
+
+// dropSession deletes the access tokens of an index entry and removes the entry from the index
+func (s *MultiTenantAuthService) dropSession(ctx context.Context, userID string, entry *userSession) {
+	if keys := tokenKeys(entry.TokenHashes); len(keys) > 0 {
+		_ = s.store.Delete(ctx, keys...)
+	}
+	_ = s.store.HashDelete(ctx, userSessionsKey(userID), entry.fields()...)
+	s.publishEvent(ctx, &events.Event{Type: events.SessionRevoked, UserID: userID, TenantID: entry.TenantID, SessionID: entry.ID})
+}
+
+// tokenKeys returns the session keys of access token hashes
+func tokenKeys(tokenHashes map[string]time.Time) []string {
+	keys := make([]string, 0, len(tokenHashes))
+	for tokenHash := range tokenHashes {
+		keys = append(keys, sessionKey(tokenHash))
+	}
+	return keys
+}
+
+// trackSession adds an access token to the user's session index so that all sessions of a user
+// can be listed and revoked together. Tokens of the same login share one entry.
+func (s *MultiTenantAuthService) trackSession(ctx context.Context, session *domain.Session, tokenHash string, expiresAt time.Time) {
+	if s.store == nil {
+		return
+	}
+
+	info := clientInfoFrom(ctx)
+	entry, ok := s.userSessions(ctx, session.UserID)[session.ID]
+	if !ok {
+		entry = &userSession{
+			SessionInfo: domain.SessionInfo{
+				ID:        session.ID,
+				TenantID:  session.TenantID,
+				ClientID:  session.ClientID,
+				Device:    info.Device,
+				UserAgent: info.UserAgent,
+				CreatedAt: session.CreatedAt,
+			},
+			RefreshFamily: session.RefreshFamily,
+		}
+	}
+
+	if info.IPAddress != "" {
+		entry.IPAddress = info.IPAddress
+	}
+	entry.LastSeenAt = session.CreatedAt
+	if expiresAt.After(entry.ExpiresAt) {
+		entry.ExpiresAt = expiresAt
+	}
+
+	// The entry and its token are stored together, so the token is never indexed without its login
+	fields := map[string]interface{}{
+		session.ID:                        entry,
+		session.ID + seenFieldSuffix:      session.CreatedAt,
+		tokenField(session.ID, tokenHash): session.ExpiresAt,
+	}
+	if err := s.store.HashSet(ctx, userSessionsKey(session.UserID), fields, time.Until(entry.ExpiresAt)); err != nil {
+		s.logger.Warn("Failed to store user session index", zap.String("user_id", session.UserID), zap.Error(err))
+	}
+}
+
+// touchSession records that an access token was used, at most once per lastSeenInterval.
+// With an idle timeout this slides the session's expiry, never past the access token's own expiry.
+func (s *MultiTenantAuthService) touchSession(ctx context.Context, tokenHash string, session *domain.Session) {
+	interval := lastSeenInterval
+	if session.IdleTimeout > 0 && session.IdleTimeout/2 < interval {
+		interval = session.IdleTimeout / 2
+	}
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < interval {
+		return
+	}
+
+	session.LastSeenAt = now
+	if err := s.store.Set(ctx, sessionKey(tokenHash), session, time.Until(sessionExpiry(session))); err != nil {
+		s.logger.Warn("Failed to update session", zap.Error(err))
+		return
+	}
+
+	fields := map[string]interface{}{session.ID + seenFieldSuffix: now}
+	if err := s.store.HashSet(ctx, userSessionsKey(session.UserID), fields, time.Until(sessionExpiry(session))); err != nil {
+		s.logger.Warn("Failed to update user session index", zap.String("user_id", session.UserID), zap.Error(err))
+	}
+}
+
+// lastActivity returns when the login of a refresh token was last used
+func (s *MultiTenantAuthService) lastActivity(ctx context.Context, token *domain.RefreshToken) time.Time {
+	last := token.CreatedAt
+	if s.store == nil {
+		return last
+	}
+	if entry, ok := s.userSessions(ctx, token.UserID)[token.FamilyID]; ok && entry.LastSeenAt.After(last) {
+		last = entry.LastSeenAt
+	}
+	return last
+}
+
+// endLogin ends the login of a refresh token: its family is revoked and its access tokens are deleted
+func (s *MultiTenantAuthService) endLogin(ctx context.Context, token *domain.RefreshToken) error {
+	if s.store != nil {
+		if entry, ok := s.userSessions(ctx, token.UserID)[token.FamilyID]; ok {
+			return s.endSession(ctx, token.UserID, entry)
+		}
+	}
+	return s.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID)
+}
+
+// deleteAccessToken deletes one access token, leaving the rest of its login signed in
+func (s *MultiTenantAuthService) deleteAccessToken(ctx context.Context, tokenHash string, session *domain.Session) {
+	_ = s.store.Delete(ctx, sessionKey(tokenHash))
+	s.publishEvent(ctx, &events.Event{Type: events.SessionRevoked, UserID: session.UserID, TenantID: session.TenantID, SessionID: session.ID})
+
+	entry, ok := s.userSessions(ctx, session.UserID)[session.ID]
+	if !ok {
+		return
+	}
+	fields := []string{tokenField(session.ID, tokenHash)}
+	delete(entry.TokenHashes, tokenHash)
+	// A login without a refresh token cannot issue new access tokens
+	if len(entry.TokenHashes) == 0 && entry.RefreshFamily == "" {
+		fields = append(fields, entry.fields()...)
+	}
+	_ = s.store.HashDelete(ctx, userSessionsKey(session.UserID), fields...)
+}
+
+// revokeAllUserSessions deletes every Redis session of a user and revokes all of their refresh tokens
+func (s *MultiTenantAuthService) revokeAllUserSessions(ctx context.Context, userID string) error {
+	if s.store != nil {
+		keys := []string{userSessionsKey(userID)}
+		for _, entry := range s.userSessions(ctx, userID) {
+			keys = append(keys, tokenKeys(entry.TokenHashes)...)
+		}
+		_ = s.store.Delete(ctx, keys...)
+	}
+	s.publishEvent(ctx, &events.Event{Type: events.UserRevoked, UserID: userID})
+
+	if err := s.refreshTokenRepo.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	s.logger.Info("All sessions revoked", zap.String("user_id", userID))
+	return nil
+}
+
+// revokeOtherUserSessions deletes every Redis session of a user except the current one
+// and revokes all refresh tokens except those of the current session
+func (s *MultiTenantAuthService) revokeOtherUserSessions(ctx context.Context, userID, currentToken string) error {
+	var keepFamily string
+	if s.store != nil {
+		var currentID string
+		var current domain.Session
+		if err := s.store.Get(ctx, sessionKey(s.tokenHasher.Hash(currentToken)), &current); err == nil && current.UserID == userID {
+			currentID, keepFamily = current.ID, current.RefreshFamily
+		}
+
+		for id, entry := range s.userSessions(ctx, userID) {
+			if id != currentID {
+				s.dropSession(ctx, userID, entry)
+			}
+		}
+	}
+
+	if err := s.refreshTokenRepo.RevokeAllForUserExceptFamily(ctx, userID, keepFamily); err != nil {
+		return err
+	}
+
+	s.logger.Info("Other sessions revoked", zap.String("user_id", userID))
+	return nil
+}
+
+// revokeTokenFamily revokes every refresh token of a family and deletes the Redis sessions issued with them
+func (s *MultiTenantAuthService) revokeTokenFamily(ctx context.Context, token *domain.RefreshToken) {
+	s.logger.Warn("Refresh token reuse detected, revoking token family",
+		zap.String("user_id", token.UserID),
+		zap.String("family_id", token.FamilyID))
+
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		s.logger.Error("Failed to revoke refresh token family", zap.Error(err))
+	}
+
+	if s.store == nil {
+		return
+	}
+	for _, entry := range s.userSessions(ctx, token.UserID) {
+		if entry.RefreshFamily == token.FamilyID {
+			s.dropSession(ctx, token.UserID, entry)
+		}
+	}
+}
+
+// publishEvent tells the gateway which of its cached token validations are stale.
+// A failure is only logged: the cached validations still expire on their own.
+func (s *MultiTenantAuthService) publishEvent(ctx context.Context, event *events.Event) {
+	if s.publisher == nil {
+		return
+	}
+	event.Time = time.Now()
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		s.logger.Warn("Failed to publish auth event",
+			zap.String("type", event.Type),
+			zap.String("user_id", event.UserID),
+			zap.Error(err))
+	}
+}
+
+// userSessions loads the user's session index (session ID -> entry), dropping expired sessions and tokens.
+// Tokens left in the index by a login that was ended concurrently are deleted along with it.
+func (s *MultiTenantAuthService) userSessions(ctx context.Context, userID string) map[string]*userSession {
+	sessions := map[string]*userSession{}
+	fields, err := s.store.HashGetAll(ctx, userSessionsKey(userID))
+	if err != nil {
+		s.logger.Warn("Failed to load user session index", zap.String("user_id", userID), zap.Error(err))
+		return sessions
+	}
+
+	for field, value := range fields {
+		if strings.Contains(field, ":") {
+			continue
+		}
+		var entry userSession
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			continue
+		}
+		entry.TokenHashes = map[string]time.Time{}
+		sessions[field] = &entry
+	}
+
+	now := time.Now()
+	var stale []string
+	orphans := map[string]time.Time{}
+	for field, value := range fields {
+		sessionID, _, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		var t time.Time
+		if err := json.Unmarshal([]byte(value), &t); err != nil {
+			stale = append(stale, field)
+			continue
+		}
+		entry, indexed := sessions[sessionID]
+		tokenHash, isToken := strings.CutPrefix(field, sessionID+tokenFieldInfix)
+		switch {
+		case isToken && !now.Before(t):
+			stale = append(stale, field)
+		case !indexed:
+			// The login was ended while one of its tokens was issued or used
+			stale = append(stale, field)
+			if isToken {
+				orphans[tokenHash] = t
+			}
+		case isToken:
+			entry.TokenHashes[tokenHash] = t
+		case t.After(entry.LastSeenAt):
+			entry.LastSeenAt = t
+		}
+	}
+
+	for id, entry := range sessions {
+		if now.After(entry.ExpiresAt) {
+			stale = append(stale, entry.fields()...)
+			delete(sessions, id)
+		}
+	}
+	if keys := tokenKeys(orphans); len(keys) > 0 {
+		_ = s.store.Delete(ctx, keys...)
+	}
+	if len(stale) > 0 {
+		_ = s.store.HashDelete(ctx, userSessionsKey(userID), stale...)
+	}
+	return sessions
+}
+
+// sessionKey returns the Redis key of a session. Keys hold the keyed hash of the access token,
+// so tokens cannot be read back from Redis.
+func sessionKey(tokenHash string) string {
+	return fmt.Sprintf("session:%s", tokenHash)
+}
+
+func userSessionsKey(userID string) string {
+	return fmt.Sprintf("user_sessions:%s", userID)
+}
+
+func userSessionsLockKey(userID string) string {
+	return fmt.Sprintf("lock:user_sessions:%s", userID)
+}
@@ -0,0 +1,369 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/service"
+	"github.com/vhvplatform/go-auth-service/internal/store"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+	"github.com/vhvplatform/go-shared/logger"
+)
+
+// expectRefresh lets a refresh token be looked up and rotated once, like the repository does
+func expectRefresh(repos testRepos, token *domain.RefreshToken) {
+	repos.refreshTokens.On("FindByTokenHash", mock.Anything, token.TokenHash).Return(token, nil)
+	repos.refreshTokens.On("MarkRotated", mock.Anything, token.ID).Run(func(mock.Arguments) {
+		now := time.Now()
+		token.RotatedAt = &now
+	}).Return(true, nil).Once()
+}
+
+// expectRevokeFamily revokes the issued refresh tokens of a family, like the repository does
+func expectRevokeFamily(repos testRepos, issued *[]*domain.RefreshToken, familyID string) {
+	repos.refreshTokens.On("RevokeFamily", mock.Anything, familyID).Run(func(mock.Arguments) {
+		now := time.Now()
+		for _, token := range *issued {
+			if token.FamilyID == familyID {
+				token.RevokedAt = &now
+			}
+		}
+	}).Return(nil).Once()
+}
+
+func TestMultiTenantAuthService_RefreshToken_ReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	repos, _, issued := sessionRepos(t, testLoginConfig())
+	authService := newTestAuthService(repos)
+
+	first, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	other, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	original := (*issued)[0]
+	expectRefresh(repos, original)
+
+	rotated, err := authService.RefreshToken(ctx, first.RefreshToken)
+	require.NoError(t, err)
+	require.Len(t, *issued, 3)
+	child := (*issued)[2]
+	assert.Equal(t, original.FamilyID, child.FamilyID)
+	assert.Equal(t, original.ID.Hex(), child.ParentID)
+	_, err = authService.VerifyToken(ctx, rotated.AccessToken)
+	require.NoError(t, err)
+
+	// Replaying the exchanged token ends the login for whoever holds the latest token too
+	expectRevokeFamily(repos, issued, original.FamilyID)
+	_, err = authService.RefreshToken(ctx, first.RefreshToken)
+	require.Error(t, err)
+
+	_, err = authService.VerifyToken(ctx, first.AccessToken)
+	assert.Error(t, err)
+	_, err = authService.VerifyToken(ctx, rotated.AccessToken)
+	assert.Error(t, err)
+	_, err = authService.VerifyToken(ctx, other.AccessToken)
+	assert.NoError(t, err, "other logins are not affected")
+
+	repos.refreshTokens.On("FindByTokenHash", mock.Anything, child.TokenHash).Return(child, nil)
+	_, err = authService.RefreshToken(ctx, rotated.RefreshToken)
+	assert.Error(t, err)
+	repos.refreshTokens.AssertNotCalled(t, "MarkRotated", mock.Anything, child.ID)
+	repos.refreshTokens.AssertExpectations(t)
+}
+
+func TestMultiTenantAuthService_RefreshToken_ConcurrentRefreshRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	repos, _, issued := sessionRepos(t, testLoginConfig())
+	authService := newTestAuthService(repos)
+
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+	token := (*issued)[0]
+
+	// Another refresh with the same token rotated it between the lookup and the rotation
+	repos.refreshTokens.On("FindByTokenHash", mock.Anything, token.TokenHash).Return(token, nil)
+	repos.refreshTokens.On("MarkRotated", mock.Anything, token.ID).Return(false, nil).Once()
+	expectRevokeFamily(repos, issued, token.FamilyID)
+
+	_, err = authService.RefreshToken(ctx, login.RefreshToken)
+	require.Error(t, err)
+
+	_, err = authService.VerifyToken(ctx, login.AccessToken)
+	assert.Error(t, err)
+	assert.Len(t, *issued, 1, "no tokens are issued")
+	repos.refreshTokens.AssertExpectations(t)
+}
+
+// slowStore delays returning what it read, widening the window in which concurrent requests could
+// overwrite each other's changes to the session index
+type slowStore struct {
+	store.Store
+}
+
+func (s slowStore) Get(ctx context.Context, key string, value interface{}) error {
+	defer time.Sleep(time.Millisecond)
+	return s.Store.Get(ctx, key, value)
+}
+
+func (s slowStore) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	defer time.Sleep(time.Millisecond)
+	return s.Store.HashGetAll(ctx, key)
+}
+
+func TestMultiTenantAuthService_ConcurrentLoginsAreAllIndexed(t *testing.T) {
+	ctx := context.Background()
+	repos, _, _ := sessionRepos(t, testLoginConfig())
+	repos.store = slowStore{Store: store.NewMemoryStore()}
+	authService := newTestAuthService(repos)
+
+	const logins = 20
+	var wg sync.WaitGroup
+	tokens := make([]string, logins)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			response, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+			if assert.NoError(t, err) {
+				tokens[i] = response.AccessToken
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	sessions, err := authService.ListSessions(ctx, tokens[0])
+	require.NoError(t, err)
+	assert.Len(t, sessions, logins)
+
+	// Every login is in the index, so revoking all of them leaves none signed in
+	repos.refreshTokens.On("RevokeAllForUser", mock.Anything, mock.Anything).Return(nil).Once()
+	require.NoError(t, authService.RevokeAllSessions(ctx, tokens[0], false))
+	for _, token := range tokens {
+		_, err := authService.VerifyToken(ctx, token)
+		assert.Error(t, err)
+	}
+}
+
+// idleConfig returns a login configuration that ends sessions unused for 30 minutes
+func idleConfig() *domain.TenantLoginConfig {
+	config := testLoginConfig()
+	config.IdleTimeout = 30
+	return config
+}
+
+// idleSession moves the last use of an access token back in time
+func idleSession(t *testing.T, sessionStore store.Store, accessToken string, idle time.Duration) {
+	ctx := context.Background()
+	key := "session:" + authutils.NewTokenHasher(testHashKey).Hash(accessToken)
+	var session domain.Session
+	require.NoError(t, sessionStore.Get(ctx, key, &session))
+	session.LastSeenAt = time.Now().Add(-idle)
+	require.NoError(t, sessionStore.Set(ctx, key, &session, time.Hour))
+}
+
+func TestMultiTenantAuthService_VerifyToken_IdleTimeout(t *testing.T) {
+	ctx := context.Background()
+	repos, _, _ := sessionRepos(t, idleConfig())
+	authService := newTestAuthService(repos)
+
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+
+	t.Run("Use within the timeout slides the session", func(t *testing.T) {
+		idleSession(t, repos.store, login.AccessToken, 20*time.Minute)
+
+		_, err := authService.VerifyToken(ctx, login.AccessToken)
+		require.NoError(t, err)
+
+		var session domain.Session
+		key := "session:" + authutils.NewTokenHasher(testHashKey).Hash(login.AccessToken)
+		require.NoError(t, repos.store.Get(ctx, key, &session))
+		assert.WithinDuration(t, time.Now(), session.LastSeenAt, time.Minute)
+	})
+
+	t.Run("Use after the timeout ends the session", func(t *testing.T) {
+		idleSession(t, repos.store, login.AccessToken, 31*time.Minute)
+
+		_, err := authService.VerifyToken(ctx, login.AccessToken)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "inactivity")
+
+		_, err = authService.VerifyToken(ctx, login.AccessToken)
+		assert.Error(t, err)
+	})
+}
+
+func TestMultiTenantAuthService_RefreshToken_IdleTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Refreshing an unused login ends it", func(t *testing.T) {
+		repos, _, issued := sessionRepos(t, idleConfig())
+		repos.store = nil
+		authService := newTestAuthService(repos)
+
+		login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+		require.NoError(t, err)
+		token := (*issued)[0]
+		token.CreatedAt = time.Now().Add(-31 * time.Minute)
+		repos.refreshTokens.On("FindByTokenHash", mock.Anything, token.TokenHash).Return(token, nil)
+		repos.refreshTokens.On("RevokeFamily", mock.Anything, token.FamilyID).Return(nil).Once()
+
+		_, err = authService.RefreshToken(ctx, login.RefreshToken)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "inactivity")
+		repos.refreshTokens.AssertNotCalled(t, "MarkRotated", mock.Anything, mock.Anything)
+		repos.refreshTokens.AssertExpectations(t)
+	})
+
+	t.Run("Using the access token keeps the login alive", func(t *testing.T) {
+		repos, _, issued := sessionRepos(t, idleConfig())
+		authService := newTestAuthService(repos)
+
+		login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+		require.NoError(t, err)
+		// The refresh token is old, but the login was used just now
+		token := (*issued)[0]
+		token.CreatedAt = time.Now().Add(-31 * time.Minute)
+		expectRefresh(repos, token)
+
+		_, err = authService.RefreshToken(ctx, login.RefreshToken)
+		require.NoError(t, err)
+		repos.refreshTokens.AssertNotCalled(t, "RevokeFamily", mock.Anything, mock.Anything)
+	})
+}
+
+// limitConfig returns a login configuration that allows two concurrent sessions per user
+func limitConfig(policy domain.SessionLimitPolicy) *domain.TenantLoginConfig {
+	config := testLoginConfig()
+	config.MaxConcurrentSessions = 2
+	config.SessionLimitPolicy = policy
+	return config
+}
+
+func TestMultiTenantAuthService_Login_SessionLimit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Reject policy refuses the login beyond the limit", func(t *testing.T) {
+		repos, _, issued := sessionRepos(t, limitConfig(domain.SessionLimitReject))
+		authService := newTestAuthService(repos)
+
+		first, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+		require.NoError(t, err)
+		_, err = authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+		require.NoError(t, err)
+
+		_, err = authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Maximum number of concurrent sessions")
+		assert.Len(t, *issued, 2)
+
+		_, err = authService.VerifyToken(ctx, first.AccessToken)
+		assert.NoError(t, err, "existing sessions stay signed in")
+	})
+
+	t.Run("Evict policy ends the oldest session", func(t *testing.T) {
+		repos, _, issued := sessionRepos(t, limitConfig(domain.SessionLimitEvictOldest))
+		authService := newTestAuthService(repos)
+
+		first, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+		require.NoError(t, err)
+		second, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+		require.NoError(t, err)
+		repos.refreshTokens.On("RevokeFamily", mock.Anything, (*issued)[0].FamilyID).Return(nil).Once()
+
+		third, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+		require.NoError(t, err)
+
+		_, err = authService.VerifyToken(ctx, first.AccessToken)
+		assert.Error(t, err)
+		_, err = authService.VerifyToken(ctx, second.AccessToken)
+		assert.NoError(t, err)
+		sessions, err := authService.ListSessions(ctx, third.AccessToken)
+		require.NoError(t, err)
+		assert.Len(t, sessions, 2)
+		repos.refreshTokens.AssertExpectations(t)
+	})
+
+	t.Run("Concurrent logins cannot exceed the limit", func(t *testing.T) {
+		repos, _, issued := sessionRepos(t, limitConfig(domain.SessionLimitReject))
+		repos.store = slowStore{Store: store.NewMemoryStore()}
+		authService := newTestAuthService(repos)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, *issued, 2)
+	})
+}
+
+func TestMultiTenantAuthService_VerifyToken_RoleChange(t *testing.T) {
+	ctx := context.Background()
+	repos, user, _ := sessionRepos(t, testLoginConfig())
+	permissionCache := NewMockCache()
+	repos.permissions = service.NewPermissionService(nil, nil, nil, nil, permissionCache, logger.NewLogger())
+	authService := newTestAuthService(repos)
+
+	login, err := authService.Login(ctx, testEmail, testPassword, testTenantID, testIP)
+	require.NoError(t, err)
+
+	before, err := authService.VerifyToken(ctx, login.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"member"}, before.Roles)
+	assert.Equal(t, []string{"user.read"}, before.Permissions)
+
+	membership := testMembership(user, "member")
+	repos.userTenants.ExpectedCalls = nil
+	repos.userTenants.On("FindByUserAndTenant", mock.Anything, user.ID.Hex(), testTenantID).Return(membership, nil)
+	repos.userTenants.On("UpdateRoles", mock.Anything, user.ID.Hex(), testTenantID, []string{"viewer"}).Run(func(args mock.Arguments) {
+		membership.Roles = args.Get(3).([]string)
+	}).Return(nil).Once()
+	repos.roles.On("GetPermissionsForRoles", mock.Anything, []string{"viewer"}, testTenantID).Return([]string{"report.read"}, nil)
+	permissionCache.On("Delete", mock.Anything, "permissions:"+user.ID.Hex()+":"+testTenantID).Return(nil).Once()
+	permissionCache.On("Delete", mock.Anything, "roles:"+user.ID.Hex()+":"+testTenantID).Return(nil).Once()
+
+	require.NoError(t, authService.AddUserToTenant(ctx, user.ID.Hex(), testTenantID, []string{"viewer"}))
+
+	// The session issued before the change gets the new roles on its next verification
+	after, err := authService.VerifyToken(ctx, login.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"viewer"}, after.Roles)
+	assert.Equal(t, []string{"report.read"}, after.Permissions)
+	permissionCache.AssertExpectations(t)
+	repos.userTenants.AssertExpectations(t)
+}
+
+// failingStore is a session store that cannot be reached
+type failingStore struct {
+	store.Store
+}
+
+func (failingStore) Get(ctx context.Context, key string, value interface{}) error {
+	return fmt.Errorf("store: connection refused")
+}
+
+func TestMultiTenantAuthService_VerifyToken_StoreFailure(t *testing.T) {
+	ctx := context.Background()
+	repos, _, _ := sessionRepos(t, testLoginConfig())
+	repos.store = failingStore{store.NewMemoryStore()}
+	authService := newTestAuthService(repos)
+
+	// A token that cannot be checked is not reported as invalid
+	_, err := authService.VerifyToken(ctx, "some-token")
+
+	require.Error(t, err)
+	assert.Equal(t, "Failed to verify token", err.Error())
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/notification"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
+	"github.com/vhvplatform/go-shared/errors"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	verificationTTL             = 30 * time.Minute
+	verificationCodeDigits      = 6
+	maxVerificationAttempts     = 5
+	maxVerificationsPerHour     = 5
+	verificationRateLimitWindow = time.Hour
+)
+
+// SendVerification sends a verification code and link to an email address or phone number.
+// It returns nil whether or not the identifier belongs to an account, so callers cannot probe for accounts.
+func (s *MultiTenantAuthService) SendVerification(ctx context.Context, identifier, tenantID string) error {
+	user, err := s.userRepo.FindByIdentifier(ctx, identifier)
+	if err != nil {
+		s.logger.Error("Failed to find user for verification", zap.Error(err))
+		return nil
+	}
+	if user == nil || !user.IsActive {
+		return nil
+	}
+
+	identifierType := domain.DetectIdentifierType(identifier, user)
+	if identifierVerified(user, identifierType) {
+		return nil
+	}
+
+	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, user.ID.Hex(), tenantID)
+	if err != nil || userTenant == nil || !userTenant.IsActive {
+		return nil
+	}
+
+	if err := s.sendVerification(ctx, user, tenantID, identifierType); err != nil {
+		s.logger.Warn("Failed to send verification",
+			zap.String("user_id", user.ID.Hex()),
+			zap.String("identifier_type", string(identifierType)),
+			zap.Error(err))
+	}
+	return nil
+}
+
+// VerifyIdentifier confirms an email address or phone number with either the code sent to it
+// or the token from the verification link, and marks it verified on the user
+func (s *MultiTenantAuthService) VerifyIdentifier(ctx context.Context, identifier, code, linkToken string) error {
+	var verification *domain.IdentifierVerification
+	var err error
+
+	switch {
+	case linkToken != "":
+		verification, err = s.verificationRepo.FindPendingByLinkToken(ctx, s.tokenHasher.Hash(linkToken))
+		if err != nil {
+			s.logger.Error("Failed to find verification", zap.Error(err))
+			return errors.Internal("Failed to verify identifier")
+		}
+		if verification == nil {
+			return errors.BadRequest("Invalid or expired verification link")
+		}
+
+	case identifier != "" && code != "":
+		verification, err = s.verificationRepo.FindPending(ctx, identifier)
+		if err != nil {
+			s.logger.Error("Failed to find verification", zap.Error(err))
+			return errors.Internal("Failed to verify identifier")
+		}
+		if verification == nil {
+			return errors.BadRequest("Invalid or expired verification code")
+		}
+		claimed, err := s.verificationRepo.ClaimAttempt(ctx, verification.ID, maxVerificationAttempts)
+		if err != nil {
+			s.logger.Error("Failed to count verification attempt", zap.Error(err))
+			return errors.Internal("Failed to verify identifier")
+		}
+		if !claimed || s.tokenHasher.Hash(code) != verification.CodeHash {
+			return errors.BadRequest("Invalid or expired verification code")
+		}
+
+	default:
+		return errors.BadRequest("Verification code or link token is required")
+	}
+
+	used, err := s.verificationRepo.MarkUsed(ctx, verification.ID)
+	if err != nil {
+		s.logger.Error("Failed to mark verification used", zap.Error(err))
+		return errors.Internal("Failed to verify identifier")
+	}
+	if !used {
+		return errors.BadRequest("Verification was already used")
+	}
+
+	// The identifier may have changed since the code was sent
+	matched, err := s.userRepo.MarkIdentifierVerified(ctx, verification.UserID, verification.IdentifierType, verification.Identifier)
+	if err != nil {
+		s.logger.Error("Failed to mark identifier verified", zap.Error(err))
+		return errors.Internal("Failed to verify identifier")
+	}
+	if !matched {
+		return errors.BadRequest("Identifier no longer belongs to this account")
+	}
+
+	s.logger.Info("Identifier verified",
+		zap.String("user_id", verification.UserID),
+		zap.String("identifier_type", string(verification.IdentifierType)))
+
+	return nil
+}
+
+// sendRegistrationVerifications sends verifications for the email and phone of a new user
+func (s *MultiTenantAuthService) sendRegistrationVerifications(ctx context.Context, user *domain.User, tenantID string) {
+	for _, identifierType := range []domain.IdentifierType{domain.IdentifierTypeEmail, domain.IdentifierTypePhone} {
+		if identifierValue(user, identifierType) == "" {
+			continue
+		}
+		if err := s.sendVerification(ctx, user, tenantID, identifierType); err != nil {
+			s.logger.Warn("Failed to send verification",
+				zap.String("user_id", user.ID.Hex()),
+				zap.String("identifier_type", string(identifierType)),
+				zap.Error(err))
+		}
+	}
+}
+
+// sendVerification creates a verification for the user's email or phone and sends its code and link token.
+// Earlier verifications of the same identifier stop working.
+func (s *MultiTenantAuthService) sendVerification(ctx context.Context, user *domain.User, tenantID string, identifierType domain.IdentifierType) error {
+	identifier := identifierValue(user, identifierType)
+	if identifier == "" {
+		return errors.BadRequest("Identifier cannot be verified")
+	}
+
+	sent, err := s.verificationRepo.CountSince(ctx, identifier, time.Now().Add(-verificationRateLimitWindow))
+	if err != nil {
+		return err
+	}
+	if sent >= maxVerificationsPerHour {
+		return errors.Forbidden("Too many verification requests, try again later")
+	}
+
+	code, err := authutils.GenerateNumericCode(verificationCodeDigits)
+	if err != nil {
+		return err
+	}
+	linkToken, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verificationRepo.InvalidateForIdentifier(ctx, identifier); err != nil {
+		return err
+	}
+
+	verification := &domain.IdentifierVerification{
+		UserID:         user.ID.Hex(),
+		TenantID:       tenantID,
+		IdentifierType: identifierType,
+		Identifier:     identifier,
+		CodeHash:       s.tokenHasher.Hash(code),
+		LinkTokenHash:  s.tokenHasher.Hash(linkToken),
+		ExpiresAt:      time.Now().Add(verificationTTL),
+	}
+	if err := s.verificationRepo.Create(ctx, verification); err != nil {
+		return err
+	}
+
+	channel := notification.ChannelEmail
+	if identifierType == domain.IdentifierTypePhone {
+		channel = notification.ChannelSMS
+	}
+
+	return s.notifier.Send(ctx, &notification.Message{
+		Channel:  channel,
+		To:       identifier,
+		Template: notification.TemplateVerification,
+		Data: map[string]string{
+			"code":       code,
+			"token":      linkToken,
+			"tenant_id":  tenantID,
+			"expires_at": verification.ExpiresAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// identifierValue returns the user's email or phone for the given type, or "" for types that cannot be verified
+func identifierValue(user *domain.User, identifierType domain.IdentifierType) string {
+	switch identifierType {
+	case domain.IdentifierTypeEmail:
+		return user.Email
+	case domain.IdentifierTypePhone:
+		return user.Phone
+	default:
+		return ""
+	}
+}
+
+// identifierVerified reports whether a login identifier is verified.
+// Usernames and document numbers have no verification step and always count as verified.
+func identifierVerified(user *domain.User, identifierType domain.IdentifierType) bool {
+	switch identifierType {
+	case domain.IdentifierTypeEmail:
+		return user.EmailVerified
+	case domain.IdentifierTypePhone:
+		return user.PhoneVerified
+	default:
+		return true
+	}
+}
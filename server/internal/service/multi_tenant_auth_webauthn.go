@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/webauthn"
+	"github.com/vhvplatform/go-shared/errors"
+	"github.com/vhvplatform/go-shared/utils"
+	"go.uber.org/zap"
+)
+
+// defaultPasskeyName names passkeys registered without a name
+const defaultPasskeyName = "Passkey"
+
+// BeginPasskeyRegistration starts registering a passkey for the user behind an access token,
+// with the relying party of the token's tenant
+func (s *MultiTenantAuthService) BeginPasskeyRegistration(ctx context.Context, accessToken string) (*domain.PasskeyCeremony, error) {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if session.ServiceAccount {
+		return nil, errors.Forbidden("Service accounts cannot register passkeys")
+	}
+	rp, _, err := s.relyingParty(ctx, session.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, session.UserID)
+	if err != nil || user == nil {
+		return nil, errors.Unauthorized("User not found")
+	}
+	existing, err := s.webauthnCredentialRepo.FindByUser(ctx, session.UserID, rp.ID)
+	if err != nil {
+		s.logger.Error("Failed to list passkeys", zap.Error(err))
+		return nil, errors.Internal("Failed to start passkey registration")
+	}
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(existing))
+	for _, credential := range existing {
+		id, err := base64.RawURLEncoding.DecodeString(credential.CredentialID)
+		if err != nil {
+			continue
+		}
+		exclude = append(exclude, webauthn.CredentialDescriptor{Type: "public-key", ID: id, Transports: credential.Transports})
+	}
+
+	challenge, token, err := s.createPasskeyChallenge(ctx, session.UserID, session.TenantID, rp.ID)
+	if err != nil {
+		return nil, err
+	}
+	name := totpAccountName(user)
+	return &domain.PasskeyCeremony{
+		Token:   token,
+		Options: rp.CreationOptions(challenge, []byte(session.UserID), name, name, exclude),
+	}, nil
+}
+
+// FinishPasskeyRegistration verifies the browser's new credential and stores it
+func (s *MultiTenantAuthService) FinishPasskeyRegistration(ctx context.Context, accessToken string, req *domain.FinishPasskeyRegistrationRequest) (*domain.WebAuthnCredential, error) {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.takePasskeyChallenge(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID != session.UserID || challenge.TenantID != session.TenantID {
+		return nil, errors.Unauthorized("Invalid or expired passkey token")
+	}
+	rp, _, err := s.relyingParty(ctx, session.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if rp.ID != challenge.RPID {
+		return nil, errors.Unauthorized("Invalid or expired passkey token")
+	}
+
+	var response webauthn.RegistrationResponse
+	if err := json.Unmarshal(req.Credential, &response); err != nil {
+		return nil, errors.BadRequest("Invalid passkey credential")
+	}
+	verified, err := rp.VerifyRegistration(challenge.Challenge, &response)
+	if err != nil {
+		return nil, errors.BadRequest(fmt.Sprintf("Passkey registration failed: %v", err))
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(verified.ID)
+	existing, err := s.webauthnCredentialRepo.FindByCredentialID(ctx, credentialID)
+	if err != nil {
+		s.logger.Error("Failed to find passkey", zap.Error(err))
+		return nil, errors.Internal("Failed to register passkey")
+	}
+	if existing != nil {
+		return nil, errors.Conflict("Passkey already registered")
+	}
+
+	name := req.Name
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	credential := &domain.WebAuthnCredential{
+		UserID:       session.UserID,
+		RPID:         rp.ID,
+		CredentialID: credentialID,
+		PublicKey:    verified.PublicKey,
+		SignCount:    verified.SignCount,
+		Transports:   verified.Transports,
+		Name:         name,
+	}
+	if err := s.webauthnCredentialRepo.Create(ctx, credential); err != nil {
+		s.logger.Error("Failed to create passkey", zap.Error(err))
+		return nil, errors.Internal("Failed to register passkey")
+	}
+
+	s.logger.Info("Passkey registered",
+		zap.String("user_id", session.UserID),
+		zap.String("tenant_id", session.TenantID),
+		zap.String("rp_id", rp.ID))
+
+	return credential, nil
+}
+
+// BeginPasskeyLogin starts a passwordless login to a tenant. No user is named: the browser offers
+// the passkeys it holds for the tenant's relying party.
+func (s *MultiTenantAuthService) BeginPasskeyLogin(ctx context.Context, tenantID string) (*domain.PasskeyCeremony, error) {
+	rp, _, err := s.relyingParty(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, token, err := s.createPasskeyChallenge(ctx, "", tenantID, rp.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.PasskeyCeremony{
+		Token:   token,
+		Options: rp.RequestOptions(challenge, nil),
+	}, nil
+}
+
+// FinishPasskeyLogin verifies the browser's assertion and signs the passkey's user in. A passkey
+// that verified the user (PIN or biometrics) satisfies two-factor authentication on its own;
+// otherwise the usual second factor is asked for.
+func (s *MultiTenantAuthService) FinishPasskeyLogin(ctx context.Context, req *domain.FinishPasskeyLoginRequest) (*domain.LoginResponse, error) {
+	challenge, err := s.takePasskeyChallenge(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID != "" || challenge.TenantID != req.TenantID {
+		return nil, errors.Unauthorized("Invalid or expired passkey token")
+	}
+	rp, loginConfig, err := s.relyingParty(ctx, req.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if rp.ID != challenge.RPID {
+		return nil, errors.Unauthorized("Invalid or expired passkey token")
+	}
+
+	var response webauthn.AssertionResponse
+	if err := json.Unmarshal(req.Credential, &response); err != nil {
+		return nil, errors.BadRequest("Invalid passkey credential")
+	}
+	credential, err := s.webauthnCredentialRepo.FindByCredentialID(ctx, base64.RawURLEncoding.EncodeToString(response.RawID))
+	if err != nil {
+		s.logger.Error("Failed to find passkey", zap.Error(err))
+		return nil, errors.Internal("Failed to verify passkey")
+	}
+	if credential == nil || credential.RPID != rp.ID {
+		return nil, errors.Unauthorized("Passkey not recognized")
+	}
+	if len(response.Response.UserHandle) > 0 && string(response.Response.UserHandle) != credential.UserID {
+		return nil, errors.Unauthorized("Passkey not recognized")
+	}
+
+	assertion, err := rp.VerifyAssertion(challenge.Challenge, credential.PublicKey, credential.SignCount, &response)
+	if err != nil {
+		if err == webauthn.ErrSignCount {
+			s.logger.Warn("Passkey signature counter went backwards",
+				zap.String("user_id", credential.UserID),
+				zap.String("credential_id", credential.ID.Hex()))
+		}
+		return nil, errors.Unauthorized("Passkey verification failed")
+	}
+	if err := s.webauthnCredentialRepo.UpdateSignCount(ctx, credential.ID, assertion.SignCount); err != nil {
+		s.logger.Warn("Failed to update passkey", zap.Error(err))
+	}
+
+	user, err := s.userRepo.FindByID(ctx, credential.UserID)
+	if err != nil || user == nil {
+		return nil, errors.Unauthorized("Passkey not recognized")
+	}
+	if !user.IsActive {
+		return nil, errors.Forbidden("User account is deactivated")
+	}
+	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, credential.UserID, req.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if userTenant == nil {
+		return nil, errors.Forbidden("User does not have access to this tenant")
+	}
+	if !userTenant.IsActive {
+		return nil, errors.Forbidden("User access to this tenant is deactivated")
+	}
+
+	var loginResponse *domain.LoginResponse
+	if assertion.UserVerified {
+		loginResponse, err = s.completeLogin(ctx, user, userTenant, loginConfig, "", "")
+	} else {
+		loginResponse, err = s.startSession(ctx, user, userTenant, loginConfig, "", "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("User logged in successfully",
+		zap.String("user_id", credential.UserID),
+		zap.String("tenant_id", req.TenantID),
+		zap.String("identifier_type", string(domain.IdentifierTypePasskey)),
+		zap.Bool("mfa_required", loginResponse.MFARequired))
+
+	return loginResponse, nil
+}
+
+// ListPasskeys lists the passkeys the user behind an access token can use in the token's tenant
+func (s *MultiTenantAuthService) ListPasskeys(ctx context.Context, accessToken string) ([]*domain.WebAuthnCredential, error) {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.tenantLoginConfigRepo.FindByTenant(ctx, session.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if config.WebAuthnRPID == "" {
+		return []*domain.WebAuthnCredential{}, nil
+	}
+
+	credentials, err := s.webauthnCredentialRepo.FindByUser(ctx, session.UserID, config.WebAuthnRPID)
+	if err != nil {
+		s.logger.Error("Failed to list passkeys", zap.Error(err))
+		return nil, errors.Internal("Failed to list passkeys")
+	}
+	return credentials, nil
+}
+
+// DeletePasskey removes one of the passkeys of the user behind an access token
+func (s *MultiTenantAuthService) DeletePasskey(ctx context.Context, accessToken, passkeyID string) error {
+	session, err := s.sessionForToken(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.webauthnCredentialRepo.Delete(ctx, session.UserID, passkeyID)
+	if err != nil {
+		s.logger.Error("Failed to delete passkey", zap.Error(err))
+		return errors.Internal("Failed to delete passkey")
+	}
+	if !deleted {
+		return errors.NotFound("Passkey not found")
+	}
+
+	s.logger.Info("Passkey deleted", zap.String("user_id", session.UserID), zap.String("passkey_id", passkeyID))
+	return nil
+}
+
+// relyingParty returns a tenant's WebAuthn relying party, once the tenant allows passkey login and has configured it
+func (s *MultiTenantAuthService) relyingParty(ctx context.Context, tenantID string) (*webauthn.RelyingParty, *domain.TenantLoginConfig, error) {
+	config, err := s.tenantLoginConfigRepo.FindByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !identifierAllowed(config, domain.IdentifierTypePasskey) {
+		return nil, nil, errors.Forbidden(fmt.Sprintf("Login with %s is not allowed for this tenant", domain.IdentifierTypePasskey))
+	}
+	if config.WebAuthnRPID == "" || len(config.WebAuthnOrigins) == 0 {
+		return nil, nil, errors.Forbidden("Passkeys are not configured for this tenant")
+	}
+
+	name := config.WebAuthnRPName
+	if name == "" {
+		name = config.WebAuthnRPID
+	}
+	return &webauthn.RelyingParty{ID: config.WebAuthnRPID, Name: name, Origins: config.WebAuthnOrigins}, config, nil
+}
+
+// createPasskeyChallenge stores a ceremony challenge and returns it with the token that identifies it
+func (s *MultiTenantAuthService) createPasskeyChallenge(ctx context.Context, userID, tenantID, rpID string) ([]byte, string, error) {
+	if s.store == nil {
+		return nil, "", errors.Internal("Session store not available")
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, "", errors.Internal("Failed to generate passkey challenge")
+	}
+	token, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, "", errors.Internal("Failed to generate passkey token")
+	}
+
+	pending := domain.WebAuthnChallenge{
+		Challenge: challenge,
+		UserID:    userID,
+		TenantID:  tenantID,
+		RPID:      rpID,
+		ExpiresAt: time.Now().Add(webauthn.Timeout),
+	}
+	if err := s.store.Set(ctx, passkeyChallengeKey(token), pending, webauthn.Timeout); err != nil {
+		s.logger.Error("Failed to store passkey challenge in Redis", zap.Error(err))
+		return nil, "", errors.Internal("Failed to create passkey challenge")
+	}
+	return challenge, token, nil
+}
+
+// takePasskeyChallenge loads a pending ceremony and deletes it in one step, so each challenge is
+// answered once even by concurrent requests
+func (s *MultiTenantAuthService) takePasskeyChallenge(ctx context.Context, token string) (*domain.WebAuthnChallenge, error) {
+	if s.store == nil {
+		return nil, errors.Internal("Session store not available")
+	}
+
+	var challenge domain.WebAuthnChallenge
+	if err := s.store.Take(ctx, passkeyChallengeKey(token), &challenge); err != nil {
+		return nil, errors.Unauthorized("Invalid or expired passkey token")
+	}
+
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, errors.Unauthorized("Invalid or expired passkey token")
+	}
+	return &challenge, nil
+}
+
+func passkeyChallengeKey(token string) string {
+	return fmt.Sprintf("passkey_challenge:%s", token)
+}
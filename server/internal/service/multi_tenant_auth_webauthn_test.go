@@ -0,0 +1,191 @@
+package service_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"github.com/vhvplatform/go-auth-service/internal/service"
+	"github.com/vhvplatform/go-auth-service/internal/webauthn"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+// testPasskey is a software authenticator holding one ES256 passkey of a user
+type testPasskey struct {
+	credentialID []byte
+	userID       string
+	key          *ecdsa.PrivateKey
+	signCount    uint32
+}
+
+func newTestPasskey(t *testing.T, userID string) *testPasskey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &testPasskey{credentialID: []byte("credential-1"), userID: userID, key: key}
+}
+
+// credential is the passkey as stored after registration
+func (p *testPasskey) credential() *domain.WebAuthnCredential {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	p.key.X.FillBytes(x)
+	p.key.Y.FillBytes(y)
+	// COSE_Key {1: 2 (EC2), 3: -7 (ES256), -1: 1 (P-256), -2: x, -3: y}
+	coseKey := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}
+	coseKey = append(coseKey, x...)
+	coseKey = append(coseKey, 0x22, 0x58, 0x20)
+	coseKey = append(coseKey, y...)
+
+	return &domain.WebAuthnCredential{
+		ID:           primitive.NewObjectID(),
+		UserID:       p.userID,
+		RPID:         testRPID,
+		CredentialID: base64.RawURLEncoding.EncodeToString(p.credentialID),
+		PublicKey:    coseKey,
+		SignCount:    p.signCount,
+	}
+}
+
+// assert answers a login challenge, as PublicKeyCredential.toJSON() serializes it
+func (p *testPasskey) assert(t *testing.T, challenge []byte, userVerified bool) json.RawMessage {
+	p.signCount++
+	clientData, err := json.Marshal(map[string]string{
+		"type":      "webauthn.get",
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    testOrigin,
+	})
+	require.NoError(t, err)
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	flags := byte(0x01) // user present
+	if userVerified {
+		flags |= 0x04
+	}
+	authData := binary.BigEndian.AppendUint32(append(rpIDHash[:], flags), p.signCount)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, p.key, digest[:])
+	require.NoError(t, err)
+
+	response := webauthn.AssertionResponse{ID: base64.RawURLEncoding.EncodeToString(p.credentialID), RawID: p.credentialID, Type: "public-key"}
+	response.Response.ClientDataJSON = clientData
+	response.Response.AuthenticatorData = authData
+	response.Response.Signature = signature
+	response.Response.UserHandle = []byte(p.userID)
+	raw, err := json.Marshal(response)
+	require.NoError(t, err)
+	return raw
+}
+
+// passkeyRepos returns mocks for passkey logins of an active user of the tenant, like sessionRepos,
+// and the user's passkey
+func passkeyRepos(t *testing.T) (testRepos, *testPasskey) {
+	config := testLoginConfig()
+	config.AllowedIdentifiers = append(config.AllowedIdentifiers, string(domain.IdentifierTypePasskey))
+	config.WebAuthnRPID = testRPID
+	config.WebAuthnOrigins = []string{testOrigin}
+	repos, user, _ := sessionRepos(t, config)
+
+	passkey := newTestPasskey(t, user.ID.Hex())
+	repos.passkeys = &MockWebAuthnCredentialRepository{}
+	repos.passkeys.On("FindByCredentialID", mock.Anything, base64.RawURLEncoding.EncodeToString(passkey.credentialID)).Return(passkey.credential(), nil)
+	repos.passkeys.On("FindByCredentialID", mock.Anything, mock.Anything).Return(nil, nil)
+	repos.passkeys.On("UpdateSignCount", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	return repos, passkey
+}
+
+// beginPasskeyLogin starts a login and returns its token and challenge
+func beginPasskeyLogin(t *testing.T, authService *service.MultiTenantAuthService) (string, []byte) {
+	ceremony, err := authService.BeginPasskeyLogin(context.Background(), testTenantID)
+	require.NoError(t, err)
+	options, ok := ceremony.Options.(*webauthn.RequestOptions)
+	require.True(t, ok)
+	assert.Equal(t, testRPID, options.RPID)
+	return ceremony.Token, options.Challenge
+}
+
+func TestMultiTenantAuthService_FinishPasskeyLogin(t *testing.T) {
+	ctx := context.Background()
+	repos, passkey := passkeyRepos(t)
+	authService := newTestAuthService(repos)
+
+	token, challenge := beginPasskeyLogin(t, authService)
+	req := &domain.FinishPasskeyLoginRequest{Token: token, TenantID: testTenantID, Credential: passkey.assert(t, challenge, true)}
+	resp, err := authService.FinishPasskeyLogin(ctx, req)
+	require.NoError(t, err)
+	require.False(t, resp.MFARequired)
+	verified, err := authService.VerifyToken(ctx, resp.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, passkey.userID, verified.UserID)
+	repos.passkeys.AssertCalled(t, "UpdateSignCount", mock.Anything, mock.Anything, uint32(1))
+
+	// Each challenge is answered once
+	_, err = authService.FinishPasskeyLogin(ctx, req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid or expired passkey token")
+}
+
+func TestMultiTenantAuthService_FinishPasskeyLogin_Rejects(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("other tenant", func(t *testing.T) {
+		repos, passkey := passkeyRepos(t)
+		authService := newTestAuthService(repos)
+		token, challenge := beginPasskeyLogin(t, authService)
+
+		_, err := authService.FinishPasskeyLogin(ctx, &domain.FinishPasskeyLoginRequest{
+			Token:      token,
+			TenantID:   "tenant456",
+			Credential: passkey.assert(t, challenge, true),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid or expired passkey token")
+	})
+
+	t.Run("unknown passkey", func(t *testing.T) {
+		repos, _ := passkeyRepos(t)
+		authService := newTestAuthService(repos)
+		token, challenge := beginPasskeyLogin(t, authService)
+
+		stranger := newTestPasskey(t, "someone-else")
+		stranger.credentialID = []byte("credential-2")
+		_, err := authService.FinishPasskeyLogin(ctx, &domain.FinishPasskeyLoginRequest{
+			Token:      token,
+			TenantID:   testTenantID,
+			Credential: stranger.assert(t, challenge, true),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Passkey not recognized")
+	})
+
+	t.Run("wrong challenge", func(t *testing.T) {
+		repos, passkey := passkeyRepos(t)
+		authService := newTestAuthService(repos)
+		token, _ := beginPasskeyLogin(t, authService)
+		_, otherChallenge := beginPasskeyLogin(t, authService)
+
+		_, err := authService.FinishPasskeyLogin(ctx, &domain.FinishPasskeyLoginRequest{
+			Token:      token,
+			TenantID:   testTenantID,
+			Credential: passkey.assert(t, otherChallenge, true),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Passkey verification failed")
+		repos.passkeys.AssertNotCalled(t, "UpdateSignCount", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/vhvplatform/go-auth-service/internal/repository"
+	authutils "github.com/vhvplatform/go-auth-service/internal/utils"
 	"github.com/vhvplatform/go-shared/auth"
 	"github.com/vhvplatform/go-shared/cache"
 	"github.com/vhvplatform/go-shared/logger"
@@ -14,27 +14,30 @@ import (
 
 // PermissionService handles permission checking and role management
 type PermissionService struct {
-	userRepo       *repository.UserRepository
-	userTenantRepo *repository.UserTenantRepository
-	roleRepo       *repository.RoleRepository
-	cache          cache.Cache
-	logger         *logger.Logger
+	userRepo           UserRepository
+	userTenantRepo     UserTenantFinder
+	roleRepo           RoleRepository
+	serviceAccountRepo ServiceAccountRepository
+	cache              cache.Cache
+	logger             *logger.Logger
 }
 
 // NewPermissionService creates a new permission service
 func NewPermissionService(
-	userRepo *repository.UserRepository,
-	userTenantRepo *repository.UserTenantRepository,
-	roleRepo *repository.RoleRepository,
+	userRepo UserRepository,
+	userTenantRepo UserTenantFinder,
+	roleRepo RoleRepository,
+	serviceAccountRepo ServiceAccountRepository,
 	cacheClient cache.Cache,
 	log *logger.Logger,
 ) *PermissionService {
 	return &PermissionService{
-		userRepo:       userRepo,
-		userTenantRepo: userTenantRepo,
-		roleRepo:       roleRepo,
-		cache:          cacheClient,
-		logger:         log,
+		userRepo:           userRepo,
+		userTenantRepo:     userTenantRepo,
+		roleRepo:           roleRepo,
+		serviceAccountRepo: serviceAccountRepo,
+		cache:              cacheClient,
+		logger:             log,
 	}
 }
 
@@ -60,17 +63,16 @@ func (s *PermissionService) GetUserPermissions(ctx context.Context, userID, tena
 		zap.String("user_id", userID),
 		zap.String("tenant_id", tenantID))
 
-	// Get user-tenant relationship to get roles
-	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, userID, tenantID)
+	roles, err := s.tenantRoles(ctx, userID, tenantID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user-tenant relationship: %w", err)
+		return nil, err
 	}
-	if userTenant == nil || !userTenant.IsActive {
+	if len(roles) == 0 {
 		return []string{}, nil // No permissions if not in tenant
 	}
 
 	// Get permissions for all roles
-	permissions, err := s.roleRepo.GetPermissionsForRoles(ctx, userTenant.Roles, tenantID)
+	permissions, err := s.roleRepo.GetPermissionsForRoles(ctx, roles, tenantID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get permissions: %w", err)
 	}
@@ -93,33 +95,7 @@ func (s *PermissionService) CheckPermission(ctx context.Context, userID, tenantI
 		return false, err
 	}
 
-	// Check for wildcard permission (super admin)
-	for _, perm := range permissions {
-		if perm == "*" {
-			return true, nil
-		}
-		if perm == permission {
-			return true, nil
-		}
-	}
-
-	// Check for wildcard patterns (e.g., "user.*" matches "user.read")
-	permObj, err := auth.ParsePermission(permission)
-	if err != nil {
-		return false, nil
-	}
-
-	for _, perm := range permissions {
-		userPerm, err := auth.ParsePermission(perm)
-		if err != nil {
-			continue
-		}
-		if userPerm.Matches(permObj) {
-			return true, nil
-		}
-	}
-
-	return false, nil
+	return authutils.HasPermission(permissions, permission), nil
 }
 
 // CheckPermissions checks if user has all specified permissions
@@ -174,19 +150,14 @@ func (s *PermissionService) GetUserRoles(ctx context.Context, userID, tenantID s
 	}
 
 	// Cache miss, fetch from database
-	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, userID, tenantID)
+	roles, err := s.tenantRoles(ctx, userID, tenantID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user-tenant relationship: %w", err)
+		return nil, err
 	}
-	if userTenant == nil || !userTenant.IsActive {
+	if len(roles) == 0 {
 		return []string{}, nil
 	}
 
-	roles := userTenant.Roles
-	if roles == nil {
-		roles = []string{}
-	}
-
 	// Cache the result (5 minutes TTL)
 	if s.cache != nil {
 		_ = s.cache.Set(ctx, cacheKey, roles, 5*time.Minute)
@@ -195,6 +166,33 @@ func (s *PermissionService) GetUserRoles(ctx context.Context, userID, tenantID s
 	return roles, nil
 }
 
+// tenantRoles returns the roles of a user in a tenant. An ID that is not a member of the tenant
+// may be one of its service accounts, whose roles are assigned on the account.
+func (s *PermissionService) tenantRoles(ctx context.Context, userID, tenantID string) ([]string, error) {
+	userTenant, err := s.userTenantRepo.FindByUserAndTenant(ctx, userID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user-tenant relationship: %w", err)
+	}
+	if userTenant != nil {
+		if !userTenant.IsActive {
+			return nil, nil
+		}
+		return userTenant.Roles, nil
+	}
+
+	if s.serviceAccountRepo == nil {
+		return nil, nil
+	}
+	account, err := s.serviceAccountRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+	if account == nil || !account.IsActive || account.TenantID != tenantID {
+		return nil, nil
+	}
+	return account.Roles, nil
+}
+
 // HasRole checks if user has a specific role
 func (s *PermissionService) HasRole(ctx context.Context, userID, tenantID, role string) (bool, error) {
 	roles, err := s.GetUserRoles(ctx, userID, tenantID)
@@ -8,7 +8,6 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/vhvplatform/go-auth-service/internal/domain"
-	"github.com/vhvplatform/go-auth-service/internal/repository"
 	"github.com/vhvplatform/go-auth-service/internal/service"
 	"github.com/vhvplatform/go-shared/logger"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -103,6 +102,7 @@ func TestPermissionService_CheckPermission(t *testing.T) {
 		nil, // UserRepository not needed for this test
 		mockUserTenantRepo,
 		mockRoleRepo,
+		nil, // ServiceAccountRepository not needed for this test
 		mockCache,
 		log,
 	)
@@ -234,6 +234,7 @@ func TestPermissionService_CheckPermissions(t *testing.T) {
 		nil,
 		mockUserTenantRepo,
 		mockRoleRepo,
+		nil, // ServiceAccountRepository not needed for this test
 		mockCache,
 		log,
 	)
@@ -284,7 +285,7 @@ func TestPermissionService_CheckPermissions(t *testing.T) {
 		mockCache.On("Set", ctx, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 
 		// Test
-		hasAll, missing, err := permService.CheckPermissions(ctx, userID, tenantID, 
+		hasAll, missing, err := permService.CheckPermissions(ctx, userID, tenantID,
 			[]string{"user.read", "user.write", "user.delete"})
 
 		// Assert
@@ -304,6 +305,7 @@ func TestPermissionService_GetUserPermissions_Cache(t *testing.T) {
 		nil,
 		mockUserTenantRepo,
 		mockRoleRepo,
+		nil, // ServiceAccountRepository not needed for this test
 		mockCache,
 		log,
 	)
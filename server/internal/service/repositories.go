@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/vhvplatform/go-auth-service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The services depend on these interfaces rather than on the MongoDB repositories, so they can be
+// tested with mocks. Each lists the methods of its repository in the repository package.
+
+// UserRepository is the storage of users
+type UserRepository interface {
+	Create(ctx context.Context, user *domain.User) error
+	FindByID(ctx context.Context, id string) (*domain.User, error)
+	FindByIdentifier(ctx context.Context, identifier string) (*domain.User, error)
+	MarkIdentifierVerified(ctx context.Context, userID string, identifierType domain.IdentifierType, identifier string) (bool, error)
+	UpdateLastLogin(ctx context.Context, userID string) error
+	UpdatePassword(ctx context.Context, userID, passwordHash string) error
+}
+
+// UserTenantRepository is the storage of tenant memberships
+type UserTenantRepository interface {
+	UserTenantFinder
+	Create(ctx context.Context, userTenant *domain.UserTenant) error
+	Deactivate(ctx context.Context, userID, tenantID string) error
+	FindByUser(ctx context.Context, userID string) ([]*domain.UserTenant, error)
+	UpdateRoles(ctx context.Context, userID, tenantID string, roles []string) error
+}
+
+// UserTenantFinder looks up the membership of a user in a tenant
+type UserTenantFinder interface {
+	FindByUserAndTenant(ctx context.Context, userID, tenantID string) (*domain.UserTenant, error)
+}
+
+// TenantLoginConfigRepository is the storage of tenant login configurations
+type TenantLoginConfigRepository interface {
+	FindByTenant(ctx context.Context, tenantID string) (*domain.TenantLoginConfig, error)
+}
+
+// RefreshTokenRepository is the storage of refresh tokens
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *domain.RefreshToken) error
+	FindByTokenHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error)
+	MarkRotated(ctx context.Context, id primitive.ObjectID) (bool, error)
+	RevokeAllForUser(ctx context.Context, userID string) error
+	RevokeAllForUserExceptFamily(ctx context.Context, userID, keepFamilyID string) error
+	RevokeFamily(ctx context.Context, familyID string) error
+}
+
+// RoleRepository is the storage of roles
+type RoleRepository interface {
+	FindByNames(ctx context.Context, names []string, tenantID string) ([]*domain.Role, error)
+	GetPermissionsForRoles(ctx context.Context, roles []string, tenantID string) ([]string, error)
+}
+
+// LoginAttemptRepository is the storage of login attempts
+type LoginAttemptRepository interface {
+	CountFailuresByIP(ctx context.Context, tenantID, ipAddress string, since time.Time) (int64, error)
+	CountFailuresByIdentifier(ctx context.Context, tenantID, identifier string, since time.Time) (int64, error)
+	Create(ctx context.Context, attempt *domain.LoginAttempt) error
+}
+
+// UserLockoutRepository is the storage of lockouts
+type UserLockoutRepository interface {
+	Create(ctx context.Context, lockout *domain.UserLockout) error
+	FindActive(ctx context.Context, tenantID, identifier, ipAddress string) (*domain.UserLockout, error)
+	FindLatest(ctx context.Context, tenantID, identifier, ipAddress string) (*domain.UserLockout, error)
+	Release(ctx context.Context, tenantID, identifier, ipAddress string) (int64, error)
+}
+
+// UserMFARepository is the storage of MFA enrollments
+type UserMFARepository interface {
+	ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	Enable(ctx context.Context, userID string, recoveryCodeHashes []string, step int64) error
+	FindByUser(ctx context.Context, userID string) (*domain.UserMFA, error)
+	SetPendingSecret(ctx context.Context, userID, secret string) error
+	UseStep(ctx context.Context, userID string, step int64) (bool, error)
+}
+
+// PasswordResetTokenRepository is the storage of password reset tokens
+type PasswordResetTokenRepository interface {
+	Consume(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error)
+	Create(ctx context.Context, token *domain.PasswordResetToken) error
+	FindValid(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error)
+	InvalidateForUser(ctx context.Context, userID string) error
+}
+
+// IdentifierVerificationRepository is the storage of identifier verifications
+type IdentifierVerificationRepository interface {
+	ClaimAttempt(ctx context.Context, id primitive.ObjectID, maxAttempts int) (bool, error)
+	CountSince(ctx context.Context, identifier string, since time.Time) (int64, error)
+	Create(ctx context.Context, verification *domain.IdentifierVerification) error
+	FindPending(ctx context.Context, identifier string) (*domain.IdentifierVerification, error)
+	FindPendingByLinkToken(ctx context.Context, linkTokenHash string) (*domain.IdentifierVerification, error)
+	InvalidateForIdentifier(ctx context.Context, identifier string) error
+	MarkUsed(ctx context.Context, id primitive.ObjectID) (bool, error)
+}
+
+// OAuthAccountRepository is the storage of linked OAuth accounts
+type OAuthAccountRepository interface {
+	Create(ctx context.Context, account *domain.OAuthAccount) error
+	Delete(ctx context.Context, userID string, provider domain.OAuthProvider) (bool, error)
+	FindByProviderID(ctx context.Context, provider domain.OAuthProvider, providerID string) (*domain.OAuthAccount, error)
+	FindByUser(ctx context.Context, userID string) ([]*domain.OAuthAccount, error)
+}
+
+// OIDCClientRepository is the storage of OIDC clients
+type OIDCClientRepository interface {
+	Create(ctx context.Context, client *domain.OIDCClient) error
+	Delete(ctx context.Context, tenantID, clientID string) (bool, error)
+	FindByClientID(ctx context.Context, clientID string) (*domain.OIDCClient, error)
+	FindByTenant(ctx context.Context, tenantID string) ([]*domain.OIDCClient, error)
+}
+
+// ServiceAccountRepository is the storage of service accounts
+type ServiceAccountRepository interface {
+	Create(ctx context.Context, account *domain.ServiceAccount) error
+	Delete(ctx context.Context, tenantID, id string) (bool, error)
+	FindByClientID(ctx context.Context, clientID string) (*domain.ServiceAccount, error)
+	FindByID(ctx context.Context, id string) (*domain.ServiceAccount, error)
+	FindByTenant(ctx context.Context, tenantID string) ([]*domain.ServiceAccount, error)
+	UpdateLastUsed(ctx context.Context, id primitive.ObjectID) error
+}
+
+// APIKeyRepository is the storage of API keys
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	FindByKeyHash(ctx context.Context, keyHash string) (*domain.APIKey, error)
+	FindByUser(ctx context.Context, userID, tenantID string) ([]*domain.APIKey, error)
+	Revoke(ctx context.Context, userID, id string) (bool, error)
+	UpdateLastUsed(ctx context.Context, id primitive.ObjectID) error
+}
+
+// WebAuthnCredentialRepository is the storage of passkeys
+type WebAuthnCredentialRepository interface {
+	Create(ctx context.Context, credential *domain.WebAuthnCredential) error
+	Delete(ctx context.Context, userID, id string) (bool, error)
+	FindByCredentialID(ctx context.Context, credentialID string) (*domain.WebAuthnCredential, error)
+	FindByUser(ctx context.Context, userID, rpID string) ([]*domain.WebAuthnCredential, error)
+	UpdateSignCount(ctx context.Context, id primitive.ObjectID, signCount uint32) error
+}
+
+// PasswordlessLoginRepository is the storage of passwordless logins
+type PasswordlessLoginRepository interface {
+	ClaimAttempt(ctx context.Context, id primitive.ObjectID, maxAttempts int) (bool, error)
+	CountSince(ctx context.Context, identifier string, since time.Time) (int64, error)
+	Create(ctx context.Context, login *domain.PasswordlessLogin) error
+	FindPending(ctx context.Context, identifier, tenantID string) (*domain.PasswordlessLogin, error)
+	FindPendingByLinkToken(ctx context.Context, linkTokenHash string) (*domain.PasswordlessLogin, error)
+	InvalidateForIdentifier(ctx context.Context, identifier, tenantID string) error
+	MarkUsed(ctx context.Context, id primitive.ObjectID) (bool, error)
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/vhvplatform/go-shared/config"
+)
+
+// DefaultPrefix is prepended to the keys of the auth service
+const DefaultPrefix = "auth:"
+
+// incrementScript starts the expiry of a counter when it is created
+var incrementScript = goredis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// hashSetScript stores fields and extends the expiry of the hash to at least ARGV[1] milliseconds
+var hashSetScript = goredis.NewScript(`
+redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[1]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// deleteIfEqualScript deletes a key if it holds ARGV[1]
+var deleteIfEqualScript = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisStore keeps the state in Redis, under a key prefix
+type RedisStore struct {
+	client *goredis.Client
+	prefix string
+}
+
+// NewRedisStore creates a store on a Redis client
+func NewRedisStore(client *goredis.Client, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// DialRedis creates a store with DefaultPrefix on the configured Redis server.
+// The connection is made when the store is first used.
+func DialRedis(cfg config.RedisConfig) *RedisStore {
+	client := goredis.NewClient(&goredis.Options{
+		Addr:     cfg.GetRedisAddr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return NewRedisStore(client, DefaultPrefix)
+}
+
+// Close closes the connection to Redis
+func (r *RedisStore) Close() error {
+	return r.client.Close()
+}
+
+// Get decodes the value of a key into value
+func (r *RedisStore) Get(ctx context.Context, key string, value interface{}) error {
+	data, err := r.client.Get(ctx, r.prefix+key).Result()
+	if err != nil {
+		return redisError(err)
+	}
+	return decode(data, value)
+}
+
+// Set stores a value for ttl
+func (r *RedisStore) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := encode(value)
+	if err != nil {
+		return err
+	}
+	return redisError(r.client.Set(ctx, r.prefix+key, data, ttl).Err())
+}
+
+// SetNX stores a value for ttl unless the key exists
+func (r *RedisStore) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	data, err := encode(value)
+	if err != nil {
+		return false, err
+	}
+	stored, err := r.client.SetNX(ctx, r.prefix+key, data, ttl).Result()
+	return stored, redisError(err)
+}
+
+// Take decodes the value of a key into value and deletes the key
+func (r *RedisStore) Take(ctx context.Context, key string, value interface{}) error {
+	data, err := r.client.GetDel(ctx, r.prefix+key).Result()
+	if err != nil {
+		return redisError(err)
+	}
+	return decode(data, value)
+}
+
+// Delete deletes keys
+func (r *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.prefix + key
+	}
+	return redisError(r.client.Del(ctx, prefixed...).Err())
+}
+
+// DeleteIfEqual deletes a key if it holds value
+func (r *RedisStore) DeleteIfEqual(ctx context.Context, key string, value interface{}) (bool, error) {
+	data, err := encode(value)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := deleteIfEqualScript.Run(ctx, r.client, []string{r.prefix + key}, data).Int64()
+	return deleted == 1, redisError(err)
+}
+
+// Increment adds one to a counter and returns the new count
+func (r *RedisStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	count, err := incrementScript.Run(ctx, r.client, []string{r.prefix + key}, ttl.Milliseconds()).Int64()
+	return count, redisError(err)
+}
+
+// HashSet stores fields of a hash and keeps the hash for at least ttl
+func (r *RedisStore) HashSet(ctx context.Context, key string, fields map[string]interface{}, ttl time.Duration) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	args := []interface{}{ttl.Milliseconds()}
+	for field, value := range fields {
+		data, err := encode(value)
+		if err != nil {
+			return err
+		}
+		args = append(args, field, data)
+	}
+	return redisError(hashSetScript.Run(ctx, r.client, []string{r.prefix + key}, args...).Err())
+}
+
+// HashGetAll returns the JSON values of all fields of a hash
+func (r *RedisStore) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	fields, err := r.client.HGetAll(ctx, r.prefix+key).Result()
+	return fields, redisError(err)
+}
+
+// HashDelete deletes fields of a hash
+func (r *RedisStore) HashDelete(ctx context.Context, key string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return redisError(r.client.HDel(ctx, r.prefix+key, fields...).Err())
+}
+
+// redisError maps a missing key to ErrNotFound
+func redisError(err error) error {
+	if errors.Is(err, goredis.Nil) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// Package store keeps the short-lived state of the auth service, such as sessions, challenges
+// and attempt counters, in Redis. Values are stored as JSON.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when a key does not exist or has expired
+var ErrNotFound = errors.New("store: key not found")
+
+// Store is a key-value store with expiring keys. Every method is atomic, so replicas of the
+// auth service can share one store.
+type Store interface {
+	// Get decodes the value of a key into value
+	Get(ctx context.Context, key string, value interface{}) error
+	// Set stores a value for ttl
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	// SetNX stores a value for ttl unless the key exists, and reports whether it was stored
+	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
+	// Take decodes the value of a key into value and deletes the key. Only one caller gets it.
+	Take(ctx context.Context, key string, value interface{}) error
+	// Delete deletes keys
+	Delete(ctx context.Context, keys ...string) error
+	// DeleteIfEqual deletes a key if it holds value, and reports whether it was deleted
+	DeleteIfEqual(ctx context.Context, key string, value interface{}) (bool, error)
+	// Increment adds one to a counter and returns the new count. A new counter expires after ttl.
+	Increment(ctx context.Context, key string, ttl time.Duration) (int64, error)
+
+	// HashSet stores fields of a hash and keeps the hash for at least ttl
+	HashSet(ctx context.Context, key string, fields map[string]interface{}, ttl time.Duration) error
+	// HashGetAll returns the JSON values of all fields of a hash
+	HashGetAll(ctx context.Context, key string) (map[string]string, error)
+	// HashDelete deletes fields of a hash
+	HashDelete(ctx context.Context, key string, fields ...string) error
+}
+
+// encode returns the JSON of a value
+func encode(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("store: failed to encode value: %w", err)
+	}
+	return string(data), nil
+}
+
+// decode parses the JSON of a value
+func decode(data string, value interface{}) error {
+	if err := json.Unmarshal([]byte(data), value); err != nil {
+		return fmt.Errorf("store: failed to decode value: %w", err)
+	}
+	return nil
+}
+
+// MemoryStore keeps the state within the process. It stands in for Redis in tests and when a
+// single instance of the auth service runs.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value     string
+	fields    map[string]string
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates an in-process store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry), now: time.Now}
+}
+
+// SetClock replaces the clock keys expire by, for tests
+func (m *MemoryStore) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+// entry returns a live entry. The caller holds the lock.
+func (m *MemoryStore) entry(key string) *memoryEntry {
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+	if !entry.expiresAt.IsZero() && !m.now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// Get decodes the value of a key into value
+func (m *MemoryStore) Get(ctx context.Context, key string, value interface{}) error {
+	m.mu.Lock()
+	entry := m.entry(key)
+	m.mu.Unlock()
+	if entry == nil || entry.fields != nil {
+		return ErrNotFound
+	}
+	return decode(entry.value, value)
+}
+
+// Set stores a value for ttl
+func (m *MemoryStore) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := encode(value)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = &memoryEntry{value: data, expiresAt: m.now().Add(ttl)}
+	return nil
+}
+
+// SetNX stores a value for ttl unless the key exists
+func (m *MemoryStore) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	data, err := encode(value)
+	if err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entry(key) != nil {
+		return false, nil
+	}
+	m.entries[key] = &memoryEntry{value: data, expiresAt: m.now().Add(ttl)}
+	return true, nil
+}
+
+// Take decodes the value of a key into value and deletes the key
+func (m *MemoryStore) Take(ctx context.Context, key string, value interface{}) error {
+	m.mu.Lock()
+	entry := m.entry(key)
+	if entry != nil && entry.fields == nil {
+		delete(m.entries, key)
+	}
+	m.mu.Unlock()
+	if entry == nil || entry.fields != nil {
+		return ErrNotFound
+	}
+	return decode(entry.value, value)
+}
+
+// Delete deletes keys
+func (m *MemoryStore) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// DeleteIfEqual deletes a key if it holds value
+func (m *MemoryStore) DeleteIfEqual(ctx context.Context, key string, value interface{}) (bool, error) {
+	data, err := encode(value)
+	if err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.entry(key)
+	if entry == nil || entry.fields != nil || entry.value != data {
+		return false, nil
+	}
+	delete(m.entries, key)
+	return true, nil
+}
+
+// Increment adds one to a counter and returns the new count
+func (m *MemoryStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.entry(key)
+	if entry == nil {
+		entry = &memoryEntry{value: "0", expiresAt: m.now().Add(ttl)}
+		m.entries[key] = entry
+	}
+	var count int64
+	if entry.fields != nil || decode(entry.value, &count) != nil {
+		return 0, fmt.Errorf("store: %s is not a counter", key)
+	}
+	count++
+	entry.value = fmt.Sprint(count)
+	return count, nil
+}
+
+// HashSet stores fields of a hash and keeps the hash for at least ttl
+func (m *MemoryStore) HashSet(ctx context.Context, key string, fields map[string]interface{}, ttl time.Duration) error {
+	values := make(map[string]string, len(fields))
+	for field, value := range fields {
+		data, err := encode(value)
+		if err != nil {
+			return err
+		}
+		values[field] = data
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.entry(key)
+	if entry == nil {
+		entry = &memoryEntry{fields: make(map[string]string)}
+		m.entries[key] = entry
+	}
+	if entry.fields == nil {
+		return fmt.Errorf("store: %s is not a hash", key)
+	}
+	for field, value := range values {
+		entry.fields[field] = value
+	}
+	if expiresAt := m.now().Add(ttl); expiresAt.After(entry.expiresAt) {
+		entry.expiresAt = expiresAt
+	}
+	return nil
+}
+
+// HashGetAll returns the JSON values of all fields of a hash
+func (m *MemoryStore) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fields := make(map[string]string)
+	if entry := m.entry(key); entry != nil {
+		for field, value := range entry.fields {
+			fields[field] = value
+		}
+	}
+	return fields, nil
+}
+
+// HashDelete deletes fields of a hash
+func (m *MemoryStore) HashDelete(ctx context.Context, key string, fields ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.entry(key)
+	if entry == nil || entry.fields == nil {
+		return nil
+	}
+	for _, field := range fields {
+		delete(entry.fields, field)
+	}
+	if len(entry.fields) == 0 {
+		delete(m.entries, key)
+	}
+	return nil
+}
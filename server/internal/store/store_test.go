@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_Values(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryStore()
+	store.SetClock(func() time.Time { return now })
+
+	var value map[string]string
+	assert.ErrorIs(t, store.Get(ctx, "a", &value), ErrNotFound)
+
+	require.NoError(t, store.Set(ctx, "a", map[string]string{"k": "v"}, time.Minute))
+	require.NoError(t, store.Get(ctx, "a", &value))
+	assert.Equal(t, "v", value["k"])
+
+	stored, err := store.SetNX(ctx, "a", "other", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, stored)
+
+	now = now.Add(time.Minute)
+	assert.ErrorIs(t, store.Get(ctx, "a", &value), ErrNotFound)
+	stored, err = store.SetNX(ctx, "a", "other", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, stored)
+
+	var taken string
+	require.NoError(t, store.Take(ctx, "a", &taken))
+	assert.Equal(t, "other", taken)
+	assert.ErrorIs(t, store.Take(ctx, "a", &taken), ErrNotFound)
+}
+
+func TestMemoryStore_DeleteIfEqual(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	deleted, err := store.DeleteIfEqual(ctx, "lock", "owner-1")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	require.NoError(t, store.Set(ctx, "lock", "owner-2", time.Minute))
+	deleted, err = store.DeleteIfEqual(ctx, "lock", "owner-1")
+	require.NoError(t, err)
+	assert.False(t, deleted, "a key holding another value is kept")
+	var owner string
+	require.NoError(t, store.Get(ctx, "lock", &owner))
+
+	deleted, err = store.DeleteIfEqual(ctx, "lock", "owner-2")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.ErrorIs(t, store.Get(ctx, "lock", &owner), ErrNotFound)
+}
+
+func TestMemoryStore_Increment(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryStore()
+	store.SetClock(func() time.Time { return now })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Increment(ctx, "counter", time.Minute)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// Incrementing does not extend the expiry
+	now = now.Add(30 * time.Second)
+	count, err := store.Increment(ctx, "counter", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), count)
+
+	now = now.Add(30 * time.Second)
+	count, err = store.Increment(ctx, "counter", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestMemoryStore_Hash(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryStore()
+	store.SetClock(func() time.Time { return now })
+
+	require.NoError(t, store.HashSet(ctx, "h", map[string]interface{}{"a": 1, "b": "two"}, time.Hour))
+	require.NoError(t, store.HashSet(ctx, "h", map[string]interface{}{"c": true}, time.Minute))
+
+	fields, err := store.HashGetAll(ctx, "h")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": `"two"`, "c": "true"}, fields)
+
+	// A shorter ttl does not shorten the hash's expiry
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, store.HashDelete(ctx, "h", "a", "c"))
+	fields, err = store.HashGetAll(ctx, "h")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"b": `"two"`}, fields)
+
+	now = now.Add(time.Hour)
+	fields, err = store.HashGetAll(ctx, "h")
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+}
@@ -0,0 +1,52 @@
+package utils
+
+import "strings"
+
+// Browser and OS tokens in the order they are checked. Order matters: Edge and Opera user agents
+// also contain "Chrome/", Chrome user agents contain "Safari/", and iOS and Android user agents
+// contain "Mac OS X" and "Linux".
+var (
+	browserTokens = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"grpc-", "gRPC client"},
+		{"curl/", "curl"},
+	}
+	osTokens = []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// DescribeDevice derives a short device label such as "Chrome on Windows" from a user agent,
+// so users can recognize their sessions. Unknown user agents give an empty label.
+func DescribeDevice(userAgent string) string {
+	browser := matchToken(userAgent, browserTokens)
+	os := matchToken(userAgent, osTokens)
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	default:
+		return os
+	}
+}
+
+func matchToken(userAgent string, tokens []struct{ token, name string }) string {
+	for _, t := range tokens {
+		if strings.Contains(userAgent, t.token) {
+			return t.name
+		}
+	}
+	return ""
+}
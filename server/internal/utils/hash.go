@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// HashToken returns the hex-encoded SHA-256 digest of a secret token,
+// so one-time codes and tokens can be stored and looked up without keeping the plaintext
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenHasher computes keyed hashes of long-lived bearer tokens (refresh tokens, session tokens).
+// Without the key, a leaked hash can neither be used nor checked against guessed tokens.
+type TokenHasher struct {
+	key []byte
+}
+
+// NewTokenHasher creates a hasher for a secret key
+func NewTokenHasher(key string) *TokenHasher {
+	return &TokenHasher{key: []byte(key)}
+}
+
+// Hash returns the hex-encoded HMAC-SHA256 of a token
+func (h *TokenHasher) Hash(token string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateNumericCode returns a random code of the given number of decimal digits, e.g. for SMS
+func GenerateNumericCode(digits int) (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < digits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashToken(t *testing.T) {
+	assert.Equal(t, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", HashToken("test"))
+	assert.NotEqual(t, HashToken("token-a"), HashToken("token-b"))
+}
+
+func TestTokenHasher(t *testing.T) {
+	// RFC 4231 test case 2
+	hasher := NewTokenHasher("Jefe")
+	assert.Equal(t, "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843", hasher.Hash("what do ya want for nothing?"))
+	assert.NotEqual(t, hasher.Hash("token"), NewTokenHasher("other-key").Hash("token"))
+}
+
+func TestGenerateNumericCode(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		code, err := GenerateNumericCode(6)
+		require.NoError(t, err)
+		assert.Len(t, code, 6)
+		for _, c := range code {
+			assert.True(t, c >= '0' && c <= '9', "unexpected character in %q", code)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import "github.com/vhvplatform/go-shared/auth"
+
+// HasPermission reports whether granted permissions include a permission. "*" grants everything
+// and wildcard patterns match within a resource, e.g. "user.*" grants "user.read".
+func HasPermission(granted []string, permission string) bool {
+	for _, perm := range granted {
+		if perm == "*" || perm == permission {
+			return true
+		}
+	}
+
+	required, err := auth.ParsePermission(permission)
+	if err != nil {
+		return false
+	}
+	for _, perm := range granted {
+		grantedPerm, err := auth.ParsePermission(perm)
+		if err != nil {
+			continue
+		}
+		if grantedPerm.Matches(required) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasPermission(t *testing.T) {
+	assert.True(t, HasPermission([]string{"user.read", "user.write"}, "user.write"))
+	assert.True(t, HasPermission([]string{"*"}, "tenant.write"))
+	assert.False(t, HasPermission([]string{"user.read"}, "user.write"))
+	assert.False(t, HasPermission(nil, "user.read"))
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 Appendix B test vectors for HMAC-SHA1
+func TestHOTP_RFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for unix, expected := range vectors {
+		assert.Equal(t, expected, hotp(key, totpStep(time.Unix(unix, 0)), 8), "time %d", unix)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+
+	code, err := GenerateTOTPCode(secret, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "081804", code)
+
+	t.Run("Current step", func(t *testing.T) {
+		step, ok := ValidateTOTPCode(secret, code, now, 1)
+		assert.True(t, ok)
+		assert.Equal(t, int64(1111111109/30), step)
+	})
+
+	t.Run("Previous step within skew", func(t *testing.T) {
+		_, ok := ValidateTOTPCode(secret, code, now.Add(TOTPPeriod), 1)
+		assert.True(t, ok)
+	})
+
+	t.Run("Outside skew", func(t *testing.T) {
+		_, ok := ValidateTOTPCode(secret, code, now.Add(3*TOTPPeriod), 1)
+		assert.False(t, ok)
+	})
+
+	t.Run("Wrong code", func(t *testing.T) {
+		_, ok := ValidateTOTPCode(secret, "000000", now, 1)
+		assert.False(t, ok)
+	})
+
+	t.Run("Lowercase secret", func(t *testing.T) {
+		_, ok := ValidateTOTPCode(strings.ToLower(secret), code, now, 0)
+		assert.True(t, ok)
+	})
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri := TOTPProvisioningURI("tenant_001", "user@example.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/tenant_001:user@example.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "issuer=tenant_001")
+}
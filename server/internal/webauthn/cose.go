@@ -0,0 +1,96 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+)
+
+// COSE algorithms (RFC 9053) and key parameters used by passkeys
+const (
+	algES256 int64 = -7
+	algEdDSA int64 = -8
+	algRS256 int64 = -257
+
+	coseKeyType   int64 = 1
+	coseAlgorithm int64 = 3
+
+	keyTypeOKP int64 = 1
+	keyTypeEC2 int64 = 2
+	keyTypeRSA int64 = 3
+
+	curveP256    int64 = 1
+	curveEd25519 int64 = 6
+)
+
+// parsePublicKey decodes a COSE_Key into its algorithm and public key
+func parsePublicKey(coseKey []byte) (int64, crypto.PublicKey, error) {
+	item, _, err := decodeCBOR(coseKey)
+	if err != nil {
+		return 0, nil, ErrUnsupportedKey
+	}
+	key, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return 0, nil, ErrUnsupportedKey
+	}
+	keyType, _ := key[coseKeyType].(int64)
+	alg, _ := key[coseAlgorithm].(int64)
+	crv, _ := key[int64(-1)].(int64)
+
+	switch {
+	case keyType == keyTypeEC2 && alg == algES256 && crv == curveP256:
+		x, okX := key[int64(-2)].([]byte)
+		y, okY := key[int64(-3)].([]byte)
+		if !okX || !okY || len(x) != 32 || len(y) != 32 {
+			return 0, nil, ErrUnsupportedKey
+		}
+		public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !public.Curve.IsOnCurve(public.X, public.Y) {
+			return 0, nil, ErrUnsupportedKey
+		}
+		return alg, public, nil
+
+	case keyType == keyTypeOKP && alg == algEdDSA && crv == curveEd25519:
+		x, ok := key[int64(-2)].([]byte)
+		if !ok || len(x) != ed25519.PublicKeySize {
+			return 0, nil, ErrUnsupportedKey
+		}
+		return alg, ed25519.PublicKey(x), nil
+
+	case keyType == keyTypeRSA && alg == algRS256:
+		n, okN := key[int64(-1)].([]byte)
+		e, okE := key[int64(-2)].([]byte)
+		if !okN || !okE || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return 0, nil, ErrUnsupportedKey
+		}
+		return alg, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return 0, nil, ErrUnsupportedKey
+}
+
+// verifySignature checks a signature made with the private key of a COSE public key
+func verifySignature(coseKey, signed, signature []byte) error {
+	alg, public, err := parsePublicKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(signed)
+	valid := false
+	switch alg {
+	case algES256:
+		valid = ecdsa.VerifyASN1(public.(*ecdsa.PublicKey), digest[:], signature)
+	case algRS256:
+		valid = rsa.VerifyPKCS1v15(public.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case algEdDSA:
+		valid = ed25519.Verify(public.(ed25519.PublicKey), signed, signature)
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
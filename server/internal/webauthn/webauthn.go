@@ -0,0 +1,351 @@
+// Package webauthn implements the relying party side of WebAuthn (https://www.w3.org/TR/webauthn-2/)
+// for passkeys: registration and authentication ceremonies with ES256, RS256 and EdDSA credentials.
+// Attestation statements are not verified; a passkey is trusted because the signed-in user registered it.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// ChallengeSize is the number of random bytes in a ceremony challenge
+	ChallengeSize = 32
+	// Timeout is how long the browser waits for the user, and how long a challenge stays valid
+	Timeout = 5 * time.Minute
+
+	clientDataTypeCreate = "webauthn.create"
+	clientDataTypeGet    = "webauthn.get"
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+var (
+	ErrMalformedResponse = errors.New("malformed credential response")
+	ErrChallengeMismatch = errors.New("challenge does not match")
+	ErrOriginMismatch    = errors.New("origin not allowed")
+	ErrRPIDMismatch      = errors.New("relying party ID does not match")
+	ErrUserNotPresent    = errors.New("user presence not asserted")
+	ErrUnsupportedKey    = errors.New("unsupported credential public key")
+	ErrInvalidSignature  = errors.New("invalid assertion signature")
+	ErrSignCount         = errors.New("signature counter did not increase, the authenticator may be cloned")
+)
+
+// RelyingParty is the site passkeys are bound to
+type RelyingParty struct {
+	ID      string   // Domain the credentials are scoped to, e.g. "example.com"
+	Name    string   // Shown by the browser during registration
+	Origins []string // Origins the ceremonies may run on, e.g. "https://app.example.com"
+}
+
+// Bytes is binary data, base64url encoded in JSON as in the WebAuthn JSON serialization
+type Bytes []byte
+
+// MarshalJSON encodes the bytes as unpadded base64url
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON decodes base64url, padded or not
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// CredentialDescriptor identifies a credential in ceremony options
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         Bytes    `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// CredentialParameter is a key type the relying party accepts, by COSE algorithm
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CreationOptions are the options of navigator.credentials.create() (PublicKeyCredentialCreationOptions)
+type CreationOptions struct {
+	Challenge Bytes `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          Bytes  `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// RequestOptions are the options of navigator.credentials.get() (PublicKeyCredentialRequestOptions)
+type RequestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationResponse is the credential returned by navigator.credentials.create(), as serialized by toJSON()
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes    `json:"clientDataJSON"`
+		AttestationObject Bytes    `json:"attestationObject"`
+		Transports        []string `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the credential returned by navigator.credentials.get(), as serialized by toJSON()
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes `json:"clientDataJSON"`
+		AuthenticatorData Bytes `json:"authenticatorData"`
+		Signature         Bytes `json:"signature"`
+		UserHandle        Bytes `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// Credential is a newly registered credential
+type Credential struct {
+	ID           []byte
+	PublicKey    []byte // COSE_Key
+	SignCount    uint32
+	Transports   []string
+	UserVerified bool
+}
+
+// Assertion is the result of a successful authentication ceremony
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+// NewChallenge returns a random ceremony challenge
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// CreationOptions returns the options for registering a passkey. userID is the opaque user handle
+// returned by discoverable logins; exclude lists the user's credentials so one authenticator is not registered twice.
+func (rp *RelyingParty) CreationOptions(challenge, userID []byte, userName, displayName string, exclude []CredentialDescriptor) *CreationOptions {
+	options := &CreationOptions{
+		Challenge:          challenge,
+		Timeout:            Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		Attestation:        "none",
+	}
+	options.RP.ID = rp.ID
+	options.RP.Name = rp.Name
+	options.User.ID = userID
+	options.User.Name = userName
+	options.User.DisplayName = displayName
+	for _, alg := range []int64{algES256, algEdDSA, algRS256} {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+	options.AuthenticatorSelection.ResidentKey = "required"
+	options.AuthenticatorSelection.UserVerification = "preferred"
+	return options
+}
+
+// RequestOptions returns the options for signing in with a passkey. An empty allow list lets the
+// user pick any discoverable credential of the relying party.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow []CredentialDescriptor) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: allow,
+		UserVerification: "preferred",
+	}
+}
+
+// VerifyRegistration checks the response to a registration ceremony started with challenge
+// and returns the new credential (WebAuthn 7.1)
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, response *RegistrationResponse) (*Credential, error) {
+	if response.Type != "public-key" {
+		return nil, ErrMalformedResponse
+	}
+	if err := rp.verifyClientData(response.Response.ClientDataJSON, clientDataTypeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	item, _, err := decodeCBOR(response.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrMalformedResponse
+	}
+	attestation, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrMalformedResponse
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrMalformedResponse
+	}
+
+	authData, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.credentialID == nil {
+		return nil, ErrMalformedResponse
+	}
+	if _, _, err := parsePublicKey(authData.publicKey); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:           authData.credentialID,
+		PublicKey:    authData.publicKey,
+		SignCount:    authData.signCount,
+		Transports:   response.Response.Transports,
+		UserVerified: authData.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion checks the response to an authentication ceremony started with challenge against
+// a stored credential (WebAuthn 7.2). signCount is the counter stored with the credential.
+func (rp *RelyingParty) VerifyAssertion(challenge, publicKey []byte, signCount uint32, response *AssertionResponse) (*Assertion, error) {
+	if response.Type != "public-key" {
+		return nil, ErrMalformedResponse
+	}
+	if err := rp.verifyClientData(response.Response.ClientDataJSON, clientDataTypeGet, challenge); err != nil {
+		return nil, err
+	}
+
+	authData, err := rp.parseAuthenticatorData(response.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), response.Response.AuthenticatorData...), clientDataHash[:]...)
+	if err := verifySignature(publicKey, signed, response.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	// Authenticators without a counter always report 0
+	if (authData.signCount != 0 || signCount != 0) && authData.signCount <= signCount {
+		return nil, ErrSignCount
+	}
+
+	return &Assertion{
+		SignCount:    authData.signCount,
+		UserVerified: authData.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// verifyClientData checks the type, challenge and origin the browser signed over
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return ErrMalformedResponse
+	}
+	if clientData.Type != ceremony {
+		return ErrMalformedResponse
+	}
+
+	received, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(clientData.Challenge, "="))
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return ErrOriginMismatch
+}
+
+// authenticatorData is the parsed authenticator data (WebAuthn 6.1)
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte // Only present in registrations
+	publicKey    []byte
+}
+
+// parseAuthenticatorData parses authenticator data and checks it is scoped to the relying party and the user was present
+func (rp *RelyingParty) parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, ErrMalformedResponse
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data[:32], rpIDHash[:]) {
+		return nil, ErrRPIDMismatch
+	}
+
+	authData := &authenticatorData{
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return nil, ErrUserNotPresent
+	}
+
+	if authData.flags&flagAttestedData != 0 {
+		rest := data[37:]
+		// AAGUID, then the length of the credential ID
+		if len(rest) < 18 {
+			return nil, ErrMalformedResponse
+		}
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLength {
+			return nil, ErrMalformedResponse
+		}
+		authData.credentialID = append([]byte(nil), rest[:idLength]...)
+		rest = rest[idLength:]
+
+		// The COSE key is followed by the extensions, if any
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, ErrMalformedResponse
+		}
+		authData.publicKey = append([]byte(nil), rest[:len(rest)-len(after)]...)
+	}
+	return authData, nil
+}
@@ -0,0 +1,198 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// softAuthenticator is a software authenticator holding one ES256 credential
+type softAuthenticator struct {
+	rpID         string
+	origin       string
+	credentialID []byte
+	key          *ecdsa.PrivateKey
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, rpID, origin string) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &softAuthenticator{rpID: rpID, origin: origin, credentialID: []byte("credential-1"), key: key}
+}
+
+func (a *softAuthenticator) clientData(ceremony string, challenge []byte) []byte {
+	data, _ := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.origin,
+	})
+	return data
+}
+
+func (a *softAuthenticator) authData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	return cborMap(
+		cborInt(coseKeyType), cborInt(keyTypeEC2),
+		cborInt(coseAlgorithm), cborInt(algES256),
+		cborInt(-1), cborInt(curveP256),
+		cborInt(-2), cborBytes(x),
+		cborInt(-3), cborBytes(y),
+	)
+}
+
+func (a *softAuthenticator) register(challenge []byte) *RegistrationResponse {
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, a.coseKey()...)
+
+	response := &RegistrationResponse{ID: base64.RawURLEncoding.EncodeToString(a.credentialID), RawID: a.credentialID, Type: "public-key"}
+	response.Response.ClientDataJSON = a.clientData(clientDataTypeCreate, challenge)
+	response.Response.AttestationObject = cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(a.authData(flagUserPresent|flagUserVerified|flagAttestedData, attested)),
+	)
+	return response
+}
+
+func (a *softAuthenticator) assert(t *testing.T, challenge []byte) *AssertionResponse {
+	a.signCount++
+	response := &AssertionResponse{ID: base64.RawURLEncoding.EncodeToString(a.credentialID), RawID: a.credentialID, Type: "public-key"}
+	response.Response.ClientDataJSON = a.clientData(clientDataTypeGet, challenge)
+	response.Response.AuthenticatorData = a.authData(flagUserPresent|flagUserVerified, nil)
+	response.Response.UserHandle = []byte("user-1")
+
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), response.Response.AuthenticatorData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+	response.Response.Signature = signature
+	return response
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	}
+}
+
+func cborInt(n int64) []byte {
+	if n < 0 {
+		return cborHead(1, uint64(-1-n))
+	}
+	return cborHead(0, uint64(n))
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, uint64(len(b))), b...) }
+
+func cborText(s string) []byte { return append(cborHead(3, uint64(len(s))), s...) }
+
+func cborMap(items ...[]byte) []byte {
+	out := cborHead(5, uint64(len(items)/2))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func newRelyingParty() *RelyingParty {
+	return &RelyingParty{ID: "example.com", Name: "Example", Origins: []string{"https://app.example.com"}}
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := newRelyingParty()
+	authenticator := newSoftAuthenticator(t, "example.com", "https://app.example.com")
+
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	credential, err := rp.VerifyRegistration(challenge, authenticator.register(challenge))
+	require.NoError(t, err)
+	assert.Equal(t, authenticator.credentialID, credential.ID)
+	assert.True(t, credential.UserVerified)
+
+	challenge, err = NewChallenge()
+	require.NoError(t, err)
+	assertion, err := rp.VerifyAssertion(challenge, credential.PublicKey, credential.SignCount, authenticator.assert(t, challenge))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), assertion.SignCount)
+	assert.True(t, assertion.UserVerified)
+}
+
+func TestVerifyRegistration_Rejects(t *testing.T) {
+	rp := newRelyingParty()
+	challenge, _ := NewChallenge()
+
+	_, err := rp.VerifyRegistration([]byte("another challenge"), newSoftAuthenticator(t, "example.com", "https://app.example.com").register(challenge))
+	assert.ErrorIs(t, err, ErrChallengeMismatch)
+
+	_, err = rp.VerifyRegistration(challenge, newSoftAuthenticator(t, "example.com", "https://evil.example").register(challenge))
+	assert.ErrorIs(t, err, ErrOriginMismatch)
+
+	_, err = rp.VerifyRegistration(challenge, newSoftAuthenticator(t, "evil.example", "https://app.example.com").register(challenge))
+	assert.ErrorIs(t, err, ErrRPIDMismatch)
+}
+
+func TestVerifyAssertion_Rejects(t *testing.T) {
+	rp := newRelyingParty()
+	authenticator := newSoftAuthenticator(t, "example.com", "https://app.example.com")
+	challenge, _ := NewChallenge()
+	credential, err := rp.VerifyRegistration(challenge, authenticator.register(challenge))
+	require.NoError(t, err)
+
+	t.Run("wrong key", func(t *testing.T) {
+		other := newSoftAuthenticator(t, "example.com", "https://app.example.com")
+		_, err := rp.VerifyAssertion(challenge, credential.PublicKey, 0, other.assert(t, challenge))
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("registration client data", func(t *testing.T) {
+		response := authenticator.assert(t, challenge)
+		response.Response.ClientDataJSON = authenticator.clientData(clientDataTypeCreate, challenge)
+		_, err := rp.VerifyAssertion(challenge, credential.PublicKey, 0, response)
+		assert.ErrorIs(t, err, ErrMalformedResponse)
+	})
+
+	t.Run("sign count not increased", func(t *testing.T) {
+		response := authenticator.assert(t, challenge)
+		_, err := rp.VerifyAssertion(challenge, credential.PublicKey, authenticator.signCount, response)
+		assert.ErrorIs(t, err, ErrSignCount)
+	})
+}
+
+func TestDecodeCBOR_Malformed(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{0x5f},       // Indefinite length byte string
+		{0x43, 0x01}, // Byte string shorter than its length
+		{0x9a, 0xff, 0xff, 0xff, 0xff},
+		{0xa1, 0x80, 0x01}, // Array as map key
+	} {
+		_, _, err := decodeCBOR(data)
+		assert.Error(t, err, "% x", data)
+	}
+}
@@ -80,8 +80,14 @@ db.tenant_login_configs.insertOne({
     passwordRequireDigit: true,
     passwordRequireSpec: false,
     sessionTimeout: 1440, // 24 hours
+    refreshTokenLifetime: 10080, // 7 days
+    idleTimeout: 0, // disabled
+    maxSessionAge: 0, // disabled
+    maxConcurrentSessions: 0, // unlimited
+    sessionLimitPolicy: "reject",
     maxLoginAttempts: 5,
     lockoutDuration: 30, // 30 minutes
+    passwordlessLogin: false,
     createdAt: new Date(),
     updatedAt: new Date()
 });
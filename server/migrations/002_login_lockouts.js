@@ -0,0 +1,14 @@
+// Login Lockout Indexes
+// Lockouts now apply to a login identifier or an IP address instead of only a user ID
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Lockout lookups by identifier and by IP address
+db.user_lockouts.createIndex({ "identifier": 1, "tenantId": 1, "unlockAt": -1 });
+db.user_lockouts.createIndex({ "ipAddress": 1, "tenantId": 1, "unlockAt": -1 });
+
+print("✅ Lockout indexes created successfully!");
+print("📝 Indexes created on user_lockouts:");
+print("   - identifier, tenantId, unlockAt");
+print("   - ipAddress, tenantId, unlockAt");
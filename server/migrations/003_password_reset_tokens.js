@@ -0,0 +1,19 @@
+// Password Reset Tokens
+// Single-use reset tokens; only the HMAC-SHA256 hash of each token is stored
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Create collection
+db.createCollection("password_reset_tokens");
+
+// 2. Indexes
+db.password_reset_tokens.createIndex({ "tokenHash": 1 }, { unique: true });
+db.password_reset_tokens.createIndex({ "userId": 1 });
+db.password_reset_tokens.createIndex({ "expiresAt": 1 }, { expireAfterSeconds: 0 });
+
+print("✅ Password reset token collection created successfully!");
+print("📝 Indexes created on password_reset_tokens:");
+print("   - tokenHash (unique)");
+print("   - userId");
+print("   - expiresAt (TTL)");
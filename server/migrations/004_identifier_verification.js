@@ -0,0 +1,31 @@
+// Email and Phone Verification
+// Adds per-identifier verification flags and the identifier_verifications collection
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Create collection
+db.createCollection("identifier_verifications");
+
+// 2. Indexes
+db.identifier_verifications.createIndex({ "identifier": 1, "createdAt": -1 });
+db.identifier_verifications.createIndex({ "linkTokenHash": 1 }, { unique: true });
+db.identifier_verifications.createIndex({ "expiresAt": 1 }, { expireAfterSeconds: 0 });
+
+// 3. Users verified before this migration keep access: treat their email as verified
+db.users_auth.updateMany(
+    { isVerified: true, email: { $exists: true, $ne: "" }, emailVerified: { $exists: false } },
+    { $set: { emailVerified: true } }
+);
+
+// 4. Tenants do not require verified identifiers unless enabled
+db.tenant_login_configs.updateMany(
+    { requireVerifiedIdentifier: { $exists: false } },
+    { $set: { requireVerifiedIdentifier: false } }
+);
+
+print("✅ Identifier verification migration completed successfully!");
+print("📝 Indexes created on identifier_verifications:");
+print("   - identifier, createdAt");
+print("   - linkTokenHash (unique)");
+print("   - expiresAt (TTL)");
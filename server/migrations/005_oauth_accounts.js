@@ -0,0 +1,18 @@
+// Social Login
+// Creates the oauth_accounts collection that links Google/GitHub accounts to users
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Create collection
+db.createCollection("oauth_accounts");
+
+// 2. Indexes
+// A provider account belongs to one user, and a user links at most one account per provider
+db.oauth_accounts.createIndex({ "provider": 1, "providerId": 1 }, { unique: true });
+db.oauth_accounts.createIndex({ "userId": 1, "provider": 1 }, { unique: true });
+
+print("✅ Social login migration completed successfully!");
+print("📝 Indexes created on oauth_accounts:");
+print("   - provider, providerId (unique)");
+print("   - userId, provider (unique)");
@@ -0,0 +1,17 @@
+// OpenID Connect Provider
+// Creates the oidc_clients collection holding the applications registered by each tenant
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Create collection
+db.createCollection("oidc_clients");
+
+// 2. Indexes
+db.oidc_clients.createIndex({ "clientId": 1 }, { unique: true });
+db.oidc_clients.createIndex({ "tenantId": 1 });
+
+print("✅ OpenID Connect client migration completed successfully!");
+print("📝 Indexes created on oidc_clients:");
+print("   - clientId (unique)");
+print("   - tenantId");
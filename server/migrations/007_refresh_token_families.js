@@ -0,0 +1,20 @@
+// Refresh Token Rotation
+// Adds token families to refresh_tokens so that a reused refresh token revokes every token issued since the same login
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Indexes
+db.refresh_tokens.createIndex({ "familyId": 1 });
+
+// 2. Existing tokens start their own family, named after their ID
+db.refresh_tokens.find({ familyId: { $exists: false } }).forEach(function (token) {
+    db.refresh_tokens.updateOne(
+        { _id: token._id },
+        { $set: { familyId: token._id.str } }
+    );
+});
+
+print("✅ Refresh token rotation migration completed successfully!");
+print("📝 Indexes created on refresh_tokens:");
+print("   - familyId");
@@ -0,0 +1,39 @@
+// Hashed Refresh Tokens
+// Replaces the plaintext refresh tokens in refresh_tokens with their HMAC-SHA256 hash.
+// Needs mongosh (for Node's crypto module) and the TOKEN_HASH_KEY the service runs with:
+//   TOKEN_HASH_KEY=... mongosh mongodb://localhost:27017 migrations/008_hash_refresh_tokens.js
+
+const crypto = require('crypto');
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// The service falls back to the JWT secret when TOKEN_HASH_KEY is not set
+const key = process.env.TOKEN_HASH_KEY || process.env.JWT_SECRET;
+if (!key) {
+    throw new Error("TOKEN_HASH_KEY or JWT_SECRET must be set");
+}
+
+// 1. Drop the unique index on the plaintext token, which would reject documents without one
+if (db.refresh_tokens.getIndexes().some(function (index) { return index.name === "token_1"; })) {
+    db.refresh_tokens.dropIndex("token_1");
+}
+
+// 2. Hash existing tokens and remove the plaintext
+let migrated = 0;
+db.refresh_tokens.find({ token: { $exists: true } }).forEach(function (doc) {
+    const tokenHash = crypto.createHmac('sha256', key).update(doc.token).digest('hex');
+    db.refresh_tokens.updateOne(
+        { _id: doc._id },
+        { $set: { tokenHash: tokenHash }, $unset: { token: "" } }
+    );
+    migrated++;
+});
+
+// 3. Indexes
+db.refresh_tokens.createIndex({ "tokenHash": 1 }, { unique: true });
+
+print("✅ Hashed refresh token migration completed successfully!");
+print("📝 Refresh tokens hashed: " + migrated);
+print("📝 Indexes created on refresh_tokens:");
+print("   - tokenHash (unique)");
@@ -0,0 +1,25 @@
+// Session Lifetimes
+// Adds the refresh token lifetime, idle timeout and maximum session age to tenant login configurations
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Existing tenants keep the previous behaviour: 7-day refresh tokens, no idle timeout, no maximum age
+db.tenant_login_configs.updateMany(
+    { refreshTokenLifetime: { $exists: false } },
+    { $set: { refreshTokenLifetime: 10080 } }
+);
+db.tenant_login_configs.updateMany(
+    { idleTimeout: { $exists: false } },
+    { $set: { idleTimeout: 0 } }
+);
+db.tenant_login_configs.updateMany(
+    { maxSessionAge: { $exists: false } },
+    { $set: { maxSessionAge: 0 } }
+);
+
+print("✅ Session lifetimes migration completed successfully!");
+print("📝 Fields added to tenant_login_configs:");
+print("   - refreshTokenLifetime (minutes)");
+print("   - idleTimeout (minutes, 0 = disabled)");
+print("   - maxSessionAge (minutes, 0 = disabled)");
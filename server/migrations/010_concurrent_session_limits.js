@@ -0,0 +1,16 @@
+// Concurrent Session Limits
+// Adds a per-user limit on simultaneous sessions to tenant login configurations
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Existing tenants stay unlimited
+db.tenant_login_configs.updateMany(
+    { maxConcurrentSessions: { $exists: false } },
+    { $set: { maxConcurrentSessions: 0, sessionLimitPolicy: "reject" } }
+);
+
+print("✅ Concurrent session limits migration completed successfully!");
+print("📝 Fields added to tenant_login_configs:");
+print("   - maxConcurrentSessions (0 = unlimited)");
+print("   - sessionLimitPolicy (reject | evict_oldest)");
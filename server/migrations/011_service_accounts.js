@@ -0,0 +1,17 @@
+// Service Accounts
+// Creates the service_accounts collection for machine-to-machine clients of a tenant
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Create collection
+db.createCollection("service_accounts");
+
+// 2. Indexes
+db.service_accounts.createIndex({ "clientId": 1 }, { unique: true });
+db.service_accounts.createIndex({ "tenantId": 1 });
+
+print("✅ Service accounts migration completed successfully!");
+print("📝 Indexes created on service_accounts:");
+print("   - clientId (unique)");
+print("   - tenantId");
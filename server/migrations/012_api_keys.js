@@ -0,0 +1,17 @@
+// Personal API Keys
+// Creates the api_keys collection holding the hashed API keys of users
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Create collection
+db.createCollection("api_keys");
+
+// 2. Indexes
+db.api_keys.createIndex({ "keyHash": 1 }, { unique: true });
+db.api_keys.createIndex({ "userId": 1, "tenantId": 1 });
+
+print("✅ API keys migration completed successfully!");
+print("📝 Indexes created on api_keys:");
+print("   - keyHash (unique)");
+print("   - userId + tenantId");
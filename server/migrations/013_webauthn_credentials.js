@@ -0,0 +1,17 @@
+// WebAuthn Passkeys
+// Creates the webauthn_credentials collection holding the passkeys registered by users
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Create collection
+db.createCollection("webauthn_credentials");
+
+// 2. Indexes
+db.webauthn_credentials.createIndex({ "credentialId": 1 }, { unique: true });
+db.webauthn_credentials.createIndex({ "userId": 1, "rpId": 1 });
+
+print("✅ WebAuthn credentials migration completed successfully!");
+print("📝 Indexes created on webauthn_credentials:");
+print("   - credentialId (unique)");
+print("   - userId + rpId");
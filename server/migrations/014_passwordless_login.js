@@ -0,0 +1,27 @@
+// Passwordless Login
+// Creates the passwordless_logins collection and the tenant switch for one-time code and link logins
+
+// Use auth database
+db = db.getSiblingDB('auth_service');
+
+// 1. Create collection
+db.createCollection("passwordless_logins");
+
+// 2. Indexes
+db.passwordless_logins.createIndex({ "identifier": 1, "tenantId": 1, "createdAt": -1 });
+db.passwordless_logins.createIndex({ "linkTokenHash": 1 }, { unique: true });
+db.passwordless_logins.createIndex({ "expiresAt": 1 }, { expireAfterSeconds: 0 });
+
+// 3. Existing tenants keep password login only
+db.tenant_login_configs.updateMany(
+    { passwordlessLogin: { $exists: false } },
+    { $set: { passwordlessLogin: false } }
+);
+
+print("✅ Passwordless login migration completed successfully!");
+print("📝 Indexes created on passwordless_logins:");
+print("   - identifier + tenantId + createdAt");
+print("   - linkTokenHash (unique)");
+print("   - expiresAt (TTL)");
+print("📝 Fields added to tenant_login_configs:");
+print("   - passwordlessLogin (false)");